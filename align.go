@@ -0,0 +1,417 @@
+package goincv
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/wailovet/goincv/third/gonum.org/v1/gonum/mat"
+)
+
+// Affine is a 2x3 row-major transform mapping (x, y) to
+// (A[0]x + A[1]y + A[2], A[3]x + A[4]y + A[5]).
+type Affine [6]float64
+
+// IdentityAffine leaves points unchanged.
+var IdentityAffine = Affine{1, 0, 0, 0, 1, 0}
+
+func (a Affine) Apply(x, y float32) (float32, float32) {
+	fx, fy := float64(x), float64(y)
+	return float32(a[0]*fx + a[1]*fy + a[2]), float32(a[3]*fx + a[4]*fy + a[5])
+}
+
+// Invert returns the inverse transform; ok is false when a is singular.
+func (a Affine) Invert() (inv Affine, ok bool) {
+	det := a[0]*a[4] - a[1]*a[3]
+	if det == 0 {
+		return inv, false
+	}
+	inv[0], inv[1] = a[4]/det, -a[1]/det
+	inv[3], inv[4] = -a[3]/det, a[0]/det
+	inv[2] = -inv[0]*a[2] - inv[1]*a[5]
+	inv[5] = -inv[3]*a[2] - inv[4]*a[5]
+	return inv, true
+}
+
+// TransformKind is the family of transforms an estimator fits.
+type TransformKind int
+
+const (
+	TransformSimilarity TransformKind = iota // rotation, uniform scale and translation
+	TransformRigid                           // rotation and translation
+	TransformAffine                          // full 6 degrees of freedom
+)
+
+// minPoints is the size of a minimal sample.
+func (k TransformKind) minPoints() int {
+	if k == TransformAffine {
+		return 3
+	}
+	return 2
+}
+
+// EstimateTransform fits the transform mapping src onto dst in the least
+// squares sense. Similarity and rigid fits use the closed form of Umeyama,
+// without reflection.
+func EstimateTransform(src, dst [][2]float32, kind TransformKind) (Affine, error) {
+	if len(src) != len(dst) {
+		return Affine{}, fmt.Errorf("%d source points but %d destination points", len(src), len(dst))
+	}
+	if len(src) < kind.minPoints() {
+		return Affine{}, fmt.Errorf("need at least %d points, got %d", kind.minPoints(), len(src))
+	}
+	if kind == TransformAffine {
+		return estimateFullAffine(src, dst)
+	}
+	n := float64(len(src))
+	var msx, msy, mdx, mdy float64
+	for i := range src {
+		msx += float64(src[i][0])
+		msy += float64(src[i][1])
+		mdx += float64(dst[i][0])
+		mdy += float64(dst[i][1])
+	}
+	msx, msy, mdx, mdy = msx/n, msy/n, mdx/n, mdy/n
+	var a, b, varS float64
+	for i := range src {
+		sx, sy := float64(src[i][0])-msx, float64(src[i][1])-msy
+		dx, dy := float64(dst[i][0])-mdx, float64(dst[i][1])-mdy
+		a += sx*dx + sy*dy
+		b += sx*dy - sy*dx
+		varS += sx*sx + sy*sy
+	}
+	norm := math.Hypot(a, b)
+	if varS == 0 || norm == 0 {
+		return Affine{}, errors.New("degenerate point configuration")
+	}
+	cos, sin := a/norm, b/norm
+	scale := 1.0
+	if kind == TransformSimilarity {
+		scale = norm / varS
+	}
+	m := Affine{scale * cos, -scale * sin, 0, scale * sin, scale * cos, 0}
+	m[2] = mdx - m[0]*msx - m[1]*msy
+	m[5] = mdy - m[3]*msx - m[4]*msy
+	return m, nil
+}
+
+func estimateFullAffine(src, dst [][2]float32) (Affine, error) {
+	a := mat.NewDense(len(src), 3, nil)
+	b := mat.NewDense(len(src), 2, nil)
+	for i := range src {
+		a.SetRow(i, []float64{float64(src[i][0]), float64(src[i][1]), 1})
+		b.SetRow(i, []float64{float64(dst[i][0]), float64(dst[i][1])})
+	}
+	var x mat.Dense
+	if err := x.Solve(a, b); err != nil {
+		return Affine{}, err
+	}
+	return Affine{x.At(0, 0), x.At(1, 0), x.At(2, 0), x.At(0, 1), x.At(1, 1), x.At(2, 1)}, nil
+}
+
+// RANSACOptions configures robust estimation. Zero values pick the
+// defaults in brackets.
+type RANSACOptions struct {
+	Threshold  float64 // maximum reprojection error of an inlier in pixels [3]
+	Iterations int     // upper bound on the number of samples [2000]
+	Confidence float64 // stop once a better model is this unlikely [0.99]
+	Seed       int64   // random seed, for reproducible results
+}
+
+func (o RANSACOptions) withDefaults() RANSACOptions {
+	if o.Threshold <= 0 {
+		o.Threshold = 3
+	}
+	if o.Iterations <= 0 {
+		o.Iterations = 2000
+	}
+	if o.Confidence <= 0 || o.Confidence >= 1 {
+		o.Confidence = 0.99
+	}
+	return o
+}
+
+// ransac is shared by the transform and homography estimators: fit builds
+// a model from a subset, residual measures one correspondence.
+func ransac[M any](n, sample int, opt RANSACOptions, fit func(idx []int) (M, error), residual func(m M, i int) float64) (best M, inliers []bool, err error) {
+	if n < sample {
+		return best, nil, fmt.Errorf("need at least %d points, got %d", sample, n)
+	}
+	opt = opt.withDefaults()
+	rng := rand.New(rand.NewSource(opt.Seed))
+	bestCount := -1
+	mask := make([]bool, n)
+	idx := make([]int, sample)
+	for it, limit := 0, opt.Iterations; it < limit; it++ {
+		for i := 0; i < sample; {
+			idx[i] = rng.Intn(n)
+			dup := false
+			for _, p := range idx[:i] {
+				dup = dup || p == idx[i]
+			}
+			if !dup {
+				i++
+			}
+		}
+		m, err := fit(idx)
+		if err != nil {
+			continue
+		}
+		count := 0
+		for i := range mask {
+			mask[i] = residual(m, i) <= opt.Threshold
+			if mask[i] {
+				count++
+			}
+		}
+		if count > bestCount {
+			bestCount, best = count, m
+			inliers = append(inliers[:0], mask...)
+			// adaptive number of iterations for the inlier ratio found
+			w := math.Pow(float64(count)/float64(n), float64(sample))
+			if w >= 1 {
+				break
+			}
+			if w > 0 {
+				if k := math.Log(1-opt.Confidence) / math.Log(1-w); k < float64(limit) {
+					limit = int(math.Ceil(k))
+				}
+			}
+		}
+	}
+	if bestCount < sample {
+		return best, nil, errors.New("no consistent model found")
+	}
+	var all []int
+	for i, in := range inliers {
+		if in {
+			all = append(all, i)
+		}
+	}
+	// refit on every inlier
+	if m, err := fit(all); err == nil {
+		best = m
+		for i := range inliers {
+			inliers[i] = residual(best, i) <= opt.Threshold
+		}
+	}
+	return best, inliers, nil
+}
+
+// EstimateTransformRANSAC fits the transform robustly to correspondences
+// containing outliers, returning the inlier mask of the final model.
+func EstimateTransformRANSAC(src, dst [][2]float32, kind TransformKind, opt RANSACOptions) (Affine, []bool, error) {
+	if len(src) != len(dst) {
+		return Affine{}, nil, fmt.Errorf("%d source points but %d destination points", len(src), len(dst))
+	}
+	pick := func(idx []int) (s, d [][2]float32) {
+		for _, i := range idx {
+			s, d = append(s, src[i]), append(d, dst[i])
+		}
+		return
+	}
+	return ransac(len(src), kind.minPoints(), opt, func(idx []int) (Affine, error) {
+		s, d := pick(idx)
+		return EstimateTransform(s, d, kind)
+	}, func(m Affine, i int) float64 {
+		x, y := m.Apply(src[i][0], src[i][1])
+		return math.Hypot(float64(x-dst[i][0]), float64(y-dst[i][1]))
+	})
+}
+
+// Interpolation selects how warps sample the source image.
+type Interpolation int
+
+const (
+	InterLinear  Interpolation = iota // bilinear
+	InterNearest                      // nearest neighbour
+	InterCubic                        // bicubic, a = -0.75 like OpenCV
+)
+
+// BorderMode selects what warps read outside the source image.
+type BorderMode int
+
+const (
+	BorderConstant  BorderMode = iota // WarpOptions.BorderValue
+	BorderReplicate                   // the nearest edge pixel
+	BorderReflect                     // mirrored without repeating the edge, OpenCV's BORDER_REFLECT_101
+)
+
+// WarpOptions configures WarpAffine and WarpPerspective.
+type WarpOptions struct {
+	Interpolation Interpolation
+	Border        BorderMode
+	BorderValue   color.Color // transparent black when nil
+}
+
+// WarpAffine maps img through m, which takes source coordinates to
+// destination ones as in OpenCV, into a width x height image. Pixel
+// centres sit on integer coordinates.
+func WarpAffine(img image.Image, m Affine, width, height int, opt WarpOptions) (*image.RGBA, error) {
+	inv, ok := m.Invert()
+	if !ok {
+		return nil, errors.New("transform is not invertible")
+	}
+	return warp(img, width, height, opt, func(x, y float64) (float64, float64, bool) {
+		return inv[0]*x + inv[1]*y + inv[2], inv[3]*x + inv[4]*y + inv[5], true
+	}), nil
+}
+
+// warp fills each destination pixel from the source point given by back.
+func warp(img image.Image, width, height int, opt WarpOptions, back func(x, y float64) (float64, float64, bool)) *image.RGBA {
+	src := ToRGBA(img)
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	var bg [4]uint8
+	if opt.BorderValue != nil {
+		c := color.RGBAModel.Convert(opt.BorderValue).(color.RGBA)
+		bg = [4]uint8{c.R, c.G, c.B, c.A}
+	}
+	w, h := b.Dx(), b.Dy()
+	// pixel fetches the source pixel at (x, y) relative to Min, applying the
+	// border mode; ok is false for a constant border
+	pixel := func(x, y int) (px []uint8, ok bool) {
+		if x < 0 || y < 0 || x >= w || y >= h {
+			switch opt.Border {
+			case BorderReplicate:
+				x, y = clampIndex(x, 0, w-1), clampIndex(y, 0, h-1)
+			case BorderReflect:
+				x, y = reflectIndex(x, w), reflectIndex(y, h)
+			default:
+				return nil, false
+			}
+		}
+		o := y*src.Stride + x*4
+		return src.Pix[o : o+4 : o+4], true
+	}
+	var acc [4]float64
+	add := func(x, y int, wt float64) {
+		if wt == 0 {
+			return
+		}
+		p, ok := pixel(x, y)
+		for c := range acc {
+			v := bg[c]
+			if ok {
+				v = p[c]
+			}
+			acc[c] += wt * float64(v)
+		}
+	}
+	for y := 0; y < height; y++ {
+		row := dst.Pix[y*dst.Stride:]
+		for x := 0; x < width; x++ {
+			out := row[x*4 : x*4+4 : x*4+4]
+			sx, sy, ok := back(float64(x), float64(y))
+			if !ok {
+				copy(out, bg[:])
+				continue
+			}
+			sx -= float64(b.Min.X)
+			sy -= float64(b.Min.Y)
+			switch opt.Interpolation {
+			case InterNearest:
+				p, ok := pixel(int(math.Round(sx)), int(math.Round(sy)))
+				if ok {
+					copy(out, p)
+				} else {
+					copy(out, bg[:])
+				}
+				continue
+			case InterCubic:
+				acc = [4]float64{}
+				x0, y0 := int(math.Floor(sx)), int(math.Floor(sy))
+				wx, wy := cubicWeights(sx-float64(x0)), cubicWeights(sy-float64(y0))
+				for j := 0; j < 4; j++ {
+					for i := 0; i < 4; i++ {
+						add(x0-1+i, y0-1+j, wx[i]*wy[j])
+					}
+				}
+			default:
+				acc = [4]float64{}
+				x0, y0 := int(math.Floor(sx)), int(math.Floor(sy))
+				ax, ay := sx-float64(x0), sy-float64(y0)
+				add(x0, y0, (1-ax)*(1-ay))
+				add(x0+1, y0, ax*(1-ay))
+				add(x0, y0+1, (1-ax)*ay)
+				add(x0+1, y0+1, ax*ay)
+			}
+			for c := range acc {
+				out[c] = uint8(math.Max(0, math.Min(255, math.Round(acc[c]))))
+			}
+		}
+	}
+	return dst
+}
+
+func cubicWeights(t float64) [4]float64 {
+	const a = -0.75
+	w0 := ((a*(t+1)-5*a)*(t+1)+8*a)*(t+1) - 4*a
+	w1 := ((a+2)*t-(a+3))*t*t + 1
+	w2 := ((a+2)*(1-t)-(a+3))*(1-t)*(1-t) + 1
+	return [4]float64{w0, w1, w2, 1 - w0 - w1 - w2}
+}
+
+// reflectIndex mirrors i into [0, n) without repeating the edge pixel.
+func reflectIndex(i, n int) int {
+	if n == 1 {
+		return 0
+	}
+	period := 2 * (n - 1)
+	i %= period
+	if i < 0 {
+		i += period
+	}
+	if i >= n {
+		i = period - i
+	}
+	return i
+}
+
+// arcFaceTemplate holds the five reference landmarks of a 112x112 ArcFace
+// crop: eyes, nose tip and mouth corners, in SCRFD order.
+var arcFaceTemplate = [5][2]float32{
+	{38.2946, 51.6963},
+	{73.5318, 51.5014},
+	{56.0252, 71.7366},
+	{41.5493, 92.3655},
+	{70.7299, 92.2041},
+}
+
+// ArcFaceTemplate returns the reference landmarks for a size x size crop,
+// scaled the way insightface does: multiples of 112 scale the template,
+// other sizes treat it as a 128 crop with an 8 pixel horizontal margin.
+func ArcFaceTemplate(size int) [][2]float32 {
+	ratio, dx := float32(size)/112, float32(0)
+	if size%112 != 0 {
+		ratio = float32(size) / 128
+		dx = 8 * ratio
+	}
+	ret := make([][2]float32, len(arcFaceTemplate))
+	for i, p := range arcFaceTemplate {
+		ret[i] = [2]float32{p[0]*ratio + dx, p[1] * ratio}
+	}
+	return ret
+}
+
+// AlignFace crops a size x size face aligned to ArcFaceTemplate from the
+// five landmarks of a detector such as SCRFD (Box.Landmark). It also
+// returns the transform from the image to the crop.
+func AlignFace(img image.Image, landmarks []BoxLandmark, size int) (*image.RGBA, Affine, error) {
+	if len(landmarks) != 5 {
+		return nil, Affine{}, fmt.Errorf("need 5 landmarks, got %d", len(landmarks))
+	}
+	src := make([][2]float32, 5)
+	for i, l := range landmarks {
+		src[i] = [2]float32{float32(l.X), float32(l.Y)}
+	}
+	m, err := EstimateTransform(src, ArcFaceTemplate(size), TransformSimilarity)
+	if err != nil {
+		return nil, Affine{}, err
+	}
+	crop, err := WarpAffine(img, m, size, size, WarpOptions{BorderValue: color.Black})
+	return crop, m, err
+}
@@ -0,0 +1,97 @@
+package goincv
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestEstimateTransform(t *testing.T) {
+	src := [][2]float32{{10, 20}, {100, 30}, {60, 120}, {15, 90}, {80, 70}}
+	rot := func(deg, scale, tx, ty float64) Affine {
+		s, c := math.Sincos(deg * math.Pi / 180)
+		return Affine{scale * c, -scale * s, tx, scale * s, scale * c, ty}
+	}
+	cases := []struct {
+		name string
+		kind TransformKind
+		m    Affine
+	}{
+		{"identity", TransformSimilarity, IdentityAffine},
+		{"similarity", TransformSimilarity, rot(30, 1.5, 12, -7)},
+		{"rigid", TransformRigid, rot(-75, 1, 3, 40)},
+		{"similarity shrink", TransformSimilarity, rot(170, 0.25, -50, 5)},
+		{"affine", TransformAffine, Affine{1.2, 0.3, 5, -0.4, 0.8, 9}},
+		{"affine shear", TransformAffine, Affine{1, 0.7, 0, 0, 1, 0}},
+	}
+	for _, c := range cases {
+		dst := make([][2]float32, len(src))
+		for i, p := range src {
+			dst[i][0], dst[i][1] = c.m.Apply(p[0], p[1])
+		}
+		got, err := EstimateTransform(src, dst, c.kind)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i]-c.m[i]) > 1e-3 {
+				t.Errorf("%s: got %v, want %v", c.name, got, c.m)
+				break
+			}
+		}
+	}
+
+	// a rigid fit of a scaled copy keeps the rotation
+	dst := make([][2]float32, len(src))
+	for i, p := range src {
+		dst[i][0], dst[i][1] = rot(40, 2, 0, 0).Apply(p[0], p[1])
+	}
+	got, err := EstimateTransform(src, dst, TransformRigid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if angle := math.Atan2(got[3], got[0]) * 180 / math.Pi; math.Abs(angle-40) > 1e-3 || math.Abs(math.Hypot(got[0], got[3])-1) > 1e-9 {
+		t.Errorf("rigid fit %v, want a 40° rotation without scale", got)
+	}
+
+	if _, err := EstimateTransform(src[:1], dst[:1], TransformSimilarity); err == nil {
+		t.Error("one point was accepted")
+	}
+	if _, err := EstimateTransform(src[:2], dst[:2], TransformAffine); err == nil {
+		t.Error("two points were accepted for an affine fit")
+	}
+	same := [][2]float32{{1, 1}, {1, 1}, {1, 1}}
+	if _, err := EstimateTransform(same, same, TransformSimilarity); err == nil {
+		t.Error("coincident points were accepted")
+	}
+}
+
+func TestEstimateTransformRANSAC(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	m := Affine{0.8, -0.6, 20, 0.6, 0.8, -10}
+	var src, dst [][2]float32
+	for i := 0; i < 40; i++ {
+		x, y := r.Float32()*200, r.Float32()*200
+		u, v := m.Apply(x, y)
+		if i%4 == 0 {
+			// a quarter of gross outliers
+			u, v = r.Float32()*200, r.Float32()*200
+		}
+		src, dst = append(src, [2]float32{x, y}), append(dst, [2]float32{u, v})
+	}
+	got, inliers, err := EstimateTransformRANSAC(src, dst, TransformSimilarity, RANSACOptions{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range got {
+		if math.Abs(got[i]-m[i]) > 1e-3 {
+			t.Fatalf("got %v, want %v", got, m)
+		}
+	}
+	for i, in := range inliers {
+		if in != (i%4 != 0) {
+			t.Errorf("point %d inlier %v", i, in)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package goincv
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// ScoreActivation says how raw class scores become probabilities.
+type ScoreActivation int
+
+const (
+	ScoreRaw     ScoreActivation = iota // already probabilities
+	ScoreSigmoid                        // independent logits, RT-DETR and DINO
+	ScoreSoftmax                        // softmax whose last class is "no object", original DETR
+)
+
+// YoloV5Anchors are the default COCO anchors in pixels, keyed by stride.
+var YoloV5Anchors = map[int][][2]float32{
+	8:  {{10, 13}, {16, 30}, {33, 23}},
+	16: {{30, 61}, {62, 45}, {59, 119}},
+	32: {{116, 90}, {156, 198}, {373, 326}},
+}
+
+// DetectDecoder turns the outputs of common detector heads into Box values
+// in source image coordinates. Outputs are for one image; a leading batch
+// axis of 1 is accepted.
+type DetectDecoder struct {
+	Width, Height int             // model input size
+	ScaleParams   ScaleParams     // from ResizeImageBorder or Preprocessor
+	Source        image.Rectangle // boxes are clamped to it when not empty
+	ConfThreshold float32         // 0.25 when zero
+	MultiLabel    bool            // emit one box per class above threshold
+	MaskCoeffs    int             // trailing mask coefficients per row, 32 for YOLOv8-seg
+}
+
+// MaskCoeffsKey is the Box.Extension key holding the []float32 mask
+// coefficients of a segmentation head, consumed by DecodeMasks.
+const MaskCoeffsKey = "mask_coeffs"
+
+// NewDetectDecoder returns a decoder for a letterboxed input, computing
+// the same ScaleParams as NewNMS.
+func NewDetectDecoder(src image.Rectangle, width, height int) *DetectDecoder {
+	params, _, _ := letterbox(src.Dx(), src.Dy(), width, height)
+	return &DetectDecoder{Width: width, Height: height, ScaleParams: params, Source: src}
+}
+
+func (d *DetectDecoder) threshold() float32 {
+	if d.ConfThreshold == 0 {
+		return 0.25
+	}
+	return d.ConfThreshold
+}
+
+// rect maps a model space box to the source image. Like the other
+// decoders of the package it returns inclusive corners, clamped to Source
+// when set; ok is false for boxes that fall entirely into the letterbox
+// padding.
+func (d *DetectDecoder) rect(x0, y0, x1, y1 float32) (r image.Rectangle, ok bool) {
+	x0, y0 = d.ScaleParams.Unscale(x0, y0)
+	x1, y1 = d.ScaleParams.Unscale(x1, y1)
+	fx0, fy0, fx1, fy1 := float64(x0), float64(y0), float64(x1), float64(y1)
+	if !d.Source.Empty() {
+		fx0 = math.Max(fx0, float64(d.Source.Min.X))
+		fy0 = math.Max(fy0, float64(d.Source.Min.Y))
+		fx1 = math.Min(fx1, float64(d.Source.Max.X-1))
+		fy1 = math.Min(fy1, float64(d.Source.Max.Y-1))
+	}
+	if fx1 < fx0 || fy1 < fy0 {
+		return r, false
+	}
+	r = image.Rectangle{image.Pt(int(fx0), int(fy0)), image.Pt(int(fx1), int(fy1))}
+	return r, true
+}
+
+// emit appends the boxes of one candidate; scores(c) returns the
+// probability of class c.
+func (d *DetectDecoder) emit(boxes []Box, nc int, scores func(c int) float32, x0, y0, x1, y1 float32) []Box {
+	thr := d.threshold()
+	best, bestP := -1, float32(0)
+	for c := 0; c < nc; c++ {
+		p := scores(c)
+		if d.MultiLabel && p >= thr {
+			if r, ok := d.rect(x0, y0, x1, y1); ok {
+				boxes = append(boxes, Box{Rectangle: r, Prob: p, ClassID: c})
+			}
+		}
+		if best < 0 || p > bestP {
+			best, bestP = c, p
+		}
+	}
+	if d.MultiLabel || best < 0 || bestP < thr {
+		return boxes
+	}
+	if r, ok := d.rect(x0, y0, x1, y1); ok {
+		boxes = append(boxes, Box{Rectangle: r, Prob: bestP, ClassID: best})
+	}
+	return boxes
+}
+
+// attachCoeffs stores a copy of the mask coefficients in the boxes emitted
+// since from.
+func (d *DetectDecoder) attachCoeffs(boxes []Box, from int, coeffs []float32) {
+	if d.MaskCoeffs == 0 || from == len(boxes) {
+		return
+	}
+	coeffs = append([]float32(nil), coeffs...)
+	for i := from; i < len(boxes); i++ {
+		boxes[i].Extension = map[string]interface{}{MaskCoeffsKey: coeffs}
+	}
+}
+
+// squeezeBatch drops leading axes of size 1 until t has nd axes.
+func squeezeBatch(t AnyTensor, nd int) (*Tensor[float32], error) {
+	f, err := AsTensor[float32](t)
+	if err != nil {
+		return nil, err
+	}
+	for len(f.shape) > nd && f.shape[0] == 1 {
+		f = f.Index(0)
+	}
+	if len(f.shape) != nd {
+		return nil, fmt.Errorf("expected %d axes, got shape %v", nd, t.Shape())
+	}
+	return f.Contiguous(), nil
+}
+
+// YoloV5 decodes the exported output [N, 5+nc] of cx, cy, w, h in pixels,
+// objectness and class probabilities. The final score is obj * cls. With
+// MaskCoeffs set rows are [N, 5+nc+nm] as exported by YOLOv5-seg.
+func (d *DetectDecoder) YoloV5(out AnyTensor) ([]Box, error) {
+	t, err := squeezeBatch(out, 2)
+	if err != nil {
+		return nil, err
+	}
+	n, c := t.shape[0], t.shape[1]
+	nc := c - 5 - d.MaskCoeffs
+	if nc < 1 {
+		return nil, fmt.Errorf("yolov5 output %v has no classes", t.shape)
+	}
+	data, thr := t.data[t.offset:], d.threshold()
+	var boxes []Box
+	for i := 0; i < n; i++ {
+		row := data[i*c : (i+1)*c]
+		obj := row[4]
+		if obj < thr {
+			continue
+		}
+		x0, y0 := row[0]-row[2]/2, row[1]-row[3]/2
+		from := len(boxes)
+		boxes = d.emit(boxes, nc, func(k int) float32 { return obj * row[5+k] }, x0, y0, x0+row[2], y0+row[3])
+		d.attachCoeffs(boxes, from, row[5+nc:])
+	}
+	return boxes, nil
+}
+
+// YoloV5Raw decodes one undecoded head of shape [na, H, W, 5+nc] holding
+// logits, applying the anchor grid of the given stride. anchors nil uses
+// YoloV5Anchors. With MaskCoeffs set each row ends with nm raw mask
+// coefficients, as in YOLOv5-seg.
+func (d *DetectDecoder) YoloV5Raw(stride int, out AnyTensor, anchors [][2]float32) ([]Box, error) {
+	t, err := squeezeBatch(out, 4)
+	if err != nil {
+		return nil, err
+	}
+	if anchors == nil {
+		anchors = YoloV5Anchors[stride]
+	}
+	na, h, w, c := t.shape[0], t.shape[1], t.shape[2], t.shape[3]
+	nc := c - 5 - d.MaskCoeffs
+	if na != len(anchors) || nc < 1 {
+		return nil, fmt.Errorf("yolov5 head %v does not match %d anchors", t.shape, len(anchors))
+	}
+	data, thr := t.data[t.offset:], d.threshold()
+	s := float32(stride)
+	var boxes []Box
+	for a := 0; a < na; a++ {
+		for gy := 0; gy < h; gy++ {
+			for gx := 0; gx < w; gx++ {
+				row := data[((a*h+gy)*w+gx)*c:][:c]
+				obj := float32(sigmoid(float64(row[4])))
+				if obj < thr {
+					continue
+				}
+				cx := (float32(sigmoid(float64(row[0])))*2 - 0.5 + float32(gx)) * s
+				cy := (float32(sigmoid(float64(row[1])))*2 - 0.5 + float32(gy)) * s
+				bw := float32(sigmoid(float64(row[2]))) * 2
+				bh := float32(sigmoid(float64(row[3]))) * 2
+				bw, bh = bw*bw*anchors[a][0], bh*bh*anchors[a][1]
+				from := len(boxes)
+				boxes = d.emit(boxes, nc, func(k int) float32 {
+					return obj * float32(sigmoid(float64(row[5+k])))
+				}, cx-bw/2, cy-bh/2, cx+bw/2, cy+bh/2)
+				d.attachCoeffs(boxes, from, row[5+nc:])
+			}
+		}
+	}
+	return boxes, nil
+}
+
+// YoloV8 decodes the exported output [4+nc, N] of cx, cy, w, h in pixels
+// and class probabilities, without objectness. An already transposed
+// [N, 4+nc] output is recognised by having more rows than columns. With
+// MaskCoeffs set the output is [4+nc+nm, N] as exported by YOLOv8-seg.
+func (d *DetectDecoder) YoloV8(out AnyTensor) ([]Box, error) {
+	t, err := squeezeBatch(out, 2)
+	if err != nil {
+		return nil, err
+	}
+	if t.shape[0] < t.shape[1] {
+		t = t.Transpose().Contiguous()
+	}
+	n, c := t.shape[0], t.shape[1]
+	nc := c - 4 - d.MaskCoeffs
+	if nc < 1 {
+		return nil, fmt.Errorf("yolov8 output %v has no classes", t.shape)
+	}
+	data := t.data[t.offset:]
+	var boxes []Box
+	for i := 0; i < n; i++ {
+		row := data[i*c : (i+1)*c]
+		x0, y0 := row[0]-row[2]/2, row[1]-row[3]/2
+		from := len(boxes)
+		boxes = d.emit(boxes, nc, func(k int) float32 { return row[4+k] }, x0, y0, x0+row[2], y0+row[3])
+		d.attachCoeffs(boxes, from, row[4+nc:])
+	}
+	return boxes, nil
+}
+
+// YoloV8Raw decodes one head of shape [4*regMax+nc, H, W] before the DFL
+// layer: each box side is the expectation of a softmax over regMax bins,
+// measured from the cell centre in units of stride. Class scores are
+// logits. With MaskCoeffs set the head is [4*regMax+nc+nm, H, W], the mask
+// coefficients following the classes.
+func (d *DetectDecoder) YoloV8Raw(stride, regMax int, out AnyTensor) ([]Box, error) {
+	t, err := squeezeBatch(out, 3)
+	if err != nil {
+		return nil, err
+	}
+	if regMax <= 0 {
+		regMax = 16
+	}
+	c, h, w := t.shape[0], t.shape[1], t.shape[2]
+	nc := c - 4*regMax - d.MaskCoeffs
+	if nc <= 0 {
+		return nil, fmt.Errorf("yolov8 head %v is too small for reg_max %d", t.shape, regMax)
+	}
+	data, hw, s := t.data[t.offset:], h*w, float32(stride)
+	thr := d.threshold()
+	logitThr := float32(math.Log(float64(thr) / (1 - float64(thr))))
+	bins := make([]float64, regMax)
+	coeffs := make([]float32, d.MaskCoeffs)
+	var boxes []Box
+	for gy := 0; gy < h; gy++ {
+		for gx := 0; gx < w; gx++ {
+			p := gy*w + gx
+			// skip cells whose best logit is below threshold before any exp
+			best := float32(math.Inf(-1))
+			for k := 0; k < nc; k++ {
+				if v := data[(4*regMax+k)*hw+p]; v > best {
+					best = v
+				}
+			}
+			if best < logitThr {
+				continue
+			}
+			var dist [4]float32
+			for side := range dist {
+				max := math.Inf(-1)
+				for b := range bins {
+					bins[b] = float64(data[(side*regMax+b)*hw+p])
+					max = math.Max(max, bins[b])
+				}
+				sum, acc := 0.0, 0.0
+				for b := range bins {
+					e := math.Exp(bins[b] - max)
+					sum += e
+					acc += e * float64(b)
+				}
+				dist[side] = float32(acc / sum)
+			}
+			cx, cy := float32(gx)+0.5, float32(gy)+0.5
+			from := len(boxes)
+			boxes = d.emit(boxes, nc, func(k int) float32 {
+				return float32(sigmoid(float64(data[(4*regMax+k)*hw+p])))
+			}, (cx-dist[0])*s, (cy-dist[1])*s, (cx+dist[2])*s, (cy+dist[3])*s)
+			if len(boxes) > from {
+				for k := range coeffs {
+					coeffs[k] = data[(4*regMax+nc+k)*hw+p]
+				}
+				d.attachCoeffs(boxes, from, coeffs)
+			}
+		}
+	}
+	return boxes, nil
+}
+
+// DETR decodes set prediction outputs: boxes [N,4] of cx, cy, w, h
+// normalised to the model input and class scores [N,nc].
+func (d *DetectDecoder) DETR(boxes, scores AnyTensor, act ScoreActivation) ([]Box, error) {
+	b, err := squeezeBatch(boxes, 2)
+	if err != nil {
+		return nil, err
+	}
+	s, err := squeezeBatch(scores, 2)
+	if err != nil {
+		return nil, err
+	}
+	if b.shape[1] != 4 || b.shape[0] != s.shape[0] {
+		return nil, fmt.Errorf("detr boxes %v do not match scores %v", b.shape, s.shape)
+	}
+	nc := s.shape[1]
+	switch act {
+	case ScoreSigmoid:
+		s = s.Sigmoid()
+	case ScoreSoftmax:
+		s = s.Softmax(1)
+		nc-- // the no-object class
+	}
+	bd, sd := b.data[b.offset:], s.data[s.offset:]
+	fw, fh := float32(d.Width), float32(d.Height)
+	var ret []Box
+	for i := 0; i < b.shape[0]; i++ {
+		row := sd[i*s.shape[1]:]
+		cx, cy, w, h := bd[i*4]*fw, bd[i*4+1]*fh, bd[i*4+2]*fw, bd[i*4+3]*fh
+		ret = d.emit(ret, nc, func(k int) float32 { return row[k] }, cx-w/2, cy-h/2, cx+w/2, cy+h/2)
+	}
+	return ret, nil
+}
@@ -0,0 +1,87 @@
+package goincv
+
+import (
+	"image"
+	"testing"
+)
+
+func TestRawDecodersMaskCoeffs(t *testing.T) {
+	d := NewDetectDecoder(image.Rect(0, 0, 64, 64), 64, 64)
+	d.MaskCoeffs = 2
+
+	// YOLOv5-seg head: one anchor, a 1x1 grid, 5+1 class+2 coefficients
+	v5 := MustTensorFrom([]float32{0, 0, 0, 0, 10, 10, 0.25, -0.5}, Shape{1, 1, 1, 1, 8})
+	boxes, err := d.YoloV5Raw(8, v5, [][2]float32{{16, 16}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCoeffs(t, "yolov5", boxes, 0.25, -0.5)
+
+	// YOLOv8-seg head with reg_max 2: 4 sides of 2 bins, 1 class, 2 coefficients
+	v8 := MustTensorFrom([]float32{0, 5, 0, 5, 0, 5, 0, 5, 10, 0.75, 1.5}, Shape{1, 11, 1, 1})
+	if boxes, err = d.YoloV8Raw(8, 2, v8); err != nil {
+		t.Fatal(err)
+	}
+	checkCoeffs(t, "yolov8", boxes, 0.75, 1.5)
+
+	// the coefficients leave no room for classes
+	d.MaskCoeffs = 3
+	if _, err := d.YoloV5Raw(8, v5, [][2]float32{{16, 16}}); err == nil {
+		t.Error("yolov5 head without classes was accepted")
+	}
+	if _, err := d.YoloV8Raw(8, 2, v8); err == nil {
+		t.Error("yolov8 head without classes was accepted")
+	}
+}
+
+func checkCoeffs(t *testing.T, name string, boxes []Box, want ...float32) {
+	t.Helper()
+	if len(boxes) != 1 {
+		t.Fatalf("%s: got %d boxes, want 1", name, len(boxes))
+	}
+	if boxes[0].ClassID != 0 {
+		t.Errorf("%s: class %d, want 0", name, boxes[0].ClassID)
+	}
+	got, _ := boxes[0].Extension[MaskCoeffsKey].([]float32)
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("%s: coefficients %v, want %v", name, got, want)
+	}
+}
+
+func TestDecoderRectangles(t *testing.T) {
+	// a 128x64 source letterboxed into 64x64: ratio 0.5 and 16 rows of
+	// padding above and below
+	d := NewDetectDecoder(image.Rect(0, 0, 128, 64), 64, 64)
+	rows := [][5]float32{
+		{32, 32, 20, 10, 0.9}, // inside
+		{60, 32, 10, 4, 0.9},  // past the right edge
+		{10, 5, 4, 6, 0.9},    // entirely in the top padding
+		{2, 18, 8, 8, 0.9},    // past the top left corner
+		{32, 32, 20, 10, 0.1}, // below threshold
+		{32, 32, 20, 10, 0.1},
+	}
+	out := NewTensor[float32](Shape{5, len(rows)})
+	for i, r := range rows {
+		for k, v := range r {
+			out.Set(v, k, i)
+		}
+	}
+	boxes, err := d.YoloV8(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Max is inclusive and stays inside the source
+	want := []image.Rectangle{
+		{image.Pt(44, 22), image.Pt(84, 42)},
+		{image.Pt(110, 28), image.Pt(127, 36)},
+		{image.Pt(0, 0), image.Pt(12, 12)},
+	}
+	if len(boxes) != len(want) {
+		t.Fatalf("got %d boxes, want %d: %v", len(boxes), len(want), boxes)
+	}
+	for i, b := range boxes {
+		if b.Rectangle != want[i] {
+			t.Errorf("box %d: %v, want %v", i, b.Rectangle, want[i])
+		}
+	}
+}
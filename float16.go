@@ -0,0 +1,301 @@
+package goincv
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Float16 is an IEEE 754 half precision number stored as its bit pattern.
+type Float16 uint16
+
+// BFloat16 is a brain floating point number: the upper 16 bits of a float32.
+type BFloat16 uint16
+
+// Float16FromFloat32 rounds f to the nearest half precision value, ties
+// to even. Values too large become ±Inf, tiny values become subnormals or
+// ±0, and NaN stays NaN.
+func Float16FromFloat32(f float32) Float16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int32(b>>23) & 0xff
+	mant := b & 0x7fffff
+
+	if exp == 0xff {
+		if mant != 0 {
+			return Float16(sign | 0x7e00 | uint16(mant>>13))
+		}
+		return Float16(sign | 0x7c00)
+	}
+
+	e := exp - 127 + 15
+	if e >= 0x1f {
+		return Float16(sign | 0x7c00)
+	}
+	if e <= 0 {
+		if e < -10 {
+			return Float16(sign)
+		}
+		// subnormal: shift the implicit leading one into the mantissa
+		mant |= 0x800000
+		shift := uint32(14 - e)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		halfway := uint32(1) << (shift - 1)
+		if rem > halfway || (rem == halfway && half&1 == 1) {
+			half++
+		}
+		return Float16(sign | uint16(half))
+	}
+
+	half := uint32(e)<<10 | mant>>13
+	rem := mant & 0x1fff
+	if rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		// a carry out of the mantissa bumps the exponent, up to Inf
+		half++
+	}
+	return Float16(sign | uint16(half))
+}
+
+// Float32 converts h exactly to float32.
+func (h Float16) Float32() float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+	switch {
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case exp == 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		e := uint32(127 - 15 + 1)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		mant &= 0x3ff
+		return math.Float32frombits(sign | e<<23 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+func (h Float16) IsNaN() bool {
+	return h&0x7c00 == 0x7c00 && h&0x3ff != 0
+}
+
+// BFloat16FromFloat32 rounds f to bfloat16, ties to even. NaN stays NaN.
+func BFloat16FromFloat32(f float32) BFloat16 {
+	b := math.Float32bits(f)
+	if b&0x7f800000 == 0x7f800000 && b&0x7fffff != 0 {
+		return BFloat16(b>>16 | 0x40)
+	}
+	b += 0x7fff + (b>>16)&1
+	return BFloat16(b >> 16)
+}
+
+// Float32 converts b exactly to float32.
+func (b BFloat16) Float32() float32 {
+	return math.Float32frombits(uint32(b) << 16)
+}
+
+func (b BFloat16) IsNaN() bool {
+	return b&0x7f80 == 0x7f80 && b&0x7f != 0
+}
+
+func F32ToF16(f32 []float32) []Float16 {
+	ret := make([]Float16, len(f32))
+	for i := range f32 {
+		ret[i] = Float16FromFloat32(f32[i])
+	}
+	return ret
+}
+
+func F16ToF32(f16 []Float16) []float32 {
+	ret := make([]float32, len(f16))
+	for i := range f16 {
+		ret[i] = f16[i].Float32()
+	}
+	return ret
+}
+
+func F32ToBF16(f32 []float32) []BFloat16 {
+	ret := make([]BFloat16, len(f32))
+	for i := range f32 {
+		ret[i] = BFloat16FromFloat32(f32[i])
+	}
+	return ret
+}
+
+func BF16ToF32(bf16 []BFloat16) []float32 {
+	ret := make([]float32, len(bf16))
+	for i := range bf16 {
+		ret[i] = bf16[i].Float32()
+	}
+	return ret
+}
+
+// Half is the set of 16 bit floating point types. Go has no arithmetic
+// for them, so they are storage only: they live in a HalfTensor and are
+// widened to float32 for computing.
+type Half interface {
+	Float16 | BFloat16
+	Float32() float32
+}
+
+func halfFromFloat32[T Half](f float32) T {
+	var zero T
+	if _, ok := any(zero).(BFloat16); ok {
+		return T(BFloat16FromFloat32(f))
+	}
+	return T(Float16FromFloat32(f))
+}
+
+// HalfTensor holds contiguous fp16 or bf16 data, as read from model files
+// or fed to half precision models. It satisfies AnyTensor; AsTensor and
+// Float32 convert it for computing.
+type HalfTensor[T Half] struct {
+	data  []T
+	shape Shape
+}
+
+// HalfTensorFrom wraps data without copying it. A nil shape means a 1D
+// tensor.
+func HalfTensorFrom[T Half](data []T, shape Shape) (*HalfTensor[T], error) {
+	if shape == nil {
+		shape = Shape{len(data)}
+	}
+	if shape.Size() != len(data) {
+		return nil, fmt.Errorf("data len == %d , shape %v size == %d", len(data), shape, shape.Size())
+	}
+	return &HalfTensor[T]{data: data, shape: shape.Clone()}, nil
+}
+
+// ToHalfTensor rounds every element of t to T.
+func ToHalfTensor[T Half](t *Tensor[float32]) *HalfTensor[T] {
+	src := t.Data()
+	data := make([]T, len(src))
+	for i := range src {
+		data[i] = halfFromFloat32[T](src[i])
+	}
+	return &HalfTensor[T]{data: data, shape: t.Shape()}
+}
+
+// AsHalfTensor returns a as *HalfTensor[T], going through float32 when
+// the element type differs.
+func AsHalfTensor[T Half](a AnyTensor) (*HalfTensor[T], error) {
+	if t, ok := a.(*HalfTensor[T]); ok {
+		return t, nil
+	}
+	f, err := AsTensor[float32](a)
+	if err != nil {
+		return nil, err
+	}
+	return ToHalfTensor[T](f), nil
+}
+
+func (t *HalfTensor[T]) Shape() Shape {
+	return t.shape.Clone()
+}
+
+func (t *HalfTensor[T]) Size() int {
+	return t.shape.Size()
+}
+
+func (t *HalfTensor[T]) DataType() DataType {
+	var zero T
+	if _, ok := any(zero).(BFloat16); ok {
+		return DataTypeBFloat16
+	}
+	return DataTypeFloat16
+}
+
+// Data returns the elements in row-major order, sharing memory with t.
+func (t *HalfTensor[T]) Data() []T {
+	return t.data
+}
+
+// Reshape returns a tensor sharing the elements with a new shape, one
+// dimension may be -1.
+func (t *HalfTensor[T]) Reshape(reshape Shape) (*HalfTensor[T], error) {
+	shape, err := resolveReshape(t.shape, reshape)
+	if err != nil {
+		return nil, err
+	}
+	return &HalfTensor[T]{data: t.data, shape: shape}, nil
+}
+
+// Float32 widens every element exactly.
+func (t *HalfTensor[T]) Float32() *Tensor[float32] {
+	data := make([]float32, len(t.data))
+	for i := range t.data {
+		data[i] = t.data[i].Float32()
+	}
+	return MustTensorFrom(data, t.shape)
+}
+
+// Value exports the elements as nested []T slices.
+func (t *HalfTensor[T]) Value() *Value {
+	if len(t.shape) == 0 {
+		return InterfaceConvertValue(t.data[0])
+	}
+	return InterfaceConvertValue(buildNested(reflect.ValueOf(t.data), t.shape).Interface())
+}
+
+func (t *HalfTensor[T]) String() string {
+	return fmt.Sprintf("HalfTensor%v%v", t.shape, t.Float32().Nested())
+}
+
+// halfFromBits reinterprets stored bit patterns as T.
+func halfFromBits[T Half](bits *Tensor[uint16]) *HalfTensor[T] {
+	src := bits.Data()
+	data := make([]T, len(src))
+	for i := range src {
+		data[i] = T(src[i])
+	}
+	return &HalfTensor[T]{data: data, shape: bits.Shape()}
+}
+
+// TensorToF16 converts a float32 tensor for fp16 model inputs.
+func TensorToF16(t *Tensor[float32]) *HalfTensor[Float16] {
+	return ToHalfTensor[Float16](t)
+}
+
+// TensorF16ToF32 converts fp16 model outputs back to float32.
+func TensorF16ToF32(t *HalfTensor[Float16]) *Tensor[float32] {
+	return t.Float32()
+}
+
+func TensorToBF16(t *Tensor[float32]) *HalfTensor[BFloat16] {
+	return ToHalfTensor[BFloat16](t)
+}
+
+func TensorBF16ToF32(t *HalfTensor[BFloat16]) *Tensor[float32] {
+	return t.Float32()
+}
+
+// ImageMeanNormalizeF16RGBCHW is ImageMeanNormalizeF32RGBCHW emitting fp16.
+func ImageMeanNormalizeF16RGBCHW(imgData [][][]uint8, mean, norm []float32) [][][]Float16 {
+	f32 := ImageMeanNormalizeF32RGBCHW(imgData, mean, norm)
+	ret := make([][][]Float16, len(f32))
+	for c := range f32 {
+		ret[c] = make([][]Float16, len(f32[c]))
+		for i := range f32[c] {
+			ret[c][i] = F32ToF16(f32[c][i])
+		}
+	}
+	return ret
+}
+
+// ImageMeanNormalizeF16RGBHWC is ImageMeanNormalizeF32RGBHWC emitting fp16.
+func ImageMeanNormalizeF16RGBHWC(imgData [][][]uint8, mean, norm []float32) [][][]Float16 {
+	f32 := ImageMeanNormalizeF32RGBHWC(imgData, mean, norm)
+	ret := make([][][]Float16, len(f32))
+	for i := range f32 {
+		ret[i] = make([][]Float16, len(f32[i]))
+		for k := range f32[i] {
+			ret[i][k] = F32ToF16(f32[i][k])
+		}
+	}
+	return ret
+}
@@ -0,0 +1,127 @@
+package goincv
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestFloat16RoundTrip(t *testing.T) {
+	for h := 0; h < 1<<16; h++ {
+		x := Float16(h)
+		got := Float16FromFloat32(x.Float32())
+		if x.IsNaN() {
+			if !got.IsNaN() {
+				t.Fatalf("%#04x: NaN became %#04x", h, got)
+			}
+			continue
+		}
+		if got != x {
+			t.Fatalf("%#04x: round trip gave %#04x", h, got)
+		}
+	}
+}
+
+func TestFloat16FromFloat32(t *testing.T) {
+	cases := []struct {
+		f    float32
+		want Float16
+	}{
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{0.1, 0x2e66},
+		{65504, 0x7bff},                       // max half
+		{65519, 0x7bff},                       // just below the Inf rounding point
+		{65520, 0x7c00},                       // rounds to Inf
+		{float32(math.Inf(-1)), 0xfc00},       // -Inf
+		{float32(math.Ldexp(1, -14)), 0x0400}, // smallest normal
+		{float32(math.Ldexp(1, -24)), 0x0001}, // smallest subnormal
+		{float32(math.Ldexp(1, -25)), 0x0000}, // tie to even rounds down
+		{float32(math.Ldexp(1.5, -25)), 0x0001},
+		{float32(math.Ldexp(3, -25)), 0x0002}, // tie to even rounds up
+		{float32(math.Ldexp(1, -26)), 0x0000}, // underflows
+		{float32(math.Copysign(0, -1)), 0x8000},
+		{1 + float32(math.Ldexp(1, -11)), 0x3c00}, // tie, even mantissa kept
+		{1 + float32(math.Ldexp(3, -11)), 0x3c02}, // tie, odd mantissa rounds up
+	}
+	for _, c := range cases {
+		if got := Float16FromFloat32(c.f); got != c.want {
+			t.Errorf("Float16FromFloat32(%g) = %#04x, want %#04x", c.f, got, c.want)
+		}
+	}
+	if !Float16FromFloat32(float32(math.NaN())).IsNaN() {
+		t.Error("NaN was not kept")
+	}
+}
+
+func TestBFloat16FromFloat32(t *testing.T) {
+	cases := []struct {
+		f    float32
+		want BFloat16
+	}{
+		{1, 0x3f80},
+		{-1, 0xbf80},
+		{1.00390625, 0x3f80}, // tie, even mantissa kept
+		{1.01171875, 0x3f82}, // tie, odd mantissa rounds up
+		{float32(math.MaxFloat32), 0x7f80},
+		{float32(math.Inf(1)), 0x7f80},
+	}
+	for _, c := range cases {
+		if got := BFloat16FromFloat32(c.f); got != c.want {
+			t.Errorf("BFloat16FromFloat32(%g) = %#04x, want %#04x", c.f, got, c.want)
+		}
+	}
+	if nan := BFloat16FromFloat32(math.Float32frombits(0x7f800001)); !nan.IsNaN() {
+		t.Errorf("NaN with low payload became %#04x", nan)
+	}
+}
+
+func TestHalfTensorConversion(t *testing.T) {
+	src := MustTensorFrom([]float32{0.5, 1.5, 2}, nil)
+	h, err := AsHalfTensor[Float16](src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Float16{0x3800, 0x3e00, 0x4000}
+	for i, v := range h.Data() {
+		if v != want[i] {
+			t.Fatalf("element %d = %#04x, want %#04x", i, v, want[i])
+		}
+	}
+	back, err := AsTensor[float32](h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range back.Data() {
+		if v != src.At(i) {
+			t.Fatalf("element %d = %v, want %v", i, v, src.At(i))
+		}
+	}
+	b, err := AsHalfTensor[BFloat16](h)
+	if err != nil || b.DataType() != DataTypeBFloat16 || b.Float32().At(1) != 1.5 {
+		t.Fatal(b, err)
+	}
+	r, err := h.Reshape(Shape{-1, 1})
+	if err != nil || !r.Shape().Equal(Shape{3, 1}) {
+		t.Fatal(r, err)
+	}
+}
+
+func TestHalfTensorNpz(t *testing.T) {
+	h := TensorToF16(MustTensorFrom([]float32{1, -2, 3, 0.25}, Shape{2, 2}))
+	buf := bytes.NewBuffer(nil)
+	if err := WriteNpz(buf, map[string]AnyTensor{"h": h}, false); err != nil {
+		t.Fatal(err)
+	}
+	arrays, err := ReadNpz(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := arrays["h"].(*HalfTensor[Float16])
+	if !ok {
+		t.Fatalf("got %T", arrays["h"])
+	}
+	if !got.Shape().Equal(Shape{2, 2}) || got.Float32().At(1, 1) != 0.25 {
+		t.Fatal(got)
+	}
+}
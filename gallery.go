@@ -0,0 +1,361 @@
+package goincv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+)
+
+// GalleryMetric is how a Gallery compares embeddings.
+type GalleryMetric int
+
+const (
+	MetricCosine    GalleryMetric = iota // cosine similarity, higher is closer
+	MetricEuclidean                      // euclidean distance, lower is closer
+)
+
+func (m GalleryMetric) String() string {
+	if m == MetricEuclidean {
+		return "euclidean"
+	}
+	return "cosine"
+}
+
+// GalleryMatch is one identity found by Gallery.Search.
+type GalleryMatch struct {
+	ID    string
+	Score float32 // similarity for MetricCosine, distance for MetricEuclidean
+	Index int     // the embedding of the identity that matched best
+}
+
+// Gallery holds the reference embeddings of known identities, e.g. face
+// embeddings of aligned crops, and finds the identities closest to a
+// query. An identity may have several embeddings and is scored by the best
+// of them. It is safe for concurrent use.
+type Gallery struct {
+	Metric    GalleryMetric
+	Threshold float32 // minimum similarity or maximum distance accepted by Identify
+	Normalize bool    // L2 normalise embeddings when added and queried
+
+	mu    sync.RWMutex
+	dim   int
+	items map[string][]galleryEntry
+}
+
+type galleryEntry struct {
+	vec  []float32
+	norm float64
+}
+
+// NewGallery returns an empty gallery normalising its embeddings, with a
+// threshold suited to ArcFace style models.
+func NewGallery(metric GalleryMetric) *Gallery {
+	g := &Gallery{Metric: metric, Threshold: 0.4, Normalize: true}
+	if metric == MetricEuclidean {
+		// √(2-2·0.4) for unit vectors
+		g.Threshold = 1.1
+	}
+	return g
+}
+
+// Dim returns the embedding length, 0 while the gallery is empty.
+func (g *Gallery) Dim() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.dim
+}
+
+// Len returns the number of identities.
+func (g *Gallery) Len() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.items)
+}
+
+// Identities returns the sorted identity names.
+func (g *Gallery) Identities() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	ret := make([]string, 0, len(g.items))
+	for id := range g.items {
+		ret = append(ret, id)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Embeddings returns a copy of the stored embeddings of id.
+func (g *Gallery) Embeddings(id string) [][]float32 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	var ret [][]float32
+	for _, e := range g.items[id] {
+		ret = append(ret, append([]float32(nil), e.vec...))
+	}
+	return ret
+}
+
+// Add stores embeddings for id, creating the identity if needed. All
+// embeddings of a gallery must have the same length.
+func (g *Gallery) Add(id string, embeddings ...[]float32) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	dim := g.dim
+	entries := make([]galleryEntry, 0, len(embeddings))
+	for i, v := range embeddings {
+		if dim == 0 {
+			dim = len(v)
+		}
+		if len(v) == 0 || len(v) != dim {
+			return fmt.Errorf("embedding %d has length %d, gallery uses %d", i, len(v), dim)
+		}
+		e, err := g.entry(v)
+		if err != nil {
+			return fmt.Errorf("embedding %d: %w", i, err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if g.items == nil {
+		g.items = map[string][]galleryEntry{}
+	}
+	g.dim = dim
+	g.items[id] = append(g.items[id], entries...)
+	return nil
+}
+
+// AddBox stores the EmbeddingKey extension of a detection for id.
+func (g *Gallery) AddBox(id string, b Box) error {
+	e, ok := b.Extension[EmbeddingKey].([]float32)
+	if !ok {
+		return errors.New("box has no embedding")
+	}
+	return g.Add(id, e)
+}
+
+// Remove deletes an identity and reports whether it existed.
+func (g *Gallery) Remove(id string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.items[id]
+	delete(g.items, id)
+	if len(g.items) == 0 {
+		g.dim = 0
+	}
+	return ok
+}
+
+// RemoveEmbedding deletes the i-th embedding of id, and the identity with
+// its last one.
+func (g *Gallery) RemoveEmbedding(id string, i int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	list := g.items[id]
+	if i < 0 || i >= len(list) {
+		return fmt.Errorf("identity %q has no embedding %d", id, i)
+	}
+	list = append(list[:i:i], list[i+1:]...)
+	if len(list) > 0 {
+		g.items[id] = list
+		return nil
+	}
+	delete(g.items, id)
+	if len(g.items) == 0 {
+		g.dim = 0
+	}
+	return nil
+}
+
+// Reset removes every identity.
+func (g *Gallery) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.items = nil
+	g.dim = 0
+}
+
+func (g *Gallery) entry(v []float32) (galleryEntry, error) {
+	s := 0.0
+	for _, x := range v {
+		s += float64(x) * float64(x)
+	}
+	if math.IsNaN(s) || math.IsInf(s, 0) {
+		return galleryEntry{}, errors.New("embedding is not finite")
+	}
+	if s == 0 {
+		return galleryEntry{}, errors.New("embedding is zero")
+	}
+	if g.Normalize {
+		return galleryEntry{vec: normalizeF32(v), norm: 1}, nil
+	}
+	return galleryEntry{vec: append([]float32(nil), v...), norm: math.Sqrt(s)}, nil
+}
+
+// Search returns the k identities closest to query, best first. k <= 0
+// returns every identity.
+func (g *Gallery) Search(query []float32, k int) ([]GalleryMatch, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.search(query, k)
+}
+
+// search is Search with g.mu held.
+func (g *Gallery) search(query []float32, k int) ([]GalleryMatch, error) {
+	if len(g.items) == 0 {
+		return nil, nil
+	}
+	if len(query) != g.dim {
+		return nil, fmt.Errorf("query has length %d, gallery uses %d", len(query), g.dim)
+	}
+	q, err := g.entry(query)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]GalleryMatch, 0, len(g.items))
+	for id, list := range g.items {
+		m := GalleryMatch{ID: id}
+		for i, e := range list {
+			s := g.score(q, e)
+			if i == 0 || g.better(s, m.Score) {
+				m.Score, m.Index = s, i
+			}
+		}
+		ret = append(ret, m)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Score != ret[j].Score {
+			return g.better(ret[i].Score, ret[j].Score)
+		}
+		return ret[i].ID < ret[j].ID
+	})
+	if k > 0 && k < len(ret) {
+		ret = ret[:k]
+	}
+	return ret, nil
+}
+
+// Identify returns the closest identity; ok is false when the gallery is
+// empty or the match does not pass Threshold, i.e. the face is unknown.
+func (g *Gallery) Identify(query []float32) (m GalleryMatch, ok bool, err error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	ret, err := g.search(query, 1)
+	if err != nil || len(ret) == 0 {
+		return m, false, err
+	}
+	m = ret[0]
+	if g.Metric == MetricEuclidean {
+		return m, m.Score <= g.Threshold, nil
+	}
+	return m, m.Score >= g.Threshold, nil
+}
+
+// IdentifyBox identifies the EmbeddingKey extension of a detection.
+func (g *Gallery) IdentifyBox(b Box) (GalleryMatch, bool, error) {
+	e, ok := b.Extension[EmbeddingKey].([]float32)
+	if !ok {
+		return GalleryMatch{}, false, errors.New("box has no embedding")
+	}
+	return g.Identify(e)
+}
+
+func (g *Gallery) score(q, e galleryEntry) float32 {
+	if g.Metric == MetricEuclidean {
+		s := 0.0
+		for i := range q.vec {
+			d := float64(q.vec[i]) - float64(e.vec[i])
+			s += d * d
+		}
+		return float32(math.Sqrt(s))
+	}
+	return float32(cosineF32(q.vec, e.vec) / (q.norm * e.norm))
+}
+
+func (g *Gallery) better(a, b float32) bool {
+	if g.Metric == MetricEuclidean {
+		return a < b
+	}
+	return a > b
+}
+
+// galleryFile is the JSON layout written by WriteGallery.
+type galleryFile struct {
+	Metric     string                 `json:"metric"`
+	Threshold  float32                `json:"threshold"`
+	Normalize  bool                   `json:"normalize"`
+	Dim        int                    `json:"dim"`
+	Identities map[string][][]float32 `json:"identities"`
+}
+
+// WriteGallery encodes the settings and embeddings of g as JSON.
+func WriteGallery(w io.Writer, g *Gallery) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	f := galleryFile{
+		Metric:     g.Metric.String(),
+		Threshold:  g.Threshold,
+		Normalize:  g.Normalize,
+		Dim:        g.dim,
+		Identities: make(map[string][][]float32, len(g.items)),
+	}
+	for id, list := range g.items {
+		vecs := make([][]float32, len(list))
+		for i, e := range list {
+			vecs[i] = e.vec
+		}
+		f.Identities[id] = vecs
+	}
+	return json.NewEncoder(w).Encode(&f)
+}
+
+// SaveGallery writes g to a JSON file.
+func SaveGallery(path string, g *Gallery) error {
+	return writeFileWith(path, func(w io.Writer) error {
+		return WriteGallery(w, g)
+	})
+}
+
+// ReadGallery decodes a gallery written by WriteGallery.
+func ReadGallery(r io.Reader) (*Gallery, error) {
+	var f galleryFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	g := &Gallery{Threshold: f.Threshold, Normalize: f.Normalize}
+	switch f.Metric {
+	case "cosine":
+		g.Metric = MetricCosine
+	case "euclidean":
+		g.Metric = MetricEuclidean
+	default:
+		return nil, fmt.Errorf("unknown gallery metric %q", f.Metric)
+	}
+	for id, vecs := range f.Identities {
+		for i, v := range vecs {
+			if len(v) != f.Dim {
+				return nil, fmt.Errorf("identity %q: embedding %d has length %d, gallery uses %d", id, i, len(v), f.Dim)
+			}
+		}
+		if err := g.Add(id, vecs...); err != nil {
+			return nil, fmt.Errorf("identity %q: %w", id, err)
+		}
+	}
+	return g, nil
+}
+
+// LoadGallery reads a gallery saved by SaveGallery.
+func LoadGallery(path string) (*Gallery, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadGallery(f)
+}
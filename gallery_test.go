@@ -0,0 +1,257 @@
+package goincv
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func testGallery(metric GalleryMetric) *Gallery {
+	g := NewGallery(metric)
+	g.Add("alice", []float32{1, 0, 0}, []float32{0.8, 0.6, 0})
+	g.Add("bob", []float32{0, 1, 0})
+	g.Add("carol", []float32{0, 0, 2})
+	return g
+}
+
+func TestGalleryAdd(t *testing.T) {
+	g := testGallery(MetricCosine)
+	if g.Len() != 3 || g.Dim() != 3 {
+		t.Fatalf("Len %d Dim %d", g.Len(), g.Dim())
+	}
+	ids := g.Identities()
+	if len(ids) != 3 || ids[0] != "alice" || ids[1] != "bob" || ids[2] != "carol" {
+		t.Errorf("Identities = %v", ids)
+	}
+	// Normalize stores unit vectors
+	if e := g.Embeddings("carol"); len(e) != 1 || e[0][2] != 1 {
+		t.Errorf("carol embeddings = %v", e)
+	}
+
+	bad := [][]float32{
+		{1, 0},
+		{},
+		{0, 0, 0},
+		{float32(math.NaN()), 0, 0},
+		{float32(math.Inf(1)), 0, 0},
+	}
+	for _, v := range bad {
+		if err := g.Add("dave", v); err == nil {
+			t.Errorf("Add(%v) gave no error", v)
+		}
+	}
+	// a bad embedding rejects the whole call
+	if err := g.Add("dave", []float32{1, 1, 1}, []float32{1}); err == nil || g.Len() != 3 {
+		t.Errorf("partial Add: err %v, %d identities", err, g.Len())
+	}
+	if err := g.AddBox("dave", Box{}); err == nil {
+		t.Error("AddBox without an embedding gave no error")
+	}
+	if err := g.AddBox("dave", Box{Extension: map[string]interface{}{EmbeddingKey: []float32{1, 1, 0}}}); err != nil || g.Len() != 4 {
+		t.Errorf("AddBox: err %v, %d identities", err, g.Len())
+	}
+}
+
+func TestGallerySearch(t *testing.T) {
+	query := []float32{0.9, 0.5, 0.1}
+	cases := []struct {
+		metric GalleryMetric
+		ids    []string
+		index  int // best embedding of alice
+	}{
+		{MetricCosine, []string{"alice", "bob", "carol"}, 1},
+		{MetricEuclidean, []string{"alice", "bob", "carol"}, 1},
+	}
+	for _, c := range cases {
+		g := testGallery(c.metric)
+		ret, err := g.Search(query, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ret) != len(c.ids) {
+			t.Fatalf("%v: %d matches", c.metric, len(ret))
+		}
+		for i, m := range ret {
+			if m.ID != c.ids[i] {
+				t.Errorf("%v: match %d is %s, want %s", c.metric, i, m.ID, c.ids[i])
+			}
+			if i > 0 && g.better(m.Score, ret[i-1].Score) {
+				t.Errorf("%v: match %d scores better than match %d", c.metric, i, i-1)
+			}
+		}
+		if ret[0].Index != c.index {
+			t.Errorf("%v: alice matched embedding %d, want %d", c.metric, ret[0].Index, c.index)
+		}
+		if top, _ := g.Search(query, 2); len(top) != 2 || top[0] != ret[0] || top[1] != ret[1] {
+			t.Errorf("%v: Search k=2 = %v", c.metric, top)
+		}
+		if _, err := g.Search([]float32{1, 0}, 1); err == nil {
+			t.Errorf("%v: short query gave no error", c.metric)
+		}
+	}
+
+	// cosine scores are the similarity to the normalised query
+	g := testGallery(MetricCosine)
+	ret, _ := g.Search([]float32{0, 3, 0}, 1)
+	if ret[0].ID != "bob" || math.Abs(float64(ret[0].Score-1)) > 1e-6 {
+		t.Errorf("exact match = %+v", ret[0])
+	}
+	if ret, err := (&Gallery{}).Search([]float32{1}, 1); err != nil || ret != nil {
+		t.Errorf("empty gallery: %v %v", ret, err)
+	}
+}
+
+func TestGalleryIdentify(t *testing.T) {
+	cases := []struct {
+		metric GalleryMetric
+		query  []float32
+		id     string
+		ok     bool
+	}{
+		{MetricCosine, []float32{0.1, 1, 0}, "bob", true},
+		{MetricCosine, []float32{1, 1, 1}, "alice", true},
+		{MetricCosine, []float32{-1, -1, -1}, "", false},
+		{MetricEuclidean, []float32{0.1, 1, 0}, "bob", true},
+		{MetricEuclidean, []float32{-1, -1, -1}, "", false},
+	}
+	for _, c := range cases {
+		g := testGallery(c.metric)
+		m, ok, err := g.Identify(c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != c.ok || (ok && m.ID != c.id) {
+			t.Errorf("%v %v: got %+v %v, want %s %v", c.metric, c.query, m, ok, c.id, c.ok)
+		}
+	}
+
+	// the threshold decides between a match and an unknown face
+	g := testGallery(MetricCosine)
+	query := []float32{1, 1, 0}
+	m, _, _ := g.Identify(query)
+	g.Threshold = m.Score
+	if _, ok, _ := g.Identify(query); !ok {
+		t.Error("score equal to Threshold was rejected")
+	}
+	g.Threshold = m.Score + 1e-4
+	if _, ok, _ := g.Identify(query); ok {
+		t.Error("score below Threshold was accepted")
+	}
+
+	e := NewGallery(MetricEuclidean)
+	e.Normalize = false
+	e.Add("x", []float32{1, 0})
+	e.Threshold = 5
+	if m, ok, _ := e.Identify([]float32{4, 4}); !ok || m.Score != 5 {
+		t.Errorf("distance 5 at Threshold 5: %+v %v", m, ok)
+	}
+	e.Threshold = 4.9
+	if _, ok, _ := e.Identify([]float32{4, 4}); ok {
+		t.Error("distance above Threshold was accepted")
+	}
+
+	if _, ok, err := (&Gallery{}).Identify([]float32{1}); ok || err != nil {
+		t.Errorf("empty gallery: %v %v", ok, err)
+	}
+	if _, _, err := g.IdentifyBox(Box{}); err == nil {
+		t.Error("IdentifyBox without an embedding gave no error")
+	}
+}
+
+func TestGalleryRemove(t *testing.T) {
+	g := testGallery(MetricCosine)
+	if err := g.RemoveEmbedding("alice", 2); err == nil {
+		t.Error("out of range embedding gave no error")
+	}
+	if err := g.RemoveEmbedding("nobody", 0); err == nil {
+		t.Error("unknown identity gave no error")
+	}
+	if err := g.RemoveEmbedding("alice", 0); err != nil {
+		t.Fatal(err)
+	}
+	if e := g.Embeddings("alice"); len(e) != 1 || e[0][1] != 0.6 {
+		t.Errorf("alice keeps %v", e)
+	}
+	if err := g.RemoveEmbedding("alice", 0); err != nil {
+		t.Fatal(err)
+	}
+	if g.Len() != 2 || g.Embeddings("alice") != nil {
+		t.Errorf("alice not removed with the last embedding: %v", g.Identities())
+	}
+	if !g.Remove("bob") || g.Remove("bob") {
+		t.Error("Remove should report whether the identity existed")
+	}
+	g.RemoveEmbedding("carol", 0)
+	// an empty gallery accepts a new embedding length
+	if g.Len() != 0 || g.Dim() != 0 {
+		t.Fatalf("Len %d Dim %d after removing everything", g.Len(), g.Dim())
+	}
+	if err := g.Add("eve", []float32{1, 2}); err != nil || g.Dim() != 2 {
+		t.Errorf("Add after emptying: err %v, Dim %d", err, g.Dim())
+	}
+	g.Reset()
+	if g.Len() != 0 || g.Dim() != 0 {
+		t.Errorf("Reset left Len %d Dim %d", g.Len(), g.Dim())
+	}
+}
+
+func TestGalleryRoundTrip(t *testing.T) {
+	for _, metric := range []GalleryMetric{MetricCosine, MetricEuclidean} {
+		g := testGallery(metric)
+		g.Threshold = 0.7
+		var buf bytes.Buffer
+		if err := WriteGallery(&buf, g); err != nil {
+			t.Fatal(err)
+		}
+		r, err := ReadGallery(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Metric != g.Metric || r.Threshold != g.Threshold || r.Normalize != g.Normalize || r.Dim() != g.Dim() {
+			t.Fatalf("%v: settings %v %v %v %d", metric, r.Metric, r.Threshold, r.Normalize, r.Dim())
+		}
+		for _, id := range g.Identities() {
+			want, got := g.Embeddings(id), r.Embeddings(id)
+			if len(got) != len(want) {
+				t.Fatalf("%v %s: %d embeddings, want %d", metric, id, len(got), len(want))
+			}
+			for i := range want {
+				for j := range want[i] {
+					if got[i][j] != want[i][j] {
+						t.Fatalf("%v %s: embedding %d = %v, want %v", metric, id, i, got[i], want[i])
+					}
+				}
+			}
+		}
+		query := []float32{0.3, 0.9, 0.2}
+		a, _ := g.Search(query, 0)
+		b, _ := r.Search(query, 0)
+		for i := range a {
+			if a[i] != b[i] {
+				t.Errorf("%v: match %d %+v after reading, want %+v", metric, i, b[i], a[i])
+			}
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "gallery.json")
+	g := testGallery(MetricCosine)
+	if err := SaveGallery(path, g); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := LoadGallery(path); err != nil || r.Len() != 3 {
+		t.Fatalf("LoadGallery: %v", err)
+	}
+
+	bad := []string{
+		`{"metric":"manhattan","dim":1,"identities":{}}`,
+		`{"metric":"cosine","dim":2,"identities":{"a":[[1]]}}`,
+		`{"metric":"cosine","dim":2,"identities":{"a":[[0,0]]}}`,
+		`{"metric":`,
+	}
+	for _, s := range bad {
+		if _, err := ReadGallery(bytes.NewBufferString(s)); err == nil {
+			t.Errorf("ReadGallery(%s) gave no error", s)
+		}
+	}
+}
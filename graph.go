@@ -0,0 +1,195 @@
+package goincv
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Attrs holds node attributes. Values are int, float32, string, []int,
+// []float32, []string or AnyTensor.
+type Attrs map[string]interface{}
+
+func (a Attrs) Int(name string, def int) int {
+	if v, ok := a[name].(int); ok {
+		return v
+	}
+	return def
+}
+
+func (a Attrs) Float(name string, def float32) float32 {
+	if v, ok := a[name].(float32); ok {
+		return v
+	}
+	return def
+}
+
+func (a Attrs) String(name string, def string) string {
+	if v, ok := a[name].(string); ok {
+		return v
+	}
+	return def
+}
+
+func (a Attrs) Ints(name string) []int {
+	v, _ := a[name].([]int)
+	return v
+}
+
+func (a Attrs) Floats(name string) []float32 {
+	v, _ := a[name].([]float32)
+	return v
+}
+
+func (a Attrs) Tensor(name string) AnyTensor {
+	v, _ := a[name].(AnyTensor)
+	return v
+}
+
+// Node is one operator application. An empty input name stands for an
+// omitted optional input and is passed to the operator as nil.
+type Node struct {
+	Name    string
+	Op      string
+	Inputs  []string
+	Outputs []string
+	Attrs   Attrs
+	Opset   int // version of the default operator set, 0 means the latest
+}
+
+// Graph is a model in ONNX style: named inputs, constant initializers and
+// nodes listed in execution order.
+type Graph struct {
+	Inputs       []TensorInfo
+	Outputs      []TensorInfo
+	Initializers map[string]AnyTensor
+	Nodes        []Node
+}
+
+// OpFunc runs one node. It must not modify its inputs.
+type OpFunc func(node *Node, inputs []AnyTensor) ([]AnyTensor, error)
+
+var operators = struct {
+	sync.RWMutex
+	m map[string]OpFunc
+}{m: map[string]OpFunc{}}
+
+// RegisterOp adds or replaces an operator of the reference executor.
+func RegisterOp(op string, fn OpFunc) {
+	operators.Lock()
+	defer operators.Unlock()
+	operators.m[op] = fn
+}
+
+func lookupOp(op string) (OpFunc, bool) {
+	operators.RLock()
+	defer operators.RUnlock()
+	fn, ok := operators.m[op]
+	return fn, ok
+}
+
+// GraphSession is a pure Go Session that interprets a Graph. It is meant
+// for small models and for testing pipelines without a native runtime.
+type GraphSession struct {
+	graph *Graph
+	ops   []OpFunc
+}
+
+// NewGraphSession checks that every operator is known and every value is
+// produced before it is used.
+func NewGraphSession(g *Graph) (*GraphSession, error) {
+	if g == nil {
+		return nil, errors.New("graph is nil")
+	}
+	known := map[string]bool{"": true}
+	for _, in := range g.Inputs {
+		known[in.Name] = true
+	}
+	for name := range g.Initializers {
+		known[name] = true
+	}
+	s := &GraphSession{graph: g}
+	for i := range g.Nodes {
+		n := &g.Nodes[i]
+		fn, ok := lookupOp(n.Op)
+		if !ok {
+			return nil, fmt.Errorf("node %s: unsupported op %s", n.Name, n.Op)
+		}
+		for _, in := range n.Inputs {
+			if !known[in] {
+				return nil, fmt.Errorf("node %s: input %s is not defined", n.Name, in)
+			}
+		}
+		for _, out := range n.Outputs {
+			known[out] = true
+		}
+		s.ops = append(s.ops, fn)
+	}
+	for _, out := range g.Outputs {
+		if !known[out.Name] {
+			return nil, fmt.Errorf("output %s is not produced", out.Name)
+		}
+	}
+	return s, nil
+}
+
+func (s *GraphSession) Inputs() []TensorInfo {
+	return s.graph.Inputs
+}
+
+func (s *GraphSession) Outputs() []TensorInfo {
+	return s.graph.Outputs
+}
+
+// Run executes the graph. Intermediate values live only for this call, so
+// Run can be used concurrently.
+func (s *GraphSession) Run(inputs map[string]AnyTensor) (map[string]AnyTensor, error) {
+	values := map[string]AnyTensor{}
+	for name, t := range s.graph.Initializers {
+		values[name] = t
+	}
+	for _, info := range s.graph.Inputs {
+		t, ok := inputs[info.Name]
+		if !ok {
+			// an input with an initializer is optional
+			if _, ok := values[info.Name]; ok {
+				continue
+			}
+		}
+		if err := info.Match(t); err != nil {
+			return nil, err
+		}
+		values[info.Name] = t
+	}
+	for i := range s.graph.Nodes {
+		n := &s.graph.Nodes[i]
+		args := make([]AnyTensor, len(n.Inputs))
+		for k, name := range n.Inputs {
+			if name != "" {
+				args[k] = values[name]
+			}
+		}
+		outs, err := s.ops[i](n, args)
+		if err != nil {
+			return nil, fmt.Errorf("node %s (%s): %v", n.Name, n.Op, err)
+		}
+		for k, name := range n.Outputs {
+			if k < len(outs) && name != "" {
+				values[name] = outs[k]
+			}
+		}
+	}
+	ret := map[string]AnyTensor{}
+	for _, out := range s.graph.Outputs {
+		t, ok := values[out.Name]
+		if !ok {
+			return nil, fmt.Errorf("output %s was not produced", out.Name)
+		}
+		ret[out.Name] = t
+	}
+	return ret, nil
+}
+
+func (s *GraphSession) Close() error {
+	return nil
+}
@@ -0,0 +1,357 @@
+package goincv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Operators of the reference executor. They follow the ONNX definitions;
+// arithmetic runs in float32 unless every operand is an integer tensor, so
+// shape computations stay exact.
+func init() {
+	RegisterOp("Identity", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		return in[:1], nil
+	})
+	for _, op := range []string{"Add", "Sub", "Mul", "Div", "Pow", "Max", "Min"} {
+		op := op
+		RegisterOp(op, func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+			return opArith(op, in)
+		})
+	}
+	RegisterOp("Relu", unaryOp(func(x float32) float32 {
+		if x < 0 {
+			return 0
+		}
+		return x
+	}))
+	RegisterOp("LeakyRelu", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		alpha := n.Attrs.Float("alpha", 0.01)
+		return unaryOp(func(x float32) float32 {
+			if x < 0 {
+				return alpha * x
+			}
+			return x
+		})(n, in)
+	})
+	RegisterOp("Sigmoid", unaryOp(func(x float32) float32 { return float32(sigmoid(float64(x))) }))
+	RegisterOp("Tanh", unaryOp(func(x float32) float32 { return float32(math.Tanh(float64(x))) }))
+	RegisterOp("Exp", unaryOp(func(x float32) float32 { return float32(math.Exp(float64(x))) }))
+	RegisterOp("Sqrt", unaryOp(func(x float32) float32 { return float32(math.Sqrt(float64(x))) }))
+	RegisterOp("Neg", unaryOp(func(x float32) float32 { return -x }))
+	RegisterOp("Softmax", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		x, err := opFloat(in, 0)
+		if err != nil {
+			return nil, err
+		}
+		shape := x.Shape()
+		if n.Opset > 0 && n.Opset < 13 {
+			// before opset 13 the input is flattened to 2D at axis, which
+			// defaults to 1
+			axis, err := normalizeAxis(n.Attrs.Int("axis", 1), len(shape))
+			if err != nil {
+				return nil, err
+			}
+			flat, err := x.Reshape(Shape{shape[:axis].Size(), shape[axis:].Size()})
+			if err != nil {
+				return nil, err
+			}
+			ret, err := flat.Softmax(1).Reshape(shape)
+			return []AnyTensor{ret}, err
+		}
+		axis, err := normalizeAxis(n.Attrs.Int("axis", -1), len(shape))
+		if err != nil {
+			return nil, err
+		}
+		return []AnyTensor{x.Softmax(axis)}, nil
+	})
+	RegisterOp("Reshape", opReshape)
+	RegisterOp("Flatten", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		x, err := opShaped(in, 0)
+		if err != nil {
+			return nil, err
+		}
+		shape := x.(AnyTensor).Shape()
+		axis := n.Attrs.Int("axis", 1)
+		if axis < 0 {
+			axis += len(shape)
+		}
+		if axis < 0 || axis > len(shape) {
+			return nil, fmt.Errorf("flatten axis %d out of range for %v", n.Attrs.Int("axis", 1), shape)
+		}
+		outer := Shape(shape[:axis]).Size()
+		ret, err := x.reshapeAny(Shape{outer, -1})
+		return []AnyTensor{ret}, err
+	})
+	RegisterOp("Transpose", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		x, err := opShaped(in, 0)
+		if err != nil {
+			return nil, err
+		}
+		perm := n.Attrs.Ints("perm")
+		if perm == nil {
+			nd := len(x.(AnyTensor).Shape())
+			for i := nd - 1; i >= 0; i-- {
+				perm = append(perm, i)
+			}
+		}
+		ret, err := x.permuteAny(perm)
+		return []AnyTensor{ret}, err
+	})
+	RegisterOp("Unsqueeze", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		x, err := opShaped(in, 0)
+		if err != nil {
+			return nil, err
+		}
+		axes, err := opAxes(n, in, 1)
+		if err != nil {
+			return nil, err
+		}
+		shape := x.(AnyTensor).Shape().Clone()
+		nd := len(shape) + len(axes)
+		norm := make([]int, len(axes))
+		for i, a := range axes {
+			if norm[i], err = normalizeAxis(a, nd); err != nil {
+				return nil, err
+			}
+		}
+		// axes index the output, so insert from the lowest one up
+		sort.Ints(norm)
+		for _, a := range norm {
+			shape = append(shape[:a], append(Shape{1}, shape[a:]...)...)
+		}
+		ret, err := x.reshapeAny(shape)
+		return []AnyTensor{ret}, err
+	})
+	RegisterOp("Squeeze", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		x, err := opShaped(in, 0)
+		if err != nil {
+			return nil, err
+		}
+		axes, err := opAxes(n, in, 1)
+		if err != nil {
+			return nil, err
+		}
+		ret, err := x.squeezeAny(axes)
+		return []AnyTensor{ret}, err
+	})
+	RegisterOp("Concat", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		x, err := opShaped(in, 0)
+		if err != nil {
+			return nil, err
+		}
+		for i := 1; i < len(in); i++ {
+			if _, err := opInput(in, i); err != nil {
+				return nil, err
+			}
+		}
+		ret, err := x.concatAny(n.Attrs.Int("axis", 0), in[1:])
+		return []AnyTensor{ret}, err
+	})
+	RegisterOp("Shape", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		if len(in) == 0 || in[0] == nil {
+			return nil, fmt.Errorf("missing input 0")
+		}
+		shape := in[0].Shape()
+		ret := NewTensor[int64](Shape{len(shape)})
+		for i, d := range shape {
+			ret.data[i] = int64(d)
+		}
+		return []AnyTensor{ret}, nil
+	})
+}
+
+// shaped is implemented by every *Tensor[T] so layout operators can keep
+// the element type without a type switch.
+type shaped interface {
+	reshapeAny(shape Shape) (AnyTensor, error)
+	permuteAny(axes []int) (AnyTensor, error)
+	squeezeAny(axes []int) (AnyTensor, error)
+	concatAny(axis int, others []AnyTensor) (AnyTensor, error)
+	gatherAny(axis int, indices AnyTensor) (AnyTensor, error)
+	sliceAny(idx []SliceIndex) (AnyTensor, error)
+}
+
+func (t *Tensor[T]) reshapeAny(shape Shape) (AnyTensor, error) {
+	ret, err := t.Reshape(shape)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (t *Tensor[T]) permuteAny(axes []int) (AnyTensor, error) {
+	ret, err := t.Permute(axes...)
+	if err != nil {
+		return nil, err
+	}
+	return ret.Contiguous(), nil
+}
+
+func (t *Tensor[T]) squeezeAny(axes []int) (AnyTensor, error) {
+	ret, err := t.Squeeze(axes...)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (t *Tensor[T]) concatAny(axis int, others []AnyTensor) (AnyTensor, error) {
+	ts := []*Tensor[T]{t}
+	for _, o := range others {
+		ot, err := AsTensor[T](o)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, ot)
+	}
+	ret, err := ConcatTensors(axis, ts...)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func opInput(in []AnyTensor, i int) (AnyTensor, error) {
+	if i >= len(in) || in[i] == nil {
+		return nil, fmt.Errorf("missing input %d", i)
+	}
+	return in[i], nil
+}
+
+func opFloat(in []AnyTensor, i int) (*Tensor[float32], error) {
+	t, err := opInput(in, i)
+	if err != nil {
+		return nil, err
+	}
+	return AsTensor[float32](t)
+}
+
+func opShaped(in []AnyTensor, i int) (shaped, error) {
+	t, err := opInput(in, i)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := t.(shaped)
+	if !ok {
+		return nil, fmt.Errorf("input %d is not a tensor", i)
+	}
+	return s, nil
+}
+
+// opInts reads a 1-D integer input such as a shape or axes.
+func opInts(in []AnyTensor, i int) ([]int, error) {
+	t, err := opInput(in, i)
+	if err != nil {
+		return nil, err
+	}
+	it, err := AsTensor[int](t)
+	if err != nil {
+		return nil, err
+	}
+	return it.Data(), nil
+}
+
+// opAxes reads axes from the input of newer opsets or the attribute of
+// older ones.
+func opAxes(n *Node, in []AnyTensor, i int) ([]int, error) {
+	if i < len(in) && in[i] != nil {
+		return opInts(in, i)
+	}
+	return n.Attrs.Ints("axes"), nil
+}
+
+func isIntTensor(t AnyTensor) bool {
+	switch t.DataType() {
+	case DataTypeInt64, DataTypeInt32, DataTypeInt16, DataTypeInt8, DataTypeInt:
+		return true
+	}
+	return false
+}
+
+func unaryOp(fn func(x float32) float32) OpFunc {
+	return func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		x, err := opFloat(in, 0)
+		if err != nil {
+			return nil, err
+		}
+		return []AnyTensor{x.Map(fn)}, nil
+	}
+}
+
+func opArith(op string, in []AnyTensor) ([]AnyTensor, error) {
+	a, err := opInput(in, 0)
+	if err != nil {
+		return nil, err
+	}
+	b, err := opInput(in, 1)
+	if err != nil {
+		return nil, err
+	}
+	if isIntTensor(a) && isIntTensor(b) {
+		x, _ := AsTensor[int64](a)
+		y, _ := AsTensor[int64](b)
+		if op == "Div" {
+			for _, v := range y.Data() {
+				if v == 0 {
+					return nil, errors.New("integer division by zero")
+				}
+			}
+		}
+		ret, err := arith(op, x, y)
+		return []AnyTensor{ret}, err
+	}
+	x, err := AsTensor[float32](a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := AsTensor[float32](b)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := arith(op, x, y)
+	return []AnyTensor{ret}, err
+}
+
+func arith[T Number](op string, x, y *Tensor[T]) (*Tensor[T], error) {
+	switch op {
+	case "Add":
+		return x.Add(y)
+	case "Sub":
+		return x.Sub(y)
+	case "Mul":
+		return x.Mul(y)
+	case "Div":
+		return x.Div(y)
+	case "Pow":
+		return x.Pow(y)
+	case "Max":
+		return x.Maximum(y)
+	case "Min":
+		return x.Minimum(y)
+	}
+	return nil, fmt.Errorf("unknown op %s", op)
+}
+
+func opReshape(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+	x, err := opShaped(in, 0)
+	if err != nil {
+		return nil, err
+	}
+	dims, err := opInts(in, 1)
+	if err != nil {
+		return nil, err
+	}
+	src := x.(AnyTensor).Shape()
+	shape := make(Shape, len(dims))
+	for i, d := range dims {
+		// 0 copies the input dimension unless allowzero is set
+		if d == 0 && n.Attrs.Int("allowzero", 0) == 0 && i < len(src) {
+			d = src[i]
+		}
+		shape[i] = d
+	}
+	ret, err := x.reshapeAny(shape)
+	return []AnyTensor{ret}, err
+}
@@ -0,0 +1,74 @@
+package goincv
+
+import (
+	"math"
+	"testing"
+)
+
+func runOp(t *testing.T, n *Node, in ...AnyTensor) ([]AnyTensor, error) {
+	t.Helper()
+	fn, ok := lookupOp(n.Op)
+	if !ok {
+		t.Fatalf("op %s is not registered", n.Op)
+	}
+	return fn(n, in)
+}
+
+func TestOpIntegerDivByZero(t *testing.T) {
+	a := MustTensorFrom([]int64{4, 6}, nil)
+	out, err := runOp(t, &Node{Op: "Div"}, a, MustTensorFrom([]int64{2, 3}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out[0].(*Tensor[int64]).Data(); got[0] != 2 || got[1] != 2 {
+		t.Errorf("4,6 / 2,3 = %v", got)
+	}
+	if _, err := runOp(t, &Node{Op: "Div"}, a, MustTensorFrom([]int64{2, 0}, nil)); err == nil {
+		t.Error("division by zero was accepted")
+	}
+}
+
+func TestOpConcatMissingInput(t *testing.T) {
+	a := MustTensorFrom([]float32{1, 2}, nil)
+	if _, err := runOp(t, &Node{Op: "Concat", Attrs: Attrs{"axis": 0}}, a, nil); err == nil {
+		t.Error("nil input was accepted")
+	}
+}
+
+func TestOpSoftmaxOpset(t *testing.T) {
+	x := MustTensorFrom([]float32{0, 0, 0, 0, 0, 0, 0, 0}, Shape{2, 2, 2})
+	cases := []struct {
+		opset int
+		attrs Attrs
+		want  float32
+	}{
+		// opset 13 normalises the last axis only
+		{13, Attrs{}, 0.5},
+		{0, Attrs{}, 0.5},
+		// earlier opsets flatten to [2, 4] at the default axis 1
+		{11, Attrs{}, 0.25},
+		{11, Attrs{"axis": 2}, 0.5},
+		{11, Attrs{"axis": 0}, 0.125},
+	}
+	for _, c := range cases {
+		out, err := runOp(t, &Node{Op: "Softmax", Attrs: c.attrs, Opset: c.opset}, x)
+		if err != nil {
+			t.Errorf("opset %d %v: %v", c.opset, c.attrs, err)
+			continue
+		}
+		got := out[0].(*Tensor[float32])
+		if !got.Shape().Equal(x.Shape()) {
+			t.Errorf("opset %d %v: shape %v", c.opset, c.attrs, got.Shape())
+			continue
+		}
+		for _, v := range got.Data() {
+			if math.Abs(float64(v-c.want)) > 1e-6 {
+				t.Errorf("opset %d %v: got %v, want %v", c.opset, c.attrs, got.Data(), c.want)
+				break
+			}
+		}
+	}
+	if _, err := runOp(t, &Node{Op: "Softmax", Attrs: Attrs{"axis": 3}}, x); err == nil {
+		t.Error("axis 3 was accepted for 3 dims")
+	}
+}
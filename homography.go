@@ -0,0 +1,202 @@
+package goincv
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/wailovet/goincv/third/gonum.org/v1/gonum/mat"
+)
+
+// Homography is a row-major 3x3 projective transform.
+type Homography [9]float64
+
+// Homography returns the affine transform as a projective one.
+func (a Affine) Homography() Homography {
+	return Homography{a[0], a[1], a[2], a[3], a[4], a[5], 0, 0, 1}
+}
+
+// Apply maps a point. Points on the line at infinity come back as NaN.
+func (h Homography) Apply(x, y float32) (float32, float32) {
+	fx, fy := float64(x), float64(y)
+	w := h[6]*fx + h[7]*fy + h[8]
+	if w == 0 {
+		return float32(math.NaN()), float32(math.NaN())
+	}
+	return float32((h[0]*fx + h[1]*fy + h[2]) / w), float32((h[3]*fx + h[4]*fy + h[5]) / w)
+}
+
+// Invert returns the inverse transform; ok is false when h is singular.
+func (h Homography) Invert() (inv Homography, ok bool) {
+	var m mat.Dense
+	if err := m.Inverse(mat.NewDense(3, 3, h[:])); err != nil {
+		return inv, false
+	}
+	copy(inv[:], m.RawMatrix().Data)
+	return inv.normalized(), true
+}
+
+// normalized scales h so its last entry is 1 when possible.
+func (h Homography) normalized() Homography {
+	if h[8] != 0 {
+		for i := range h {
+			h[i] /= h[8]
+		}
+	}
+	return h
+}
+
+// ApplyLandmarks maps landmark points.
+func (h Homography) ApplyLandmarks(l []BoxLandmark) []BoxLandmark {
+	ret := make([]BoxLandmark, len(l))
+	for i, p := range l {
+		x, y := h.Apply(float32(p.X), float32(p.Y))
+		ret[i] = BoxLandmark{X: roundInt(float64(x)), Y: roundInt(float64(y))}
+	}
+	return ret
+}
+
+// ApplyBox maps a box: Rectangle becomes the bounds of its mapped corners,
+// a rotated box is refitted to its mapped polygon and landmarks follow.
+// The mask cannot be carried over and is dropped.
+func (h Homography) ApplyBox(b Box) Box {
+	r := b.Rectangle
+	corners := [][2]float32{
+		{float32(r.Min.X), float32(r.Min.Y)}, {float32(r.Max.X), float32(r.Min.Y)},
+		{float32(r.Max.X), float32(r.Max.Y)}, {float32(r.Min.X), float32(r.Max.Y)},
+	}
+	x0, y0 := float32(math.Inf(1)), float32(math.Inf(1))
+	x1, y1 := float32(math.Inf(-1)), float32(math.Inf(-1))
+	for _, c := range corners {
+		x, y := h.Apply(c[0], c[1])
+		x0, y0 = min32(x0, x), min32(y0, y)
+		x1, y1 = max32(x1, x), max32(y1, y)
+	}
+	b.Rectangle = image.Rect(roundInt(float64(x0)), roundInt(float64(y0)), roundInt(float64(x1)), roundInt(float64(y1)))
+	if b.Rotated != nil {
+		pts := b.Rotated.Polygon()
+		for i := range pts {
+			pts[i][0], pts[i][1] = h.Apply(pts[i][0], pts[i][1])
+		}
+		rb := MinAreaRect(pts[:])
+		b.Rotated = &rb
+		b.Rectangle = rb.Bounds()
+	}
+	if b.Landmark != nil {
+		b.Landmark = h.ApplyLandmarks(b.Landmark)
+	}
+	b.Mask = nil
+	return b
+}
+
+// hartley returns the similarity moving the centroid of pts to the origin
+// with a mean distance of √2, which conditions the DLT.
+func hartley(pts [][2]float32) (t [9]float64) {
+	var cx, cy float64
+	for _, p := range pts {
+		cx += float64(p[0])
+		cy += float64(p[1])
+	}
+	cx, cy = cx/float64(len(pts)), cy/float64(len(pts))
+	d := 0.0
+	for _, p := range pts {
+		d += math.Hypot(float64(p[0])-cx, float64(p[1])-cy)
+	}
+	s := 1.0
+	if d > 0 {
+		s = math.Sqrt2 * float64(len(pts)) / d
+	}
+	return [9]float64{s, 0, -s * cx, 0, s, -s * cy, 0, 0, 1}
+}
+
+// EstimateHomography fits the homography mapping src onto dst with the
+// normalised direct linear transform. Four points in general position give
+// the exact perspective transform; more are fitted in the algebraic least
+// squares sense.
+func EstimateHomography(src, dst [][2]float32) (Homography, error) {
+	if len(src) != len(dst) {
+		return Homography{}, fmt.Errorf("%d source points but %d destination points", len(src), len(dst))
+	}
+	if len(src) < 4 {
+		return Homography{}, fmt.Errorf("need at least 4 points, got %d", len(src))
+	}
+	ts, td := hartley(src), hartley(dst)
+	a := mat.NewDense(2*len(src), 9, nil)
+	for i := range src {
+		x := ts[0]*float64(src[i][0]) + ts[2]
+		y := ts[4]*float64(src[i][1]) + ts[5]
+		u := td[0]*float64(dst[i][0]) + td[2]
+		v := td[4]*float64(dst[i][1]) + td[5]
+		a.SetRow(2*i, []float64{-x, -y, -1, 0, 0, 0, u * x, u * y, u})
+		a.SetRow(2*i+1, []float64{0, 0, 0, -x, -y, -1, v * x, v * y, v})
+	}
+	var svd mat.SVD
+	if !svd.Factorize(a, mat.SVDFull) {
+		return Homography{}, errors.New("SVD did not converge")
+	}
+	values := svd.Values(nil)
+	if values[7] <= values[0]*1e-10 {
+		return Homography{}, errors.New("degenerate point configuration")
+	}
+	var vt mat.Dense
+	svd.VTo(&vt)
+	// the right singular vector of the smallest singular value
+	hn := mat.NewDense(3, 3, nil)
+	for i := 0; i < 9; i++ {
+		hn.Set(i/3, i%3, vt.At(i, 8))
+	}
+	// undo the normalisation: H = Td⁻¹ Hn Ts
+	tdInv := mat.NewDense(3, 3, []float64{1 / td[0], 0, -td[2] / td[0], 0, 1 / td[4], -td[5] / td[4], 0, 0, 1})
+	var m mat.Dense
+	m.Product(tdInv, hn, mat.NewDense(3, 3, ts[:]))
+	var h Homography
+	copy(h[:], m.RawMatrix().Data)
+	return h.normalized(), nil
+}
+
+// GetPerspectiveTransform returns the homography mapping the four corners
+// of src onto those of dst, e.g. a detected document or plate onto an
+// upright rectangle.
+func GetPerspectiveTransform(src, dst [4][2]float32) (Homography, error) {
+	return EstimateHomography(src[:], dst[:])
+}
+
+// EstimateHomographyRANSAC fits a homography robustly to correspondences
+// containing outliers, returning the inlier mask of the final model.
+func EstimateHomographyRANSAC(src, dst [][2]float32, opt RANSACOptions) (Homography, []bool, error) {
+	if len(src) != len(dst) {
+		return Homography{}, nil, fmt.Errorf("%d source points but %d destination points", len(src), len(dst))
+	}
+	return ransac(len(src), 4, opt, func(idx []int) (Homography, error) {
+		s := make([][2]float32, len(idx))
+		d := make([][2]float32, len(idx))
+		for k, i := range idx {
+			s[k], d[k] = src[i], dst[i]
+		}
+		return EstimateHomography(s, d)
+	}, func(h Homography, i int) float64 {
+		x, y := h.Apply(src[i][0], src[i][1])
+		r := math.Hypot(float64(x-dst[i][0]), float64(y-dst[i][1]))
+		if math.IsNaN(r) {
+			return math.Inf(1)
+		}
+		return r
+	})
+}
+
+// WarpPerspective maps img through h, which takes source coordinates to
+// destination ones as in OpenCV, into a width x height image.
+func WarpPerspective(img image.Image, h Homography, width, height int, opt WarpOptions) (*image.RGBA, error) {
+	inv, ok := h.Invert()
+	if !ok {
+		return nil, errors.New("homography is not invertible")
+	}
+	return warp(img, width, height, opt, func(x, y float64) (float64, float64, bool) {
+		w := inv[6]*x + inv[7]*y + inv[8]
+		if w == 0 {
+			return 0, 0, false
+		}
+		return (inv[0]*x + inv[1]*y + inv[2]) / w, (inv[3]*x + inv[4]*y + inv[5]) / w, true
+	}), nil
+}
@@ -0,0 +1,96 @@
+package goincv
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestEstimateHomography(t *testing.T) {
+	cases := []struct {
+		name string
+		h    Homography
+	}{
+		{"identity", Homography{1, 0, 0, 0, 1, 0, 0, 0, 1}},
+		{"affine", Affine{1.2, 0.3, 5, -0.4, 0.8, 9}.Homography()},
+		{"perspective", Homography{0.9, 0.1, 30, -0.2, 1.1, 12, 0.001, -0.0005, 1}},
+		{"strong perspective", Homography{1, 0.2, -40, 0.1, 0.7, 25, 0.003, 0.002, 1}},
+	}
+	grid := [][2]float32{{0, 0}, {100, 0}, {100, 80}, {0, 80}, {50, 40}, {20, 70}, {90, 10}}
+	for _, c := range cases {
+		for _, n := range []int{4, len(grid)} {
+			src := grid[:n]
+			dst := make([][2]float32, n)
+			for i, p := range src {
+				dst[i][0], dst[i][1] = c.h.Apply(p[0], p[1])
+			}
+			got, err := EstimateHomography(src, dst)
+			if err != nil {
+				t.Errorf("%s with %d points: %v", c.name, n, err)
+				continue
+			}
+			for i := range got {
+				if math.Abs(got[i]-c.h[i]) > 1e-4*math.Max(1, math.Abs(c.h[i])) {
+					t.Errorf("%s with %d points: got %v, want %v", c.name, n, got, c.h)
+					break
+				}
+			}
+		}
+	}
+
+	square := [4][2]float32{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	quad := [4][2]float32{{10, 10}, {50, 12}, {48, 40}, {8, 35}}
+	h, err := GetPerspectiveTransform(square, quad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range square {
+		x, y := h.Apply(p[0], p[1])
+		if math.Hypot(float64(x-quad[i][0]), float64(y-quad[i][1])) > 1e-3 {
+			t.Errorf("corner %d maps to %v,%v, want %v", i, x, y, quad[i])
+		}
+	}
+	inv, ok := h.Invert()
+	if !ok {
+		t.Fatal("homography is not invertible")
+	}
+	if x, y := inv.Apply(quad[2][0], quad[2][1]); math.Abs(float64(x-1)) > 1e-4 || math.Abs(float64(y-1)) > 1e-4 {
+		t.Errorf("inverse maps %v to %v,%v", quad[2], x, y)
+	}
+
+	collinear := [][2]float32{{0, 0}, {1, 1}, {2, 2}, {3, 3}}
+	if _, err := EstimateHomography(collinear, collinear); err == nil {
+		t.Error("collinear points were accepted")
+	}
+	if _, err := EstimateHomography(grid[:3], grid[:3]); err == nil {
+		t.Error("three points were accepted")
+	}
+}
+
+func TestEstimateHomographyRANSAC(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	h := Homography{0.9, 0.1, 30, -0.2, 1.1, 12, 0.001, -0.0005, 1}
+	var src, dst [][2]float32
+	for i := 0; i < 60; i++ {
+		x, y := r.Float32()*300, r.Float32()*300
+		u, v := h.Apply(x, y)
+		if i%5 == 0 {
+			u, v = r.Float32()*300, r.Float32()*300
+		}
+		src, dst = append(src, [2]float32{x, y}), append(dst, [2]float32{u, v})
+	}
+	got, inliers, err := EstimateHomographyRANSAC(src, dst, RANSACOptions{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range src {
+		x, y := got.Apply(src[i][0], src[i][1])
+		u, v := h.Apply(src[i][0], src[i][1])
+		if math.Hypot(float64(x-u), float64(y-v)) > 0.05 {
+			t.Fatalf("point %d maps to %v,%v, want %v,%v", i, x, y, u, v)
+		}
+		if inliers[i] != (i%5 != 0) {
+			t.Errorf("point %d inlier %v", i, inliers[i])
+		}
+	}
+}
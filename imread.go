@@ -0,0 +1,171 @@
+package goincv
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// ImageLayout is the memory order of a flat image buffer.
+type ImageLayout int
+
+const (
+	LayoutHWC ImageLayout = iota // pixel interleaved, H x W x 3
+	LayoutCHW                    // planar, 3 x H x W
+)
+
+// ChannelOrder selects the colour order written to a flat image buffer.
+type ChannelOrder int
+
+const (
+	ChannelRGB ChannelOrder = iota
+	ChannelBGR
+)
+
+// ImReadFlat writes the pixels of img into a flat uint8 buffer of
+// 3*W*H values. dst is reused when it is large enough, so converting
+// a stream of frames of the same size does not allocate.
+func ImReadFlat(img image.Image, layout ImageLayout, order ChannelOrder, dst []uint8) []uint8 {
+	var lut [3][256]uint8
+	for c := range lut {
+		for v := range lut[c] {
+			lut[c][v] = uint8(v)
+		}
+	}
+	return imReadFlat(img, layout, order, &lut, dst)
+}
+
+// ImReadNormalizeF32 is ImReadFlat with (v/255-mean)/norm fused in, the
+// same formula as ImageMeanNormalizeF32RGBCHW. mean and norm are given in
+// output channel order; nil means 0 and 1.
+func ImReadNormalizeF32(img image.Image, layout ImageLayout, order ChannelOrder, mean, norm []float32, dst []float32) []float32 {
+	var lut [3][256]float32
+	for c := range lut {
+		m, n := float32(0), float32(1)
+		if mean != nil {
+			m = mean[c]
+		}
+		if norm != nil {
+			n = norm[c]
+		}
+		for v := range lut[c] {
+			lut[c][v] = (float32(v)/255 - m) / n
+		}
+	}
+	return imReadFlat(img, layout, order, &lut, dst)
+}
+
+// ImReadTensor returns img as a uint8 tensor of shape [H,W,3] or [3,H,W].
+func ImReadTensor(img image.Image, layout ImageLayout, order ChannelOrder) *Tensor[uint8] {
+	return MustTensorFrom(ImReadFlat(img, layout, order, nil), imageShape(img, layout))
+}
+
+// ImReadNormalizeTensor returns the normalised image as a float32 tensor of
+// shape [H,W,3] or [3,H,W], ready to be given a batch axis with Unsqueeze.
+func ImReadNormalizeTensor(img image.Image, layout ImageLayout, order ChannelOrder, mean, norm []float32) *Tensor[float32] {
+	return MustTensorFrom(ImReadNormalizeF32(img, layout, order, mean, norm, nil), imageShape(img, layout))
+}
+
+func imageShape(img image.Image, layout ImageLayout) Shape {
+	b := img.Bounds()
+	if layout == LayoutCHW {
+		return Shape{3, b.Dy(), b.Dx()}
+	}
+	return Shape{b.Dy(), b.Dx(), 3}
+}
+
+// imReadFlat reads the Pix buffers of the common image types directly and
+// maps each 8 bit sample through lut, which holds one table per output
+// channel. Results match ToRGBA followed by At, without the per pixel
+// interface calls.
+func imReadFlat[T float32 | uint8](img image.Image, layout ImageLayout, order ChannelOrder, lut *[3][256]T, dst []T) []T {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	n := w * h * 3
+	if cap(dst) < n {
+		dst = make([]T, n)
+	}
+	dst = dst[:n]
+
+	// output position of r, g and b relative to the pixel index
+	step, plane := 3, 1
+	if layout == LayoutCHW {
+		step, plane = 1, w*h
+	}
+	cr, cg, cb := 0, 1, 2
+	if order == ChannelBGR {
+		cr, cb = 2, 0
+	}
+	or, og, ob := cr*plane, cg*plane, cb*plane
+	lr, lg, lb := &lut[cr], &lut[cg], &lut[cb]
+
+	switch src := img.(type) {
+	case *image.RGBA:
+		for y := 0; y < h; y++ {
+			pix := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+			o := y * w * step
+			for x := 0; x < w; x++ {
+				i := x * 4
+				dst[o+or] = lr[pix[i]]
+				dst[o+og] = lg[pix[i+1]]
+				dst[o+ob] = lb[pix[i+2]]
+				o += step
+			}
+		}
+	case *image.NRGBA:
+		for y := 0; y < h; y++ {
+			pix := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+			o := y * w * step
+			for x := 0; x < w; x++ {
+				i := x * 4
+				a := uint32(pix[i+3])
+				if a == 0xff {
+					dst[o+or] = lr[pix[i]]
+					dst[o+og] = lg[pix[i+1]]
+					dst[o+ob] = lb[pix[i+2]]
+				} else {
+					dst[o+or] = lr[premultiply(pix[i], a)]
+					dst[o+og] = lg[premultiply(pix[i+1], a)]
+					dst[o+ob] = lb[premultiply(pix[i+2], a)]
+				}
+				o += step
+			}
+		}
+	case *image.YCbCr:
+		for y := 0; y < h; y++ {
+			o := y * w * step
+			for x := 0; x < w; x++ {
+				yi := src.YOffset(b.Min.X+x, b.Min.Y+y)
+				ci := src.COffset(b.Min.X+x, b.Min.Y+y)
+				r, g, bb := color.YCbCrToRGB(src.Y[yi], src.Cb[ci], src.Cr[ci])
+				dst[o+or] = lr[r]
+				dst[o+og] = lg[g]
+				dst[o+ob] = lb[bb]
+				o += step
+			}
+		}
+	case *image.Gray:
+		for y := 0; y < h; y++ {
+			pix := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+			o := y * w * step
+			for x := 0; x < w; x++ {
+				v := pix[x]
+				dst[o+or] = lr[v]
+				dst[o+og] = lg[v]
+				dst[o+ob] = lb[v]
+				o += step
+			}
+		}
+	default:
+		// ToRGBA draws from the origin, which shifts sub-images
+		rgba := image.NewRGBA(b)
+		draw.Draw(rgba, b, img, b.Min, draw.Src)
+		return imReadFlat(rgba, layout, order, lut, dst)
+	}
+	return dst
+}
+
+// premultiply matches the rounding draw.Draw uses for NRGBA sources.
+func premultiply(v uint8, a uint32) uint8 {
+	return uint8(uint32(v) * a * 0x101 / 0xff >> 8)
+}
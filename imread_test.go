@@ -0,0 +1,164 @@
+package goincv
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+// oldImRead is the ToRGBA followed by At loop the ImRead functions used
+// before reading Pix directly, as [c][y][x] in RGB order. The old loop
+// indexed from 0 and ToRGBA draws from the origin, so both ignored
+// Bounds().Min; sub-images are converted and read relative to it instead.
+func oldImRead(img image.Image) [3][][]uint8 {
+	b := img.Bounds()
+	rgba := ToRGBA(img)
+	if b.Min != (image.Point{}) {
+		rgba = image.NewRGBA(b)
+		draw.Draw(rgba, b, img, b.Min, draw.Src)
+	}
+	var ret [3][][]uint8
+	for c := range ret {
+		ret[c] = make([][]uint8, b.Dy())
+		for dh := range ret[c] {
+			ret[c][dh] = make([]uint8, b.Dx())
+		}
+	}
+	for dh := 0; dh < b.Dy(); dh++ {
+		for dw := 0; dw < b.Dx(); dw++ {
+			r, g, bb, _ := rgba.At(b.Min.X+dw, b.Min.Y+dh).RGBA()
+			ret[0][dh][dw] = uint8(r)
+			ret[1][dh][dw] = uint8(g)
+			ret[2][dh][dw] = uint8(bb)
+		}
+	}
+	return ret
+}
+
+func imreadTestImages() map[string]image.Image {
+	rnd := rand.New(rand.NewSource(1))
+	fill := func(p []uint8) {
+		for i := range p {
+			p[i] = uint8(rnd.Intn(256))
+		}
+	}
+	r := image.Rect(0, 0, 13, 9)
+
+	rgba := image.NewRGBA(r)
+	fill(rgba.Pix)
+	for i := 3; i < len(rgba.Pix); i += 4 {
+		rgba.Pix[i] = 0xff
+	}
+	nrgba := image.NewNRGBA(r)
+	fill(nrgba.Pix)
+	// keep the edge cases in, random bytes rarely hit them
+	nrgba.Pix[3], nrgba.Pix[7], nrgba.Pix[11] = 0, 0xff, 1
+	ycc420 := image.NewYCbCr(r, image.YCbCrSubsampleRatio420)
+	fill(ycc420.Y)
+	fill(ycc420.Cb)
+	fill(ycc420.Cr)
+	ycc422 := image.NewYCbCr(r, image.YCbCrSubsampleRatio422)
+	fill(ycc422.Y)
+	fill(ycc422.Cb)
+	fill(ycc422.Cr)
+	gray := image.NewGray(r)
+	fill(gray.Pix)
+	pal := image.NewPaletted(r, color.Palette{color.Black, color.White, color.RGBA{200, 10, 50, 255}})
+	for i := range pal.Pix {
+		pal.Pix[i] = uint8(rnd.Intn(3))
+	}
+
+	sub := image.Rect(3, 2, 10, 7)
+	return map[string]image.Image{
+		"rgba":        rgba,
+		"nrgba":       nrgba,
+		"ycbcr420":    ycc420,
+		"ycbcr422":    ycc422,
+		"gray":        gray,
+		"paletted":    pal,
+		"sub rgba":    rgba.SubImage(sub),
+		"sub nrgba":   nrgba.SubImage(sub),
+		"sub ycbcr":   ycc420.SubImage(sub),
+		"sub gray":    gray.SubImage(sub),
+		"sub palette": pal.SubImage(sub),
+	}
+}
+
+func TestImReadMatchesAt(t *testing.T) {
+	for name, img := range imreadTestImages() {
+		want := oldImRead(img)
+		b := img.Bounds()
+		w, h := b.Dx(), b.Dy()
+
+		hwc := func(got [][][]uint8, order ChannelOrder) error {
+			if len(got) != h {
+				return fmt.Errorf("rows %d, want %d", len(got), h)
+			}
+			for y := range got {
+				if len(got[y]) != w {
+					return fmt.Errorf("row %d has %d pixels, want %d", y, len(got[y]), w)
+				}
+				for x := range got[y] {
+					for c := 0; c < 3; c++ {
+						wc := c
+						if order == ChannelBGR {
+							wc = 2 - c
+						}
+						if got[y][x][c] != want[wc][y][x] {
+							return fmt.Errorf("(%d,%d)[%d] = %d, want %d", x, y, c, got[y][x][c], want[wc][y][x])
+						}
+					}
+				}
+			}
+			return nil
+		}
+		if err := hwc(ImRead(img), ChannelBGR); err != nil {
+			t.Errorf("%s: ImRead %v", name, err)
+		}
+		if err := hwc(ImReadBGR(img), ChannelBGR); err != nil {
+			t.Errorf("%s: ImReadBGR %v", name, err)
+		}
+		if err := hwc(ImReadRGB(img), ChannelRGB); err != nil {
+			t.Errorf("%s: ImReadRGB %v", name, err)
+		}
+
+		chw := ImReadRGBCHW(img)
+	chwLoop:
+		for c := range want {
+			for y := range want[c] {
+				if !bytes.Equal(chw[c][y], want[c][y]) {
+					t.Errorf("%s: ImReadRGBCHW channel %d row %d = %v, want %v", name, c, y, chw[c][y], want[c][y])
+					break chwLoop
+				}
+			}
+		}
+
+		for _, layout := range []ImageLayout{LayoutHWC, LayoutCHW} {
+			for _, order := range []ChannelOrder{ChannelRGB, ChannelBGR} {
+				flat := make([]uint8, w*h*3)
+				for c := 0; c < 3; c++ {
+					wc := c
+					if order == ChannelBGR {
+						wc = 2 - c
+					}
+					for y := 0; y < h; y++ {
+						for x := 0; x < w; x++ {
+							i := (y*w+x)*3 + c
+							if layout == LayoutCHW {
+								i = (c*h+y)*w + x
+							}
+							flat[i] = want[wc][y][x]
+						}
+					}
+				}
+				if got := ImReadFlat(img, layout, order, nil); !bytes.Equal(got, flat) {
+					t.Errorf("%s: ImReadFlat(%d, %d) = %v, want %v", name, layout, order, got, flat)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package goincv
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+)
+
+// DecodeMasks builds the instance mask of every box carrying coefficients
+// under MaskCoeffsKey, as YOLACT, YOLOv5-seg and YOLOv8-seg predict them.
+// protos holds nm prototype masks over the model input, [nm, mh, mw] or
+// [mh, mw, nm], with an optional batch axis. The mask logit is the
+// coefficient weighted sum of the prototypes; it is bilinearly upsampled to
+// the source resolution with ScaleParams, cropped to the box and set where
+// its sigmoid reaches threshold (0.5 when zero). Call it after NMS so only
+// kept boxes pay for it.
+func (d *DetectDecoder) DecodeMasks(boxes []Box, protos AnyTensor, threshold float32) error {
+	p, err := AsTensor[float32](protos)
+	if err != nil {
+		return err
+	}
+	for len(p.shape) > 3 && p.shape[0] == 1 {
+		p = p.Index(0)
+	}
+	if len(p.shape) != 3 {
+		return fmt.Errorf("prototypes %v are not 3-D", protos.Shape())
+	}
+	if threshold == 0 {
+		threshold = 0.5
+	}
+	logitThr := float32(math.Log(float64(threshold) / (1 - float64(threshold))))
+	var chw *Tensor[float32]
+	for i := range boxes {
+		coeffs, ok := boxes[i].Extension[MaskCoeffsKey].([]float32)
+		if !ok {
+			continue
+		}
+		if chw == nil {
+			switch len(coeffs) {
+			case p.shape[0]:
+				chw = p.Contiguous()
+			case p.shape[2]:
+				if chw, err = p.Permute(2, 0, 1); err != nil {
+					return err
+				}
+				chw = chw.Contiguous()
+			default:
+				return fmt.Errorf("prototypes %v do not match %d coefficients", p.shape, len(coeffs))
+			}
+		}
+		if len(coeffs) != chw.shape[0] {
+			return fmt.Errorf("box %d has %d coefficients, prototypes %v", i, len(coeffs), chw.shape)
+		}
+		boxes[i].Mask = d.decodeMask(boxes[i].Rectangle, coeffs, chw, logitThr)
+	}
+	return nil
+}
+
+// decodeMask evaluates the prototypes only over the cells under r. Box
+// rectangles include their Max corner, so the mask does too.
+func (d *DetectDecoder) decodeMask(r image.Rectangle, coeffs []float32, protos *Tensor[float32], logitThr float32) *image.Alpha {
+	r.Max = r.Max.Add(image.Pt(1, 1))
+	if !d.Source.Empty() {
+		r = r.Intersect(d.Source)
+	}
+	mask := image.NewAlpha(r)
+	if r.Empty() {
+		return mask
+	}
+	nm, mh, mw := protos.shape[0], protos.shape[1], protos.shape[2]
+	sx, sy := float32(mw)/float32(d.Width), float32(mh)/float32(d.Height)
+	// proto coordinate of the centre of source pixel (x, y)
+	at := func(x, y int) (float32, float32) {
+		mx, my := d.ScaleParams.Scale(float32(x)+0.5, float32(y)+0.5)
+		return mx*sx - 0.5, my*sy - 0.5
+	}
+	fx0, fy0 := at(r.Min.X, r.Min.Y)
+	fx1, fy1 := at(r.Max.X-1, r.Max.Y-1)
+	gx0 := clampIndex(int(math.Floor(float64(fx0))), 0, mw-1)
+	gy0 := clampIndex(int(math.Floor(float64(fy0))), 0, mh-1)
+	gx1 := clampIndex(int(math.Floor(float64(fx1)))+1, 0, mw-1)
+	gy1 := clampIndex(int(math.Floor(float64(fy1)))+1, 0, mh-1)
+	cw, ch := gx1-gx0+1, gy1-gy0+1
+	logits := make([]float32, cw*ch)
+	data, plane := protos.data[protos.offset:], mh*mw
+	for k := 0; k < nm; k++ {
+		c := coeffs[k]
+		for y := 0; y < ch; y++ {
+			src := data[k*plane+(gy0+y)*mw+gx0:][:cw]
+			dst := logits[y*cw:][:cw]
+			for x := range dst {
+				dst[x] += c * src[x]
+			}
+		}
+	}
+	sample := func(fx, fy float32) float32 {
+		fx = float32(math.Max(0, math.Min(float64(fx-float32(gx0)), float64(cw-1))))
+		fy = float32(math.Max(0, math.Min(float64(fy-float32(gy0)), float64(ch-1))))
+		x0, y0 := int(fx), int(fy)
+		x1, y1 := clampIndex(x0+1, 0, cw-1), clampIndex(y0+1, 0, ch-1)
+		ax, ay := fx-float32(x0), fy-float32(y0)
+		top := logits[y0*cw+x0]*(1-ax) + logits[y0*cw+x1]*ax
+		bottom := logits[y1*cw+x0]*(1-ax) + logits[y1*cw+x1]*ax
+		return top*(1-ay) + bottom*ay
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := mask.Pix[(y-r.Min.Y)*mask.Stride:]
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if sample(at(x, y)) >= logitThr {
+				row[x-r.Min.X] = 255
+			}
+		}
+	}
+	return mask
+}
+
+func clampIndex(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// MergeBoxMasks draws the masks of boxes into one alpha image of the given
+// bounds, e.g. the source image.
+func MergeBoxMasks(boxes []Box, bounds image.Rectangle) *image.Alpha {
+	ret := image.NewAlpha(bounds)
+	for _, b := range boxes {
+		if b.Mask != nil {
+			draw.DrawMask(ret, b.Mask.Rect, image.Opaque, image.Point{}, b.Mask, b.Mask.Rect.Min, draw.Over)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,63 @@
+package goincv
+
+import (
+	"image"
+	"testing"
+)
+
+func TestDecodeMasks(t *testing.T) {
+	// model 64x64 with 16x16 prototypes, source 128x64 letterboxed
+	src := image.Rect(0, 0, 128, 64)
+	d := NewDetectDecoder(src, 64, 64)
+	d.MaskCoeffs = 2
+	// prototype 0 fires on the left half of the model input, 1 everywhere
+	protos := NewTensor[float32](Shape{1, 2, 16, 16})
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if x < 8 {
+				protos.Set(10, 0, 0, y, x)
+			}
+			protos.Set(1, 0, 1, y, x)
+		}
+	}
+	out := NewTensor[float32](Shape{1, 7, 10})
+	for i, v := range [][]float32{{32, 32, 64, 32, 0.9, 1, -5}, {10, 30, 8, 8, 0.1, 1, 0}} {
+		for k, x := range v {
+			out.Set(x, 0, k, i)
+		}
+	}
+	boxes, err := d.YoloV8(out)
+	if err != nil || len(boxes) != 1 {
+		t.Fatal(err, boxes)
+	}
+	if err := d.DecodeMasks(boxes, protos, 0); err != nil {
+		t.Fatal(err)
+	}
+	r := boxes[0].Rectangle
+	m := boxes[0].Mask
+	// the Max corner of a box rectangle is inside the box
+	want := image.Rectangle{r.Min, r.Max.Add(image.Pt(1, 1))}.Intersect(src)
+	if m.Rect != want {
+		t.Fatalf("mask covers %v, want %v", m.Rect, want)
+	}
+	for _, p := range [][3]int{{10, 40, 255}, {60, 40, 255}, {68, 40, 0}, {120, 40, 0}} {
+		if got := m.AlphaAt(p[0], p[1]).A; int(got) != p[2] {
+			t.Errorf("alpha at %d,%d = %d, want %d", p[0], p[1], got, p[2])
+		}
+	}
+
+	// HWC prototypes decode the same
+	hwc, err := protos.Index(0).Permute(1, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	boxes2, _ := d.YoloV8(out)
+	if err := d.DecodeMasks(boxes2, hwc, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := range m.Pix {
+		if m.Pix[i] != boxes2[0].Mask.Pix[i] {
+			t.Fatal("HWC prototypes decode differently")
+		}
+	}
+}
@@ -118,9 +118,20 @@ func FlattenUint8(d interface{}) []uint8 {
 type DataType string
 
 const (
-	DataTypeFloat32 DataType = "DataTypeFloat32"
-	DataTypeFloat64 DataType = "DataTypeFloat64"
-	DataTypeUInt8   DataType = "DataTypeUInt8"
+	DataTypeFloat32  DataType = "DataTypeFloat32"
+	DataTypeFloat64  DataType = "DataTypeFloat64"
+	DataTypeFloat16  DataType = "DataTypeFloat16"
+	DataTypeBFloat16 DataType = "DataTypeBFloat16"
+	DataTypeUInt8    DataType = "DataTypeUInt8"
+	DataTypeUInt16   DataType = "DataTypeUInt16"
+	DataTypeUInt32   DataType = "DataTypeUInt32"
+	DataTypeUInt64   DataType = "DataTypeUInt64"
+	DataTypeUInt     DataType = "DataTypeUInt"
+	DataTypeInt8     DataType = "DataTypeInt8"
+	DataTypeInt16    DataType = "DataTypeInt16"
+	DataTypeInt32    DataType = "DataTypeInt32"
+	DataTypeInt64    DataType = "DataTypeInt64"
+	DataTypeInt      DataType = "DataTypeInt"
 )
 
 // func Reshape(input interface{}, reshape Shape, types DataType) (ret interface{}, err error) {
@@ -383,10 +394,13 @@ func ToSlice(oval interface{}) []interface{} {
 	return s
 }
 
+// Softmax returns the index of the largest element of arr, parsing each
+// element with cast, or -1 when arr is empty. Despite the name it returns
+// no probabilities; use Tensor.Softmax for those.
 func Softmax(arr interface{}) int {
 	list := ToSlice(arr)
 	maxIndex := -1
-	var maxNum float64 = -99999999
+	var maxNum = math.Inf(-1)
 	for i := range list {
 		n := cast.ToFloat64(fmt.Sprint(list[i]))
 		// log.Println(n)
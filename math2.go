@@ -62,7 +62,11 @@ func (f *OneEuroFilter) Filter(t, x float64) float64 {
 }
 
 // estimateAffine2D 使用最小二乘法估计 2D 仿射变换矩阵
+// It fits a rotation only and leaves src and dst untouched; see
+// EstimateTransform for similarity and full affine fits.
 func EstimateAffine2D(src, dst []float32) []float32 {
+	src = append([]float32(nil), src...)
+	dst = append([]float32(nil), dst...)
 	// 将坐标系移到图像的中心
 	srcMean := make([]float32, 2)
 	dstMean := make([]float32, 2)
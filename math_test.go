@@ -0,0 +1,23 @@
+package goincv
+
+import "testing"
+
+func TestSoftmaxIndex(t *testing.T) {
+	cases := []struct {
+		arr  interface{}
+		want int
+	}{
+		{[]float32{0.1, 0.7, 0.2}, 1},
+		{[]float64{-3, -1, -2}, 1},
+		{[]float64{-2e9, -1e9, -3e9}, 1},
+		{[]float32{-1e30, -1e20}, 1},
+		{[]int64{4, 9, 9}, 1},
+		{[]interface{}{"0.5", "2.5", 1}, 1},
+		{[]float32{}, -1},
+	}
+	for _, c := range cases {
+		if got := Softmax(c.arr); got != c.want {
+			t.Errorf("Softmax(%v) = %d, want %d", c.arr, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package goincv
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+	"sync"
+)
+
+// TensorInfo describes a named model input or output. Dynamic dimensions,
+// such as the batch axis, are negative.
+type TensorInfo struct {
+	Name     string
+	Shape    Shape
+	DataType DataType
+}
+
+// Match reports whether t can be bound to this input.
+func (info TensorInfo) Match(t AnyTensor) error {
+	if t == nil {
+		return fmt.Errorf("input %s is missing", info.Name)
+	}
+	if info.DataType != "" && t.DataType() != info.DataType {
+		return fmt.Errorf("input %s wants %v, got %v", info.Name, info.DataType, t.DataType())
+	}
+	if info.Shape == nil {
+		return nil
+	}
+	shape := t.Shape()
+	if len(shape) != len(info.Shape) {
+		return fmt.Errorf("input %s wants shape %v, got %v", info.Name, info.Shape, shape)
+	}
+	for i, d := range info.Shape {
+		if d >= 0 && d != shape[i] {
+			return fmt.Errorf("input %s wants shape %v, got %v", info.Name, info.Shape, shape)
+		}
+	}
+	return nil
+}
+
+// Session is a loaded model ready to run. Implementations wrap an inference
+// runtime (onnxruntime, ncnn, ...) or the pure Go GraphSession; Run must be
+// safe to call from several goroutines.
+type Session interface {
+	Inputs() []TensorInfo
+	Outputs() []TensorInfo
+	Run(inputs map[string]AnyTensor) (map[string]AnyTensor, error)
+	Close() error
+}
+
+// Backend opens a model file as a Session.
+type Backend func(path string) (Session, error)
+
+var backends = struct {
+	sync.RWMutex
+	m map[string]Backend
+}{m: map[string]Backend{}}
+
+// RegisterBackend makes a runtime available to OpenSession, usually from
+// the init function of the package wrapping it.
+func RegisterBackend(name string, open Backend) {
+	backends.Lock()
+	defer backends.Unlock()
+	backends.m[name] = open
+}
+
+// Backends returns the registered backend names.
+func Backends() []string {
+	backends.RLock()
+	defer backends.RUnlock()
+	ret := make([]string, 0, len(backends.m))
+	for name := range backends.m {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// OpenSession loads path with a registered backend.
+func OpenSession(backend, path string) (Session, error) {
+	backends.RLock()
+	open, ok := backends.m[backend]
+	backends.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("backend %s is not registered", backend)
+	}
+	return open(path)
+}
+
+// CheckInputs validates inputs against the session's input infos.
+func CheckInputs(s Session, inputs map[string]AnyTensor) error {
+	for _, info := range s.Inputs() {
+		if err := info.Match(inputs[info.Name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunSession binds inputs positionally and returns the outputs in the
+// order of s.Outputs().
+func RunSession(s Session, inputs ...AnyTensor) ([]AnyTensor, error) {
+	infos := s.Inputs()
+	if len(inputs) != len(infos) {
+		return nil, fmt.Errorf("model has %d inputs, got %d", len(infos), len(inputs))
+	}
+	feed := map[string]AnyTensor{}
+	for i, info := range infos {
+		feed[info.Name] = inputs[i]
+	}
+	out, err := s.Run(feed)
+	if err != nil {
+		return nil, err
+	}
+	ret := []AnyTensor{}
+	for _, info := range s.Outputs() {
+		t, ok := out[info.Name]
+		if !ok {
+			return nil, fmt.Errorf("model did not produce output %s", info.Name)
+		}
+		ret = append(ret, t)
+	}
+	return ret, nil
+}
+
+// RunSession preprocesses imgs for the first input of s and runs it. Unset
+// Width, Height, Layout and DType are taken from the input shape, which
+// must be NCHW or NHWC.
+func (p *Preprocessor) RunSession(s Session, imgs []image.Image) (map[string]AnyTensor, []ScaleParams, error) {
+	infos := s.Inputs()
+	if len(infos) == 0 {
+		return nil, nil, errors.New("model has no inputs")
+	}
+	info := infos[0]
+	pp := *p
+	if len(info.Shape) == 4 && pp.Width == 0 && pp.Height == 0 {
+		if info.Shape[1] == 3 {
+			pp.Layout, pp.Height, pp.Width = LayoutCHW, info.Shape[2], info.Shape[3]
+		} else {
+			pp.Layout, pp.Height, pp.Width = LayoutHWC, info.Shape[1], info.Shape[2]
+		}
+	}
+	if pp.DType == "" {
+		pp.DType = info.DataType
+	}
+	input, params, err := pp.Run(imgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := info.Match(input); err != nil {
+		return nil, nil, err
+	}
+	out, err := s.Run(map[string]AnyTensor{info.Name: input})
+	return out, params, err
+}
+
+// AddTensors is AddValue for model outputs: scores are flattened, bbox is
+// read as [N,4] and kps, which may be nil, as [N,10].
+func (m *NMS) AddTensors(stride int, score, bbox, kps AnyTensor) error {
+	s, err := AsTensor[float32](score)
+	if err != nil {
+		return err
+	}
+	b, err := AsTensor[float32](bbox)
+	if err != nil {
+		return err
+	}
+	b, err = b.Reshape(Shape{-1, 4})
+	if err != nil {
+		return err
+	}
+	if s.Size() != b.shape[0] {
+		return fmt.Errorf("%d scores for %d boxes", s.Size(), b.shape[0])
+	}
+	var k [][]float32
+	if kps != nil {
+		kt, err := AsTensor[float32](kps)
+		if err != nil {
+			return err
+		}
+		kt, err = kt.Reshape(Shape{b.shape[0], -1})
+		if err != nil {
+			return err
+		}
+		k = kt.To2D()
+	}
+	m.AddValue(stride, s.Data(), b.To2D(), k)
+	return nil
+}
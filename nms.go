@@ -23,6 +23,9 @@ type NMS struct {
 	sourcetWidth  int
 	sourcetHeight int
 	ScaleParams   ScaleParams
+	Agnostic      bool      // suppress across classes
+	MaxDetections int       // 0 keeps all
+	Method        NMSMethod // NMSHard by default
 
 	boxCollection []Box
 	numAnchors    int
@@ -95,39 +98,12 @@ func (m *NMS) AddValue(stride int, scoreValues []float32, bboxValues_Nx4 [][]flo
 }
 
 func (m *NMS) End() []Box {
-	sort.Slice(m.boxCollection, func(i, j int) bool {
-		return m.boxCollection[i].Prob > m.boxCollection[j].Prob
+	return MultiClassNms(m.boxCollection, NMSOptions{
+		Method:        m.Method,
+		IoUThreshold:  m.NMSThreshold,
+		Agnostic:      m.Agnostic,
+		MaxDetections: m.MaxDetections,
 	})
-
-	inputBoxes := m.boxCollection
-	thresh := m.NMSThreshold
-
-	vArea := []int{}
-	for i := range inputBoxes {
-		vArea = append(vArea,
-			(inputBoxes[i].Rectangle.Max.X-inputBoxes[i].Rectangle.Min.X+1)*(inputBoxes[i].Rectangle.Max.Y-inputBoxes[i].Rectangle.Min.Y+1),
-		)
-	}
-
-	for i := 0; i < len(inputBoxes); i++ {
-		for j := i + 1; j < len(inputBoxes); {
-			xx1 := math.Max(float64(inputBoxes[i].Rectangle.Min.X), float64(inputBoxes[j].Rectangle.Min.X))
-			yy1 := math.Max(float64(inputBoxes[i].Rectangle.Min.Y), float64(inputBoxes[j].Rectangle.Min.Y))
-			xx2 := math.Min(float64(inputBoxes[i].Rectangle.Max.X), float64(inputBoxes[j].Rectangle.Max.X))
-			yy2 := math.Min(float64(inputBoxes[i].Rectangle.Max.Y), float64(inputBoxes[j].Rectangle.Max.Y))
-			w := math.Max(0, xx2-xx1+1)
-			h := math.Max(0, yy2-yy1+1)
-			inter := float32(w * h)
-			ovr := inter / (float32(vArea[i]) + float32(vArea[j]) - inter)
-			if ovr >= thresh {
-				inputBoxes = append(inputBoxes[:j], inputBoxes[j+1:]...)
-				vArea = append(vArea[:j], vArea[j+1:]...)
-			} else {
-				j++
-			}
-		}
-	}
-	return inputBoxes
 }
 
 func (m *NMS) generatePoints(stride int) {
@@ -226,3 +202,108 @@ func (m *NMS) generateBboxesSingleStride(stride int, scoreValues []float32, bbox
 		m.boxCollection = m.boxCollection[:nms_pre_]
 	}
 }
+
+// NMSOptions configures MultiClassNms.
+type NMSOptions struct {
+	Method          NMSMethod
+	IoUThreshold    float32
+	ScoreThreshold  float32
+	ClassThresholds map[int]float32 // overrides ScoreThreshold per class id
+	Agnostic        bool            // suppress across classes
+	MaxDetections   int             // 0 keeps all
+	Labels          []string        // fills Box.Label from Box.ClassID
+	Sigma           float32         // gaussian Soft-NMS and Matrix NMS, 0.5 when zero
+}
+
+// MultiClassNms filters boxes by score and suppresses overlaps with the
+// chosen method. Only boxes of the same class interact unless Agnostic is
+// set. Boxes with Rotated set are compared by RotatedIoU for the hard, soft
+// and matrix methods. The result is sorted by probability; the input slice
+// is not modified.
+func MultiClassNms(boxes []Box, opt NMSOptions) []Box {
+	cand := make([]Box, 0, len(boxes))
+	for _, b := range boxes {
+		if b.Prob >= opt.threshold(b.ClassID) {
+			cand = append(cand, b)
+		}
+	}
+	sort.SliceStable(cand, func(i, j int) bool {
+		return cand[i].Prob > cand[j].Prob
+	})
+
+	var ret []Box
+	switch opt.Method {
+	case NMSSoftLinear, NMSSoftGaussian:
+		ret = softNms(cand, opt)
+	case NMSMatrixLinear, NMSMatrixGaussian:
+		ret = matrixNms(cand, opt)
+	case NMSWeightedFusion:
+		// a single model with weight 1 always validates
+		ret, _ = WeightedBoxesFusion([][]Box{cand}, nil, opt)
+	case NMSDIoU:
+		ret = greedyNms(cand, opt, BoxDIoU)
+	case NMSCIoU:
+		ret = greedyNms(cand, opt, BoxCIoU)
+	default:
+		ret = greedyNms(cand, opt, nil)
+	}
+	if opt.MaxDetections > 0 && len(ret) > opt.MaxDetections {
+		ret = ret[:opt.MaxDetections]
+	}
+	for i := range ret {
+		if c := ret[i].ClassID; c >= 0 && c < len(opt.Labels) {
+			ret[i].Label = opt.Labels[c]
+		}
+	}
+	return ret
+}
+
+func (opt NMSOptions) threshold(class int) float32 {
+	if t, ok := opt.ClassThresholds[class]; ok {
+		return t
+	}
+	return opt.ScoreThreshold
+}
+
+// interacts reports whether two boxes can suppress each other.
+func (opt NMSOptions) interacts(a, b Box) bool {
+	return opt.Agnostic || a.ClassID == b.ClassID
+}
+
+// greedyNms keeps the most probable box and drops the ones whose overlap
+// with it reaches IoUThreshold. cand must be sorted. overlap nil means
+// BoxOverlap, computed on precomputed float rectangles when no box is
+// rotated.
+func greedyNms(cand []Box, opt NMSOptions, overlap func(a, b image.Rectangle) float32) []Box {
+	rects, canonical := nmsRects(len(cand), func(i int) image.Rectangle { return boxBounds(cand[i]) })
+	var skip func(i, j int) bool
+	if !opt.Agnostic {
+		skip = func(i, j int) bool { return cand[i].ClassID != cand[j].ClassID }
+	}
+	pair := func(i, j int) float32 { return rects[i].iou(rects[j]) }
+	if overlap == nil && hasRotated(cand) {
+		pair = func(i, j int) float32 { return BoxOverlap(cand[i], cand[j]) }
+	} else if overlap != nil {
+		pair = func(i, j int) float32 { return overlap(cand[i].Rectangle, cand[j].Rectangle) }
+	}
+	keep := suppress(rects, canonical, opt.IoUThreshold, opt.MaxDetections, skip, pair)
+	ret := make([]Box, len(keep))
+	for k, i := range keep {
+		ret[k] = cand[i]
+	}
+	return ret
+}
+
+// BoxIoU is the overlap ratio used by DetectNms, counting pixels inclusively.
+func BoxIoU(a, b image.Rectangle) float32 {
+	xx1 := math.Max(float64(a.Min.X), float64(b.Min.X))
+	yy1 := math.Max(float64(a.Min.Y), float64(b.Min.Y))
+	xx2 := math.Min(float64(a.Max.X), float64(b.Max.X))
+	yy2 := math.Min(float64(a.Max.Y), float64(b.Max.Y))
+	w := math.Max(0, xx2-xx1+1)
+	h := math.Max(0, yy2-yy1+1)
+	inter := float32(w * h)
+	areaA := float32((a.Max.X - a.Min.X + 1) * (a.Max.Y - a.Min.Y + 1))
+	areaB := float32((b.Max.X - b.Min.X + 1) * (b.Max.Y - b.Min.Y + 1))
+	return inter / (areaA + areaB - inter)
+}
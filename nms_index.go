@@ -0,0 +1,173 @@
+package goincv
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// Below this many boxes the grid costs more than it saves.
+const nmsGridMinBoxes = 128
+
+// nmsRect is a box with half open float coordinates and its area, so the
+// inner loop of suppression does no integer conversion. Results are
+// bit-identical to BoxIoU for coordinates below 2^24.
+type nmsRect struct {
+	x0, y0, x1, y1, area float32
+}
+
+// nmsRects converts boxes once. ok is false when a rectangle is not
+// canonical, in which case the spatial index must not be used.
+func nmsRects(n int, rect func(i int) image.Rectangle) (rects []nmsRect, ok bool) {
+	rects = make([]nmsRect, n)
+	ok = true
+	for i := range rects {
+		r := rect(i)
+		if r.Max.X < r.Min.X || r.Max.Y < r.Min.Y {
+			ok = false
+		}
+		x0, y0 := float32(r.Min.X), float32(r.Min.Y)
+		x1, y1 := float32(r.Max.X+1), float32(r.Max.Y+1)
+		rects[i] = nmsRect{x0, y0, x1, y1, (x1 - x0) * (y1 - y0)}
+	}
+	return rects, ok
+}
+
+func (a nmsRect) iou(b nmsRect) float32 {
+	w := min32(a.x1, b.x1) - max32(a.x0, b.x0)
+	h := min32(a.y1, b.y1) - max32(a.y0, b.y0)
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	inter := w * h
+	return inter / (a.area + b.area - inter)
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// bitset marks suppressed boxes.
+type bitset []uint64
+
+func newBitset(n int) bitset    { return make(bitset, (n+63)/64) }
+func (b bitset) has(i int) bool { return b[i>>6]&(1<<(i&63)) != 0 }
+func (b bitset) set(i int)      { b[i>>6] |= 1 << (i & 63) }
+
+// suppress runs greedy suppression over rects in priority order and returns
+// the indices kept, at most limit of them when limit > 0. overlap(i, j)
+// must not exceed zero for disjoint boxes, which holds for IoU, DIoU and
+// CIoU; skip, when not nil, exempts a pair from suppression.
+//
+// With a positive threshold and many boxes only pairs sharing a grid cell
+// are compared, which turns the quadratic loop into roughly linear time for
+// the scattered proposals of a detector.
+func suppress(rects []nmsRect, canonical bool, thresh float32, limit int, skip func(i, j int) bool, overlap func(i, j int) float32) []int {
+	n := len(rects)
+	removed := newBitset(n)
+	keep := []int{}
+	var grid *nmsGrid
+	var seen []int32
+	if canonical && thresh > 0 && n >= nmsGridMinBoxes {
+		grid = newNmsGrid(rects)
+		seen = make([]int32, n)
+	}
+	test := func(i, j int) {
+		if removed.has(j) || (skip != nil && skip(i, j)) {
+			return
+		}
+		if overlap(i, j) >= thresh {
+			removed.set(j)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if removed.has(i) {
+			continue
+		}
+		keep = append(keep, i)
+		if limit > 0 && len(keep) >= limit {
+			break
+		}
+		if grid == nil {
+			for j := i + 1; j < n; j++ {
+				test(i, j)
+			}
+			continue
+		}
+		cx0, cy0, cx1, cy1 := grid.span(rects[i])
+		for cy := cy0; cy <= cy1; cy++ {
+			for cx := cx0; cx <= cx1; cx++ {
+				cell := grid.cells[cy*grid.cols+cx]
+				// cells hold indices in ascending order
+				for _, j := range cell[sort.Search(len(cell), func(k int) bool { return int(cell[k]) > i }):] {
+					// a box spanning several cells is met more than once
+					if seen[j] == int32(i+1) {
+						continue
+					}
+					seen[j] = int32(i + 1)
+					test(i, int(j))
+				}
+			}
+		}
+	}
+	return keep
+}
+
+// nmsGrid buckets boxes into square cells about the size of an average box.
+type nmsGrid struct {
+	x0, y0, cell float32
+	cols, rows   int
+	cells        [][]int32
+}
+
+func newNmsGrid(rects []nmsRect) *nmsGrid {
+	g := &nmsGrid{x0: rects[0].x0, y0: rects[0].y0}
+	x1, y1 := rects[0].x1, rects[0].y1
+	side := float64(0)
+	for _, r := range rects {
+		g.x0, g.y0 = min32(g.x0, r.x0), min32(g.y0, r.y0)
+		x1, y1 = max32(x1, r.x1), max32(y1, r.y1)
+		side += math.Max(float64(r.x1-r.x0), float64(r.y1-r.y0))
+	}
+	w, h := float64(x1-g.x0), float64(y1-g.y0)
+	// keep the number of cells in the order of the number of boxes
+	cell := math.Max(side/float64(len(rects)), math.Sqrt(w*h/float64(4*len(rects))))
+	g.cell = float32(math.Max(1, cell))
+	g.cols = int(w/float64(g.cell)) + 1
+	g.rows = int(h/float64(g.cell)) + 1
+	g.cells = make([][]int32, g.cols*g.rows)
+	for i, r := range rects {
+		cx0, cy0, cx1, cy1 := g.span(r)
+		for cy := cy0; cy <= cy1; cy++ {
+			for cx := cx0; cx <= cx1; cx++ {
+				g.cells[cy*g.cols+cx] = append(g.cells[cy*g.cols+cx], int32(i))
+			}
+		}
+	}
+	return g
+}
+
+// span returns the inclusive range of cells covering the pixels of r.
+func (g *nmsGrid) span(r nmsRect) (cx0, cy0, cx1, cy1 int) {
+	cx0 = int((r.x0 - g.x0) / g.cell)
+	cy0 = int((r.y0 - g.y0) / g.cell)
+	cx1 = int((r.x1 - 1 - g.x0) / g.cell)
+	cy1 = int((r.y1 - 1 - g.y0) / g.cell)
+	if cx1 >= g.cols {
+		cx1 = g.cols - 1
+	}
+	if cy1 >= g.rows {
+		cy1 = g.rows - 1
+	}
+	return
+}
@@ -0,0 +1,127 @@
+package goincv
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// referenceNms is the slice deletion DetectNms used before suppress,
+// returning the indices of the boxes it keeps.
+func referenceNms(boxes []Box, thresh float32) []int {
+	idx := make([]int, len(boxes))
+	vArea := make([]int, len(boxes))
+	for i, b := range boxes {
+		idx[i] = i
+		vArea[i] = (b.Rectangle.Max.X - b.Rectangle.Min.X + 1) * (b.Rectangle.Max.Y - b.Rectangle.Min.Y + 1)
+	}
+	for i := 0; i < len(idx); i++ {
+		for j := i + 1; j < len(idx); {
+			a, b := boxes[idx[i]].Rectangle, boxes[idx[j]].Rectangle
+			xx1 := math.Max(float64(a.Min.X), float64(b.Min.X))
+			yy1 := math.Max(float64(a.Min.Y), float64(b.Min.Y))
+			xx2 := math.Min(float64(a.Max.X), float64(b.Max.X))
+			yy2 := math.Min(float64(a.Max.Y), float64(b.Max.Y))
+			w := math.Max(0, xx2-xx1+1)
+			h := math.Max(0, yy2-yy1+1)
+			inter := float32(w * h)
+			ovr := inter / (float32(vArea[i]) + float32(vArea[j]) - inter)
+			if ovr >= thresh {
+				idx = append(idx[:j], idx[j+1:]...)
+				vArea = append(vArea[:j], vArea[j+1:]...)
+			} else {
+				j++
+			}
+		}
+	}
+	return idx
+}
+
+// randomBoxes scatters n boxes over a 4K frame, with some clusters so
+// suppression has work to do and a few single pixel boxes.
+func randomBoxes(r *rand.Rand, n int) []Box {
+	boxes := make([]Box, n)
+	for i := range boxes {
+		x, y := r.Intn(3840), r.Intn(2160)
+		w, h := 8+r.Intn(200), 8+r.Intn(200)
+		if i > 0 && r.Intn(3) == 0 {
+			// jitter around an earlier box
+			p := boxes[r.Intn(i)].Rectangle
+			x, y = p.Min.X+r.Intn(9)-4, p.Min.Y+r.Intn(9)-4
+			w, h = p.Dx()+r.Intn(9)-4, p.Dy()+r.Intn(9)-4
+		}
+		if r.Intn(50) == 0 {
+			w, h = 0, 0
+		}
+		boxes[i] = Box{Rectangle: image.Rect(x, y, x+w, y+h), Prob: r.Float32(), ClassID: r.Intn(3)}
+	}
+	return boxes
+}
+
+func TestSuppressMatchesReference(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 7, nmsGridMinBoxes - 1, nmsGridMinBoxes, 500, 3000} {
+		for _, thresh := range []float32{0.3, 0.5, 0.7} {
+			boxes := randomBoxes(r, n)
+			want := referenceNms(boxes, thresh)
+			rects, canonical := nmsRects(n, func(i int) image.Rectangle { return boxes[i].Rectangle })
+			if !canonical {
+				t.Fatal("random boxes should be canonical")
+			}
+			iou := func(i, j int) float32 { return rects[i].iou(rects[j]) }
+			for _, grid := range []bool{false, true} {
+				got := suppress(rects, grid, thresh, 0, nil, iou)
+				if len(got) == 0 && len(want) == 0 {
+					continue
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("n=%d thresh=%v grid=%v: kept %d boxes, reference kept %d", n, thresh, grid, len(got), len(want))
+				}
+			}
+		}
+	}
+}
+
+func TestDetectNmsMatchesReference(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	boxes := randomBoxes(r, 2000)
+	// a non canonical box turns the grid off
+	boxes[10].Rectangle = image.Rectangle{Min: image.Pt(50, 50), Max: image.Pt(40, 40)}
+	want := referenceNms(boxes, 0.45)
+	got := DetectNms(append([]Box(nil), boxes...), 0.45)
+	if len(got) != len(want) {
+		t.Fatalf("kept %d boxes, reference kept %d", len(got), len(want))
+	}
+	for k, i := range want {
+		if got[k].Rectangle != boxes[i].Rectangle {
+			t.Fatalf("box %d is %v, reference %v", k, got[k].Rectangle, boxes[i].Rectangle)
+		}
+	}
+}
+
+func BenchmarkDetectNms(b *testing.B) {
+	boxes := randomBoxes(rand.New(rand.NewSource(3)), 10000)
+	work := make([]Box, len(boxes))
+	b.Run("suppress", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			copy(work, boxes)
+			DetectNms(work, 0.45)
+		}
+	})
+	b.Run("reference", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			referenceNms(boxes, 0.45)
+		}
+	})
+}
+
+func BenchmarkNMSEnd(b *testing.B) {
+	m := NewNMS(image.Rect(0, 0, 3840, 2160), 640, 640)
+	m.boxCollection = randomBoxes(rand.New(rand.NewSource(4)), 10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.End()
+	}
+}
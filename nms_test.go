@@ -0,0 +1,83 @@
+package goincv
+
+import (
+	"image"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestMultiClassNms(t *testing.T) {
+	box := func(x, class int, prob float32) Box {
+		return Box{Rectangle: image.Rect(x, 0, x+10, 10), ClassID: class, Prob: prob}
+	}
+	// a and b overlap with IoU 0.69, c is apart
+	a, b, c := box(0, 0, 0.9), box(2, 1, 0.8), box(50, 1, 0.3)
+	cases := []struct {
+		name string
+		in   []Box
+		opt  NMSOptions
+		want []Box
+	}{
+		{"same class", []Box{a, box(2, 0, 0.8), c}, NMSOptions{IoUThreshold: 0.5}, []Box{a, c}},
+		{"class aware", []Box{a, b, c}, NMSOptions{IoUThreshold: 0.5}, []Box{a, b, c}},
+		{"agnostic", []Box{a, b, c}, NMSOptions{IoUThreshold: 0.5, Agnostic: true}, []Box{a, c}},
+		{"high IoU threshold", []Box{a, box(2, 0, 0.8)}, NMSOptions{IoUThreshold: 0.7}, []Box{a, box(2, 0, 0.8)}},
+		{"score threshold", []Box{a, b, c}, NMSOptions{IoUThreshold: 0.5, ScoreThreshold: 0.5}, []Box{a, b}},
+		{"class threshold", []Box{a, b, c}, NMSOptions{IoUThreshold: 0.5, ScoreThreshold: 0.5, ClassThresholds: map[int]float32{1: 0.2, 0: 0.95}}, []Box{b, c}},
+		{"max detections", []Box{c, b, a}, NMSOptions{IoUThreshold: 0.5, MaxDetections: 2}, []Box{a, b}},
+		{"max detections agnostic", []Box{c, b, a}, NMSOptions{IoUThreshold: 0.5, Agnostic: true, MaxDetections: 1}, []Box{a}},
+		{"empty", nil, NMSOptions{IoUThreshold: 0.5}, nil},
+	}
+	for _, c := range cases {
+		got := MultiClassNms(c.in, c.opt)
+		if len(got) != len(c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i].Rectangle != c.want[i].Rectangle || got[i].ClassID != c.want[i].ClassID || got[i].Prob != c.want[i].Prob {
+				t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+
+	got := MultiClassNms([]Box{a, b}, NMSOptions{IoUThreshold: 0.5, Labels: []string{"cat", "dog"}})
+	if got[0].Label != "cat" || got[1].Label != "dog" {
+		t.Errorf("labels %q %q", got[0].Label, got[1].Label)
+	}
+}
+
+// TestNMSEndSingleClass checks that End still gives the results of the
+// sort-then-suppress loop it replaced when every box has the same class.
+func TestNMSEndSingleClass(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	for _, n := range []int{0, 1, 50, 2000} {
+		for _, thresh := range []float32{0.3, 0.6} {
+			boxes := randomBoxes(r, n)
+			for i := range boxes {
+				boxes[i].ClassID = 0
+			}
+			sorted := append([]Box(nil), boxes...)
+			sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Prob > sorted[j].Prob })
+			var want []Box
+			for _, i := range referenceNms(sorted, thresh) {
+				want = append(want, sorted[i])
+			}
+
+			m := NewNMS(image.Rect(0, 0, 3840, 2160), 640, 640)
+			m.NMSThreshold = thresh
+			m.boxCollection = boxes
+			got := m.End()
+			if len(got) != len(want) {
+				t.Fatalf("n=%d thresh=%v: kept %d boxes, want %d", n, thresh, len(got), len(want))
+			}
+			for i := range got {
+				if got[i].Rectangle != want[i].Rectangle || got[i].Prob != want[i].Prob {
+					t.Fatalf("n=%d thresh=%v: box %d is %v, want %v", n, thresh, i, got[i], want[i])
+				}
+			}
+		}
+	}
+}
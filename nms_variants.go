@@ -0,0 +1,267 @@
+package goincv
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+// NMSMethod selects how overlapping boxes are resolved.
+type NMSMethod int
+
+const (
+	NMSHard           NMSMethod = iota // greedy IoU suppression
+	NMSSoftLinear                      // Soft-NMS, scores scaled by 1-IoU above IoUThreshold
+	NMSSoftGaussian                    // Soft-NMS, scores scaled by exp(-IoU²/Sigma)
+	NMSDIoU                            // greedy suppression on IoU minus centre distance penalty
+	NMSCIoU                            // DIoU plus aspect ratio consistency
+	NMSMatrixLinear                    // Matrix NMS, parallel score decay
+	NMSMatrixGaussian                  // Matrix NMS with a gaussian kernel
+	NMSWeightedFusion                  // weighted boxes fusion of one box list
+)
+
+// Soft and matrix methods keep every box with a decayed score; boxes that
+// fall below this are dropped when no ScoreThreshold is given.
+const minDecayedScore = 0.001
+
+type fbox struct {
+	x0, y0, x1, y1 float64
+}
+
+// inclusive pixel coordinates, like BoxIoU
+func toFbox(r image.Rectangle) fbox {
+	return fbox{float64(r.Min.X), float64(r.Min.Y), float64(r.Max.X + 1), float64(r.Max.Y + 1)}
+}
+
+// BoxDIoU is BoxIoU minus the squared centre distance over the squared
+// diagonal of the smallest enclosing box.
+func BoxDIoU(a, b image.Rectangle) float32 {
+	return BoxIoU(a, b) - float32(centerPenalty(toFbox(a), toFbox(b)))
+}
+
+// BoxCIoU adds an aspect ratio consistency term to BoxDIoU.
+func BoxCIoU(a, b image.Rectangle) float32 {
+	iou := float64(BoxIoU(a, b))
+	fa, fb := toFbox(a), toFbox(b)
+	v := math.Atan((fa.x1-fa.x0)/(fa.y1-fa.y0)) - math.Atan((fb.x1-fb.x0)/(fb.y1-fb.y0))
+	v = 4 / (math.Pi * math.Pi) * v * v
+	alpha := 0.0
+	if v > 0 {
+		alpha = v / (1 - iou + v)
+	}
+	return float32(iou - centerPenalty(fa, fb) - alpha*v)
+}
+
+func centerPenalty(a, b fbox) float64 {
+	dx := (a.x0 + a.x1 - b.x0 - b.x1) / 2
+	dy := (a.y0 + a.y1 - b.y0 - b.y1) / 2
+	cw := math.Max(a.x1, b.x1) - math.Min(a.x0, b.x0)
+	ch := math.Max(a.y1, b.y1) - math.Min(a.y0, b.y0)
+	c := cw*cw + ch*ch
+	if c == 0 {
+		return 0
+	}
+	return (dx*dx + dy*dy) / c
+}
+
+func (opt NMSOptions) sigma() float64 {
+	if opt.Sigma <= 0 {
+		return 0.5
+	}
+	return float64(opt.Sigma)
+}
+
+func (opt NMSOptions) decayedThreshold(class int) float32 {
+	if t := opt.threshold(class); t > 0 {
+		return t
+	}
+	return minDecayedScore
+}
+
+// softNms decays the scores of overlapping boxes instead of removing them
+// (Bodla et al. 2017). cand must be sorted.
+func softNms(cand []Box, opt NMSOptions) []Box {
+	boxes := append([]Box(nil), cand...)
+	ret := []Box{}
+	for len(boxes) > 0 {
+		best := 0
+		for i := range boxes {
+			if boxes[i].Prob > boxes[best].Prob {
+				best = i
+			}
+		}
+		top := boxes[best]
+		ret = append(ret, top)
+		boxes = append(boxes[:best], boxes[best+1:]...)
+		if opt.MaxDetections > 0 && len(ret) >= opt.MaxDetections {
+			break
+		}
+		k := 0
+		for _, b := range boxes {
+			if opt.interacts(top, b) {
+				iou := float64(BoxOverlap(top, b))
+				if opt.Method == NMSSoftGaussian {
+					b.Prob *= float32(math.Exp(-iou * iou / opt.sigma()))
+				} else if iou >= float64(opt.IoUThreshold) {
+					b.Prob *= float32(1 - iou)
+				}
+			}
+			if b.Prob >= opt.decayedThreshold(b.ClassID) {
+				boxes[k] = b
+				k++
+			}
+		}
+		boxes = boxes[:k]
+	}
+	return ret
+}
+
+// matrixNms decays every score at once from the IoU matrix (Wang et al.
+// 2020, SOLOv2): a box is penalised by its overlap with higher scoring
+// boxes, compensated by how much those were suppressed themselves.
+func matrixNms(cand []Box, opt NMSOptions) []Box {
+	n := len(cand)
+	iou := make([][]float64, n)
+	cmax := make([]float64, n)
+	for i := range iou {
+		iou[i] = make([]float64, n)
+	}
+	for j := 0; j < n; j++ {
+		for i := 0; i < j; i++ {
+			if opt.interacts(cand[i], cand[j]) {
+				iou[i][j] = float64(BoxOverlap(cand[i], cand[j]))
+				cmax[j] = math.Max(cmax[j], iou[i][j])
+			}
+		}
+	}
+	ret := []Box{}
+	for j := 0; j < n; j++ {
+		decay := 1.0
+		for i := 0; i < j; i++ {
+			var d float64
+			if opt.Method == NMSMatrixGaussian {
+				d = math.Exp(-(iou[i][j]*iou[i][j] - cmax[i]*cmax[i]) / opt.sigma())
+			} else {
+				// a box fully covered by an earlier one would divide by zero
+				d = (1 - iou[i][j]) / math.Max(1-cmax[i], 1e-6)
+			}
+			decay = math.Min(decay, d)
+		}
+		b := cand[j]
+		b.Prob *= float32(decay)
+		if b.Prob >= opt.decayedThreshold(b.ClassID) {
+			ret = append(ret, b)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Prob > ret[j].Prob
+	})
+	return ret
+}
+
+// WeightedBoxesFusion merges the predictions of several models (Solovyev et
+// al. 2021). Boxes matching a cluster with IoU above IoUThreshold are
+// averaged weighted by score instead of being discarded, and clusters found
+// by few models are down-weighted. weights nil gives every model weight 1,
+// otherwise it needs one non-negative weight per model. Fused boxes are
+// axis aligned and carry no Rotated box or Mask.
+func WeightedBoxesFusion(models [][]Box, weights []float32, opt NMSOptions) ([]Box, error) {
+	if weights == nil {
+		weights = make([]float32, len(models))
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	if len(weights) != len(models) {
+		return nil, fmt.Errorf("got %d weights for %d models", len(weights), len(models))
+	}
+	totalWeight := float32(0)
+	for _, w := range weights {
+		if w < 0 || math.IsNaN(float64(w)) {
+			return nil, fmt.Errorf("invalid model weight %v", w)
+		}
+		totalWeight += w
+	}
+	if totalWeight == 0 && len(models) > 0 {
+		return nil, errors.New("model weights sum to zero")
+	}
+	type member struct {
+		box   Box
+		score float32
+	}
+	var all []member
+	for m, boxes := range models {
+		for _, b := range boxes {
+			if b.Prob >= opt.threshold(b.ClassID) {
+				all = append(all, member{b, b.Prob * weights[m]})
+			}
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].score > all[j].score
+	})
+
+	type cluster struct {
+		members []member
+		score   float32
+		rect    image.Rectangle
+	}
+	var clusters []*cluster
+	for _, m := range all {
+		var best *cluster
+		bestIoU := opt.IoUThreshold
+		for _, c := range clusters {
+			if !opt.interacts(c.members[0].box, m.box) {
+				continue
+			}
+			if iou := BoxIoU(c.rect, m.box.Rectangle); iou > bestIoU {
+				best, bestIoU = c, iou
+			}
+		}
+		if best == nil {
+			best = &cluster{}
+			clusters = append(clusters, best)
+		}
+		best.members = append(best.members, m)
+		// score weighted mean of the coordinates
+		var x0, y0, x1, y1 float64
+		var sum float32
+		for _, mm := range best.members {
+			r, s := mm.box.Rectangle, float64(mm.score)
+			x0 += s * float64(r.Min.X)
+			y0 += s * float64(r.Min.Y)
+			x1 += s * float64(r.Max.X)
+			y1 += s * float64(r.Max.Y)
+			sum += mm.score
+		}
+		best.score = sum / float32(len(best.members))
+		if sum > 0 {
+			w := float64(sum)
+			best.rect = image.Rect(int(math.Round(x0/w)), int(math.Round(y0/w)), int(math.Round(x1/w)), int(math.Round(y1/w)))
+		} else {
+			best.rect = best.members[0].box.Rectangle
+		}
+	}
+
+	ret := []Box{}
+	for _, c := range clusters {
+		b := c.members[0].box
+		b.Rectangle = c.rect
+		b.Rotated, b.Mask = nil, nil
+		n := float32(len(c.members))
+		if n > float32(len(weights)) {
+			n = float32(len(weights))
+		}
+		b.Prob = c.score * n / totalWeight
+		ret = append(ret, b)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Prob > ret[j].Prob
+	})
+	if opt.MaxDetections > 0 && len(ret) > opt.MaxDetections {
+		ret = ret[:opt.MaxDetections]
+	}
+	return ret, nil
+}
@@ -0,0 +1,56 @@
+package goincv
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestWeightedBoxesFusion(t *testing.T) {
+	opt := NMSOptions{Method: NMSWeightedFusion, IoUThreshold: 0.5}
+	a := Box{Rectangle: image.Rect(0, 0, 10, 10), Prob: 0.9, Rotated: &RotatedBox{}, Mask: image.NewAlpha(image.Rect(0, 0, 10, 10))}
+	b := Box{Rectangle: image.Rect(2, 2, 12, 12), Prob: 0.9}
+	models := [][]Box{{a}, {b}}
+
+	got, err := WeightedBoxesFusion(models, nil, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d boxes, want 1", len(got))
+	}
+	if got[0].Rectangle != image.Rect(1, 1, 11, 11) {
+		t.Errorf("fused rectangle %v", got[0].Rectangle)
+	}
+	if got[0].Rotated != nil || got[0].Mask != nil {
+		t.Error("fused box keeps the rotated box or mask of a member")
+	}
+	if math.Abs(float64(got[0].Prob-0.9)) > 1e-6 {
+		t.Errorf("fused prob %v, want 0.9", got[0].Prob)
+	}
+
+	for _, weights := range [][]float32{{1}, {1, 1, 1}, {1, -1}, {0, 0}} {
+		if _, err := WeightedBoxesFusion(models, weights, opt); err == nil {
+			t.Errorf("weights %v were accepted", weights)
+		}
+	}
+}
+
+func TestMatrixNmsDuplicates(t *testing.T) {
+	r := image.Rect(0, 0, 10, 10)
+	boxes := []Box{
+		{Rectangle: r, Prob: 0.9},
+		{Rectangle: r, Prob: 0.8},
+		{Rectangle: r, Prob: 0.7},
+		{Rectangle: image.Rect(20, 20, 30, 30), Prob: 0.6},
+	}
+	got := MultiClassNms(boxes, NMSOptions{Method: NMSMatrixLinear, IoUThreshold: 0.5})
+	for _, b := range got {
+		if math.IsNaN(float64(b.Prob)) || math.IsInf(float64(b.Prob), 0) {
+			t.Fatalf("decayed score %v", b.Prob)
+		}
+	}
+	if len(got) != 2 || got[0].Prob != 0.9 || got[1].Prob != 0.6 {
+		t.Errorf("got %v", got)
+	}
+}
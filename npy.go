@@ -0,0 +1,480 @@
+package goincv
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var npyMagic = []byte("\x93NUMPY")
+
+// npyMaxHeader bounds the header length read from a file. numpy itself
+// refuses headers above 10000 bytes unless told otherwise.
+const npyMaxHeader = 1 << 20
+
+var (
+	npyDescrRe   = regexp.MustCompile(`'descr'\s*:\s*'([^']*)'`)
+	npyFortranRe = regexp.MustCompile(`'fortran_order'\s*:\s*(True|False)`)
+	npyShapeRe   = regexp.MustCompile(`'shape'\s*:\s*\(([^)]*)\)`)
+)
+
+// npyDescr maps a DataType to the numpy dtype string. int and uint are
+// written as 64 bit.
+func npyDescr(dt DataType) (string, error) {
+	switch dt {
+	case DataTypeFloat32:
+		return "<f4", nil
+	case DataTypeFloat64:
+		return "<f8", nil
+	case DataTypeFloat16:
+		return "<f2", nil
+	case DataTypeUInt8:
+		return "|u1", nil
+	case DataTypeUInt16:
+		return "<u2", nil
+	case DataTypeUInt32:
+		return "<u4", nil
+	case DataTypeUInt64, DataTypeUInt:
+		return "<u8", nil
+	case DataTypeInt8:
+		return "|i1", nil
+	case DataTypeInt16:
+		return "<i2", nil
+	case DataTypeInt32:
+		return "<i4", nil
+	case DataTypeInt64, DataTypeInt:
+		return "<i8", nil
+	}
+	return "", fmt.Errorf("npy does not support %v", dt)
+}
+
+// npyHeader builds the header the way numpy does, padded with spaces so
+// the data starts on a 64 byte boundary.
+func npyHeader(descr string, shape Shape) []byte {
+	dims := make([]string, len(shape))
+	for i := range shape {
+		dims[i] = strconv.Itoa(shape[i])
+	}
+	shapeStr := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		shapeStr += ","
+	}
+	dict := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%s), }", descr, shapeStr)
+
+	prefix := len(npyMagic) + 2 + 2
+	major := byte(1)
+	if len(dict)+1+prefix > 65535 {
+		major = 2
+		prefix += 2
+	}
+	pad := 64 - (prefix+len(dict)+1)%64
+	if pad == 64 {
+		pad = 0
+	}
+	dict += strings.Repeat(" ", pad) + "\n"
+
+	buf := bytes.NewBuffer(nil)
+	buf.Write(npyMagic)
+	buf.Write([]byte{major, 0})
+	if major == 1 {
+		binary.Write(buf, binary.LittleEndian, uint16(len(dict)))
+	} else {
+		binary.Write(buf, binary.LittleEndian, uint32(len(dict)))
+	}
+	buf.WriteString(dict)
+	return buf.Bytes()
+}
+
+// writeLE writes data in little endian, widening int and uint to 64 bit.
+func writeLE[T Number](w io.Writer, data []T) error {
+	switch d := any(data).(type) {
+	case []int:
+		tmp := make([]int64, len(d))
+		for i := range d {
+			tmp[i] = int64(d[i])
+		}
+		return binary.Write(w, binary.LittleEndian, tmp)
+	case []uint:
+		tmp := make([]uint64, len(d))
+		for i := range d {
+			tmp[i] = uint64(d[i])
+		}
+		return binary.Write(w, binary.LittleEndian, tmp)
+	}
+	return binary.Write(w, binary.LittleEndian, data)
+}
+
+// WriteNpy encodes t in the numpy .npy format.
+func WriteNpy[T Number](w io.Writer, t *Tensor[T]) error {
+	descr, err := npyDescr(t.DataType())
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(npyHeader(descr, t.shape)); err != nil {
+		return err
+	}
+	return writeLE(w, t.Data())
+}
+
+// SaveNpy writes t to a .npy file.
+func SaveNpy[T Number](path string, t *Tensor[T]) error {
+	return writeFileWith(path, func(w io.Writer) error {
+		return WriteNpy(w, t)
+	})
+}
+
+// WriteNpyValue encodes a Value as dtype.
+func WriteNpyValue(w io.Writer, v *Value, dtype DataType) error {
+	t, err := valueToTensorOf(v, dtype)
+	if err != nil {
+		return err
+	}
+	return writeNpyAny(w, t)
+}
+
+func writeNpyAny(w io.Writer, a AnyTensor) error {
+	if t, ok := a.(*HalfTensor[BFloat16]); ok {
+		// numpy has no bfloat16, widen it losslessly
+		a = t.Float32()
+	}
+	descr, err := npyDescr(a.DataType())
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(npyHeader(descr, a.Shape())); err != nil {
+		return err
+	}
+	return writeTensorData(w, a)
+}
+
+// writeTensorData writes the elements of a in little endian row-major order.
+func writeTensorData(w io.Writer, a AnyTensor) error {
+	switch t := a.(type) {
+	case *Tensor[float32]:
+		return writeLE(w, t.Data())
+	case *Tensor[float64]:
+		return writeLE(w, t.Data())
+	case *Tensor[uint8]:
+		return writeLE(w, t.Data())
+	case *Tensor[uint16]:
+		return writeLE(w, t.Data())
+	case *Tensor[uint32]:
+		return writeLE(w, t.Data())
+	case *Tensor[uint64]:
+		return writeLE(w, t.Data())
+	case *Tensor[uint]:
+		return writeLE(w, t.Data())
+	case *Tensor[int8]:
+		return writeLE(w, t.Data())
+	case *Tensor[int16]:
+		return writeLE(w, t.Data())
+	case *Tensor[int32]:
+		return writeLE(w, t.Data())
+	case *Tensor[int64]:
+		return writeLE(w, t.Data())
+	case *Tensor[int]:
+		return writeLE(w, t.Data())
+	case *HalfTensor[Float16]:
+		return binary.Write(w, binary.LittleEndian, t.Data())
+	case *HalfTensor[BFloat16]:
+		return binary.Write(w, binary.LittleEndian, t.Data())
+	}
+	return fmt.Errorf("unsupported data type %v", a.DataType())
+}
+
+// valueToTensorOf converts v to a tensor of the given element type.
+func valueToTensorOf(v *Value, dtype DataType) (AnyTensor, error) {
+	switch dtype {
+	case DataTypeFloat32:
+		return ValueToTensor[float32](v)
+	case DataTypeFloat64:
+		return ValueToTensor[float64](v)
+	case DataTypeUInt8:
+		return ValueToTensor[uint8](v)
+	case DataTypeUInt16:
+		return ValueToTensor[uint16](v)
+	case DataTypeUInt32:
+		return ValueToTensor[uint32](v)
+	case DataTypeUInt64:
+		return ValueToTensor[uint64](v)
+	case DataTypeUInt:
+		return ValueToTensor[uint](v)
+	case DataTypeInt8:
+		return ValueToTensor[int8](v)
+	case DataTypeInt16:
+		return ValueToTensor[int16](v)
+	case DataTypeInt32:
+		return ValueToTensor[int32](v)
+	case DataTypeInt64:
+		return ValueToTensor[int64](v)
+	case DataTypeInt:
+		return ValueToTensor[int](v)
+	}
+	return nil, fmt.Errorf("unsupported data type %v", dtype)
+}
+
+// ReadNpy decodes a .npy stream. The concrete type of the result follows
+// the stored dtype, e.g. '<f4' gives *Tensor[float32].
+func ReadNpy(r io.Reader) (AnyTensor, error) {
+	magic := make([]byte, len(npyMagic)+2)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic[:len(npyMagic)], npyMagic) {
+		return nil, errors.New("not a npy file")
+	}
+	var headerLen int
+	switch magic[len(npyMagic)] {
+	case 1:
+		var l uint16
+		if err := binary.Read(r, binary.LittleEndian, &l); err != nil {
+			return nil, err
+		}
+		headerLen = int(l)
+	case 2, 3:
+		var l uint32
+		if err := binary.Read(r, binary.LittleEndian, &l); err != nil {
+			return nil, err
+		}
+		headerLen = int(l)
+	default:
+		return nil, fmt.Errorf("unsupported npy version %d", magic[len(npyMagic)])
+	}
+	if headerLen > npyMaxHeader {
+		return nil, fmt.Errorf("npy header of %d bytes is too large", headerLen)
+	}
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	descr := npyDescrRe.FindSubmatch(header)
+	fortran := npyFortranRe.FindSubmatch(header)
+	shapeStr := npyShapeRe.FindSubmatch(header)
+	if descr == nil || fortran == nil || shapeStr == nil {
+		return nil, fmt.Errorf("invalid npy header %q", header)
+	}
+	shape := Shape{}
+	for _, s := range strings.Split(string(shapeStr[1]), ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		d, err := strconv.Atoi(s)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid npy shape %q", shapeStr[1])
+		}
+		shape = append(shape, d)
+	}
+	if !npySizeFits(shape) {
+		return nil, fmt.Errorf("npy shape %v is too large", shape)
+	}
+	return readNpyData(bufio.NewReader(r), string(descr[1]), shape, string(fortran[1]) == "True")
+}
+
+// LoadNpy reads a .npy file.
+func LoadNpy(path string) (AnyTensor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadNpy(f)
+}
+
+// npySizeFits reports whether the byte size of shape, for elements of up
+// to 8 bytes, fits in an int.
+func npySizeFits(shape Shape) bool {
+	n := 8
+	for _, d := range shape {
+		if d == 0 {
+			return true
+		}
+		if n > math.MaxInt/d {
+			return false
+		}
+		n *= d
+	}
+	return true
+}
+
+func readNpyData(r io.Reader, descr string, shape Shape, fortran bool) (AnyTensor, error) {
+	if len(descr) < 3 {
+		return nil, fmt.Errorf("unsupported npy dtype %q", descr)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if descr[0] == '>' {
+		order = binary.BigEndian
+	}
+	switch descr[1:] {
+	case "f4":
+		return readNpyTensor[float32](r, order, shape, fortran)
+	case "f8":
+		return readNpyTensor[float64](r, order, shape, fortran)
+	case "f2":
+		bits, err := readNpyTensor[uint16](r, order, shape, fortran)
+		if err != nil {
+			return nil, err
+		}
+		return halfFromBits[Float16](bits), nil
+	case "u1", "b1":
+		return readNpyTensor[uint8](r, order, shape, fortran)
+	case "u2":
+		return readNpyTensor[uint16](r, order, shape, fortran)
+	case "u4":
+		return readNpyTensor[uint32](r, order, shape, fortran)
+	case "u8":
+		return readNpyTensor[uint64](r, order, shape, fortran)
+	case "i1":
+		return readNpyTensor[int8](r, order, shape, fortran)
+	case "i2":
+		return readNpyTensor[int16](r, order, shape, fortran)
+	case "i4":
+		return readNpyTensor[int32](r, order, shape, fortran)
+	case "i8":
+		return readNpyTensor[int64](r, order, shape, fortran)
+	}
+	return nil, fmt.Errorf("unsupported npy dtype %q", descr)
+}
+
+// npyChunk is the number of elements read at a time, so a header claiming
+// a huge shape cannot allocate more than the stream actually holds.
+const npyChunk = 1 << 16
+
+func readNpyTensor[T Number](r io.Reader, order binary.ByteOrder, shape Shape, fortran bool) (*Tensor[T], error) {
+	n := shape.Size()
+	chunk := make([]T, npyChunk)
+	if n < len(chunk) {
+		chunk = chunk[:n]
+	}
+	var data []T
+	for len(data) < n {
+		c := chunk
+		if rest := n - len(data); rest < len(c) {
+			c = c[:rest]
+		}
+		if err := binary.Read(r, order, c); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		data = append(data, c...)
+	}
+	if data == nil {
+		data = []T{}
+	}
+	if !fortran {
+		return TensorFrom(data, shape)
+	}
+	reversed := make(Shape, len(shape))
+	for i := range shape {
+		reversed[i] = shape[len(shape)-1-i]
+	}
+	t, err := TensorFrom(data, reversed)
+	if err != nil {
+		return nil, err
+	}
+	return t.Transpose().Clone(), nil
+}
+
+// WriteNpz stores the arrays as name.npy entries of a zip archive, like
+// np.savez, or np.savez_compressed when compress is set.
+func WriteNpz(w io.Writer, arrays map[string]AnyTensor, compress bool) error {
+	names := make([]string, 0, len(arrays))
+	for name := range arrays {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	method := zip.Store
+	if compress {
+		method = zip.Deflate
+	}
+	zw := zip.NewWriter(w)
+	for _, name := range names {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:   name + ".npy",
+			Method: method,
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeNpyAny(fw, arrays[name]); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return zw.Close()
+}
+
+// SaveNpz writes the arrays to a .npz file.
+func SaveNpz(path string, arrays map[string]AnyTensor, compress bool) error {
+	return writeFileWith(path, func(w io.Writer) error {
+		return WriteNpz(w, arrays, compress)
+	})
+}
+
+// ReadNpz decodes every array of a .npz archive, keyed by name without
+// the .npy suffix.
+func ReadNpz(r io.ReaderAt, size int64) (map[string]AnyTensor, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]AnyTensor{}
+	for _, f := range zr.File {
+		fr, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		t, err := ReadNpy(fr)
+		fr.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		ret[strings.TrimSuffix(f.Name, ".npy")] = t
+	}
+	return ret, nil
+}
+
+// LoadNpz reads a .npz file.
+func LoadNpz(path string) (map[string]AnyTensor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return ReadNpz(f, st.Size())
+}
+
+// writeFileWith creates path and streams the content through a buffer.
+func writeFileWith(path string, fn func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	if err := fn(bw); err != nil {
+		f.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,115 @@
+package goincv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNpyRoundTrip(t *testing.T) {
+	arrays := map[string]AnyTensor{
+		"f4":     MustTensorFrom([]float32{1, -2.5, 3, 4, 5, 6}, Shape{2, 3}),
+		"f8":     MustTensorFrom([]float64{1e-300, 2}, Shape{2}),
+		"u1":     MustTensorFrom([]uint8{0, 255}, Shape{2, 1}),
+		"i2":     MustTensorFrom([]int16{-32768, 32767}, Shape{1, 2}),
+		"i8":     MustTensorFrom([]int64{-1 << 62, 7}, Shape{2}),
+		"u4":     MustTensorFrom([]uint32{1 << 31}, Shape{}),
+		"empty":  MustTensorFrom([]float32{}, Shape{0, 3}),
+		"scalar": MustTensorFrom([]int32{-9}, Shape{}),
+	}
+	for name, a := range arrays {
+		buf := bytes.NewBuffer(nil)
+		if err := writeNpyAny(buf, a); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		descr, _ := npyDescr(a.DataType())
+		if n := len(npyHeader(descr, a.Shape())); n%64 != 0 {
+			t.Errorf("%s: data starts at %d, not on a 64 byte boundary", name, n)
+		}
+		got, err := ReadNpy(buf)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got.DataType() != a.DataType() || !got.Shape().Equal(a.Shape()) || fmt.Sprint(got) != fmt.Sprint(a) {
+			t.Errorf("%s: got %v %v, want %v %v", name, got.DataType(), got, a.DataType(), a)
+		}
+	}
+}
+
+func TestNpyBFloat16(t *testing.T) {
+	h := TensorToBF16(MustTensorFrom([]float32{1, -2, 0.5}, Shape{3}))
+	buf := bytes.NewBuffer(nil)
+	if err := writeNpyAny(buf, h); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadNpy(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, ok := got.(*Tensor[float32])
+	if !ok {
+		t.Fatalf("got %T, want widened float32", got)
+	}
+	if d := f.Data(); d[0] != 1 || d[1] != -2 || d[2] != 0.5 {
+		t.Errorf("got %v", d)
+	}
+}
+
+func TestNpyFortranOrder(t *testing.T) {
+	header := npyHeader("<i4", Shape{2, 3})
+	header = bytes.Replace(header, []byte("'fortran_order': False"), []byte("'fortran_order': True "), 1)
+	buf := bytes.NewBuffer(header)
+	binary.Write(buf, binary.LittleEndian, []int32{1, 4, 2, 5, 3, 6})
+	got, err := ReadNpy(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MustTensorFrom([]int32{1, 2, 3, 4, 5, 6}, Shape{2, 3})
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNpyMalformedHeader(t *testing.T) {
+	v1 := func(dict string) []byte {
+		b := append([]byte(nil), npyMagic...)
+		b = append(b, 1, 0)
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(dict)))
+		return append(b, dict...)
+	}
+	dict := func(descr, shape string) string {
+		return "{'descr': '" + descr + "', 'fortran_order': False, 'shape': (" + shape + "), }\n"
+	}
+	huge := append([]byte(nil), npyMagic...)
+	huge = append(huge, 2, 0)
+	huge = binary.LittleEndian.AppendUint32(huge, 0xffffffff)
+
+	cases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"bad magic", []byte("\x93NUMPZ\x01\x00\x00\x00"), "not a npy file"},
+		{"version", append(append([]byte(nil), npyMagic...), 9, 0, 0, 0), "version"},
+		{"huge header", huge, "too large"},
+		{"short header", v1(dict("<f4", "2,"))[:20], "EOF"},
+		{"no shape", v1("{'descr': '<f4', 'fortran_order': False, }"), "invalid npy header"},
+		{"negative dim", v1(dict("<f4", "-1, 2")), "invalid npy shape"},
+		{"bad dim", v1(dict("<f4", "2x,")), "invalid npy shape"},
+		{"overflow", v1(dict("<f4", "4294967296, 4294967296")), "too large"},
+		{"dtype", v1(dict("<c8", "1,")), "unsupported npy dtype"},
+		{"short data", v1(dict("<f4", "4,")), "EOF"},
+		// the shape is not trusted for the allocation
+		{"huge shape", append(v1(dict("<f4", "4000000000,")), make([]byte, 16)...), "EOF"},
+	}
+	for _, c := range cases {
+		_, err := ReadNpy(bytes.NewReader(c.data))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got %v, want error containing %q", c.name, err, c.want)
+		}
+	}
+}
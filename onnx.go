@@ -0,0 +1,760 @@
+package goincv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterBackend("go", func(path string) (Session, error) {
+		return OpenOnnx(path)
+	})
+}
+
+// OpenOnnx loads an ONNX model and prepares it for the pure Go executor.
+func OpenOnnx(path string) (*GraphSession, error) {
+	g, err := LoadOnnx(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewGraphSession(g)
+}
+
+// LoadOnnx reads an ONNX model file into a Graph. Tensors stored as
+// external data are read relative to the model file.
+func LoadOnnx(path string) (*Graph, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseOnnx(data, filepath.Dir(path))
+}
+
+// ParseOnnx decodes a serialized ONNX ModelProto.
+func ParseOnnx(data []byte) (*Graph, error) {
+	return parseOnnx(data, "")
+}
+
+func parseOnnx(data []byte, dir string) (*Graph, error) {
+	var graph []byte
+	p := &onnxParser{dir: dir}
+	err := pbEach(data, func(field, wire int, r *pbReader) error {
+		if wire != pbBytes || (field != 7 && field != 8) {
+			return r.skip(wire)
+		}
+		b, err := r.bytes()
+		if err != nil {
+			return err
+		}
+		if field == 7 {
+			graph = b
+			return nil
+		}
+		return p.opsetImport(b)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid onnx model: %v", err)
+	}
+	if graph == nil {
+		return nil, errors.New("onnx model has no graph")
+	}
+	g, err := p.graph(graph)
+	if err != nil {
+		return nil, fmt.Errorf("invalid onnx graph: %v", err)
+	}
+	return g, nil
+}
+
+type onnxParser struct {
+	dir   string
+	opset int // version of the default domain, 0 when not imported
+}
+
+// opsetImport reads an OperatorSetIdProto and keeps the version of the
+// default domain.
+func (p *onnxParser) opsetImport(data []byte) error {
+	var (
+		domain  string
+		version uint64
+	)
+	err := pbEach(data, func(field, wire int, r *pbReader) error {
+		var err error
+		switch {
+		case field == 1 && wire == pbBytes:
+			var b []byte
+			b, err = r.bytes()
+			domain = string(b)
+		case field == 2 && wire == pbVarint:
+			version, err = r.varint()
+		default:
+			err = r.skip(wire)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if isDefaultDomain(domain) {
+		p.opset = int(version)
+	}
+	return nil
+}
+
+func isDefaultDomain(domain string) bool {
+	return domain == "" || domain == "ai.onnx"
+}
+
+func (p *onnxParser) graph(data []byte) (*Graph, error) {
+	g := &Graph{Initializers: map[string]AnyTensor{}}
+	var inputs []TensorInfo
+	err := pbEach(data, func(field, wire int, r *pbReader) error {
+		if wire != pbBytes {
+			return r.skip(wire)
+		}
+		b, err := r.bytes()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			n, err := p.node(b)
+			if err != nil {
+				return err
+			}
+			g.Nodes = append(g.Nodes, n)
+		case 5:
+			name, t, err := p.tensor(b)
+			if err != nil {
+				return err
+			}
+			g.Initializers[name] = t
+		case 11:
+			info, err := p.valueInfo(b)
+			if err != nil {
+				return err
+			}
+			inputs = append(inputs, info)
+		case 12:
+			info, err := p.valueInfo(b)
+			if err != nil {
+				return err
+			}
+			g.Outputs = append(g.Outputs, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// older exporters also list every initializer as a graph input
+	for _, in := range inputs {
+		if _, ok := g.Initializers[in.Name]; !ok {
+			g.Inputs = append(g.Inputs, in)
+		}
+	}
+	return g, nil
+}
+
+func (p *onnxParser) node(data []byte) (Node, error) {
+	n := Node{Attrs: Attrs{}}
+	domain := ""
+	err := pbEach(data, func(field, wire int, r *pbReader) error {
+		if wire != pbBytes {
+			return r.skip(wire)
+		}
+		b, err := r.bytes()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			n.Inputs = append(n.Inputs, string(b))
+		case 2:
+			n.Outputs = append(n.Outputs, string(b))
+		case 3:
+			n.Name = string(b)
+		case 4:
+			n.Op = string(b)
+		case 5:
+			return p.attribute(b, n.Attrs)
+		case 7:
+			domain = string(b)
+		}
+		return nil
+	})
+	if isDefaultDomain(domain) {
+		n.Opset = p.opset
+	}
+	return n, err
+}
+
+func (p *onnxParser) attribute(data []byte, attrs Attrs) error {
+	var (
+		name    string
+		typ     uint64
+		f       float32
+		i       int64
+		s       []byte
+		t       AnyTensor
+		floats  []float32
+		ints    []int64
+		strings []string
+	)
+	err := pbEach(data, func(field, wire int, r *pbReader) error {
+		var err error
+		switch field {
+		case 1:
+			var b []byte
+			b, err = r.bytes()
+			name = string(b)
+		case 2:
+			var v uint32
+			v, err = r.fixed32()
+			f = math.Float32frombits(v)
+		case 3:
+			var v uint64
+			v, err = r.varint()
+			i = int64(v)
+		case 4:
+			s, err = r.bytes()
+		case 5:
+			var b []byte
+			if b, err = r.bytes(); err == nil {
+				_, t, err = p.tensor(b)
+			}
+		case 7:
+			err = r.floats(wire, &floats)
+		case 8:
+			err = r.int64s(wire, &ints)
+		case 9:
+			var b []byte
+			b, err = r.bytes()
+			strings = append(strings, string(b))
+		case 20:
+			typ, err = r.varint()
+		default:
+			err = r.skip(wire)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// AttributeProto.AttributeType
+	switch typ {
+	case 1:
+		attrs[name] = f
+	case 2:
+		attrs[name] = int(i)
+	case 3:
+		attrs[name] = string(s)
+	case 4:
+		attrs[name] = t
+	case 6:
+		attrs[name] = floats
+	case 7:
+		ret := make([]int, len(ints))
+		for k := range ints {
+			ret[k] = int(ints[k])
+		}
+		attrs[name] = ret
+	case 8:
+		attrs[name] = strings
+	}
+	return nil
+}
+
+func (p *onnxParser) valueInfo(data []byte) (TensorInfo, error) {
+	info := TensorInfo{}
+	err := pbEach(data, func(field, wire int, r *pbReader) error {
+		if wire != pbBytes {
+			return r.skip(wire)
+		}
+		b, err := r.bytes()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			info.Name = string(b)
+		case 2:
+			// TypeProto.tensor_type
+			return pbEach(b, func(field, wire int, r *pbReader) error {
+				if field != 1 || wire != pbBytes {
+					return r.skip(wire)
+				}
+				tt, err := r.bytes()
+				if err != nil {
+					return err
+				}
+				return p.tensorType(tt, &info)
+			})
+		}
+		return nil
+	})
+	return info, err
+}
+
+func (p *onnxParser) tensorType(data []byte, info *TensorInfo) error {
+	return pbEach(data, func(field, wire int, r *pbReader) error {
+		switch field {
+		case 1:
+			v, err := r.varint()
+			info.DataType = onnxDataTypes[int(v)]
+			return err
+		case 2:
+			shape, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			info.Shape = Shape{}
+			return pbEach(shape, func(field, wire int, r *pbReader) error {
+				if field != 1 {
+					return r.skip(wire)
+				}
+				dim, err := r.bytes()
+				if err != nil {
+					return err
+				}
+				d := -1 // dim_param or unknown
+				err = pbEach(dim, func(field, wire int, r *pbReader) error {
+					if field == 1 && wire == pbVarint {
+						v, err := r.varint()
+						d = int(v)
+						return err
+					}
+					return r.skip(wire)
+				})
+				info.Shape = append(info.Shape, d)
+				return err
+			})
+		}
+		return r.skip(wire)
+	})
+}
+
+// onnxDataTypes maps TensorProto.DataType to DataType.
+var onnxDataTypes = map[int]DataType{
+	1:  DataTypeFloat32,
+	2:  DataTypeUInt8,
+	3:  DataTypeInt8,
+	4:  DataTypeUInt16,
+	5:  DataTypeInt16,
+	6:  DataTypeInt32,
+	7:  DataTypeInt64,
+	9:  DataTypeUInt8, // bool
+	10: DataTypeFloat16,
+	11: DataTypeFloat64,
+	12: DataTypeUInt32,
+	13: DataTypeUInt64,
+	16: DataTypeBFloat16,
+}
+
+func (p *onnxParser) tensor(data []byte) (string, AnyTensor, error) {
+	var (
+		name     string
+		dims     []int64
+		dtype    uint64
+		raw      []byte
+		floats   []float32
+		doubles  []float64
+		int32s   []int64
+		int64s   []int64
+		uint64s  []int64
+		external = map[string]string{}
+	)
+	err := pbEach(data, func(field, wire int, r *pbReader) error {
+		var err error
+		switch field {
+		case 1:
+			err = r.int64s(wire, &dims)
+		case 2:
+			dtype, err = r.varint()
+		case 4:
+			err = r.floats(wire, &floats)
+		case 5:
+			err = r.int64s(wire, &int32s)
+		case 7:
+			err = r.int64s(wire, &int64s)
+		case 8:
+			var b []byte
+			b, err = r.bytes()
+			name = string(b)
+		case 9:
+			raw, err = r.bytes()
+		case 10:
+			err = r.doubles(wire, &doubles)
+		case 11:
+			err = r.int64s(wire, &uint64s)
+		case 13:
+			var b []byte
+			if b, err = r.bytes(); err == nil {
+				err = pbStringEntry(b, external)
+			}
+		default:
+			err = r.skip(wire)
+		}
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	shape := make(Shape, len(dims))
+	for i := range dims {
+		if dims[i] < 0 {
+			return "", nil, fmt.Errorf("tensor %s: invalid shape %v", name, dims)
+		}
+		shape[i] = int(dims[i])
+	}
+	if loc, ok := external["location"]; ok {
+		if raw, err = p.external(loc, external); err != nil {
+			return "", nil, fmt.Errorf("tensor %s: %v", name, err)
+		}
+	}
+	// the shape is checked against the data before anything is allocated
+	var t AnyTensor
+	if raw != nil {
+		if is := onnxItemSize(int(dtype)); is > 0 && !onnxSizeMatches(shape, is, len(raw)) {
+			return "", nil, fmt.Errorf("tensor %s: %d bytes do not match shape %v", name, len(raw), shape)
+		}
+		t, err = onnxRawTensor(int(dtype), shape, raw)
+	} else {
+		// a valid tensor fills only one of the typed fields
+		n := len(floats) + len(doubles) + len(int32s) + len(int64s) + len(uint64s)
+		if !onnxSizeMatches(shape, 1, n) {
+			return "", nil, fmt.Errorf("tensor %s: %d values do not match shape %v", name, n, shape)
+		}
+		t, err = onnxTypedTensor(int(dtype), shape, floats, doubles, int32s, int64s, uint64s)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("tensor %s: %v", name, err)
+	}
+	return name, t, nil
+}
+
+func (p *onnxParser) external(loc string, entry map[string]string) ([]byte, error) {
+	if p.dir == "" {
+		return nil, errors.New("external data needs LoadOnnx")
+	}
+	// the location is untrusted and must stay inside the model directory
+	clean := filepath.Clean(loc)
+	if filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("external data location %q is outside the model directory", loc)
+	}
+	f, err := os.Open(filepath.Join(p.dir, clean))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var offset int64
+	if o, ok := entry["offset"]; ok {
+		if offset, err = strconv.ParseInt(o, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid external data offset: %v", err)
+		}
+	}
+	if offset < 0 || offset > st.Size() {
+		return nil, fmt.Errorf("external data offset %d is outside %s", offset, loc)
+	}
+	n := st.Size() - offset
+	if l, ok := entry["length"]; ok {
+		if n, err = strconv.ParseInt(l, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid external data length: %v", err)
+		}
+		if n < 0 || n > st.Size()-offset {
+			return nil, fmt.Errorf("external data length %d at offset %d is outside %s", n, offset, loc)
+		}
+	}
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, offset); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// onnxItemSize is the raw_data size of one element, 0 for unsupported
+// types.
+func onnxItemSize(dtype int) int {
+	switch dtype {
+	case 2, 3, 9:
+		return 1
+	case 4, 5, 10, 16:
+		return 2
+	case 1, 6, 12:
+		return 4
+	case 7, 11, 13:
+		return 8
+	}
+	return 0
+}
+
+// onnxSizeMatches reports whether shape holds exactly n bytes of elements
+// of the given size, without overflowing on huge dimensions.
+func onnxSizeMatches(shape Shape, itemSize, n int) bool {
+	size := itemSize
+	for _, d := range shape {
+		if d == 0 {
+			return n == 0
+		}
+	}
+	for _, d := range shape {
+		if size > n/d {
+			return false
+		}
+		size *= d
+	}
+	return size == n
+}
+
+func onnxRawTensor(dtype int, shape Shape, raw []byte) (AnyTensor, error) {
+	switch dtype {
+	case 1:
+		return decodeLE[float32](raw, shape)
+	case 2, 9:
+		return decodeLE[uint8](raw, shape)
+	case 3:
+		return decodeLE[int8](raw, shape)
+	case 4:
+		return decodeLE[uint16](raw, shape)
+	case 5:
+		return decodeLE[int16](raw, shape)
+	case 6:
+		return decodeLE[int32](raw, shape)
+	case 7:
+		return decodeLE[int64](raw, shape)
+	case 10:
+		return decodeHalfLE[Float16](raw, shape)
+	case 11:
+		return decodeLE[float64](raw, shape)
+	case 12:
+		return decodeLE[uint32](raw, shape)
+	case 13:
+		return decodeLE[uint64](raw, shape)
+	case 16:
+		return decodeHalfLE[BFloat16](raw, shape)
+	}
+	return nil, fmt.Errorf("unsupported onnx data type %d", dtype)
+}
+
+// onnxTypedTensor builds a tensor from the typed repeated fields. Small
+// integer types, float16 and bool are all stored in int32_data.
+func onnxTypedTensor(dtype int, shape Shape, floats []float32, doubles []float64, int32s, int64s, uint64s []int64) (AnyTensor, error) {
+	switch dtype {
+	case 1:
+		return TensorFrom(floats, shape)
+	case 11:
+		return TensorFrom(doubles, shape)
+	case 7:
+		return TensorFrom(int64s, shape)
+	case 13:
+		return fromInt64s[uint64](uint64s, shape)
+	case 12:
+		return fromInt64s[uint32](uint64s, shape)
+	case 6:
+		return fromInt64s[int32](int32s, shape)
+	case 5:
+		return fromInt64s[int16](int32s, shape)
+	case 4:
+		return fromInt64s[uint16](int32s, shape)
+	case 3:
+		return fromInt64s[int8](int32s, shape)
+	case 2, 9:
+		return fromInt64s[uint8](int32s, shape)
+	case 10:
+		bits, err := fromInt64s[uint16](int32s, shape)
+		if err != nil {
+			return nil, err
+		}
+		return halfFromBits[Float16](bits), nil
+	case 16:
+		bits, err := fromInt64s[uint16](int32s, shape)
+		if err != nil {
+			return nil, err
+		}
+		return halfFromBits[BFloat16](bits), nil
+	}
+	return nil, fmt.Errorf("unsupported onnx data type %d", dtype)
+}
+
+func fromInt64s[T Number](v []int64, shape Shape) (*Tensor[T], error) {
+	data := make([]T, len(v))
+	for i := range v {
+		data[i] = T(v[i])
+	}
+	return TensorFrom(data, shape)
+}
+
+// Protobuf wire format, only what the ONNX messages need.
+const (
+	pbVarint  = 0
+	pbFixed64 = 1
+	pbBytes   = 2
+	pbFixed32 = 5
+)
+
+type pbReader struct {
+	buf []byte
+	pos int
+}
+
+var errPbTruncated = errors.New("truncated protobuf message")
+
+func (r *pbReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, errPbTruncated
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *pbReader) bytes() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.buf)-r.pos) {
+		return nil, errPbTruncated
+	}
+	b := r.buf[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+func (r *pbReader) fixed32() (uint32, error) {
+	if len(r.buf)-r.pos < 4 {
+		return 0, errPbTruncated
+	}
+	v := binary.LittleEndian.Uint32(r.buf[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+func (r *pbReader) fixed64() (uint64, error) {
+	if len(r.buf)-r.pos < 8 {
+		return 0, errPbTruncated
+	}
+	v := binary.LittleEndian.Uint64(r.buf[r.pos:])
+	r.pos += 8
+	return v, nil
+}
+
+func (r *pbReader) skip(wire int) error {
+	var err error
+	switch wire {
+	case pbVarint:
+		_, err = r.varint()
+	case pbFixed64:
+		_, err = r.fixed64()
+	case pbBytes:
+		_, err = r.bytes()
+	case pbFixed32:
+		_, err = r.fixed32()
+	default:
+		err = fmt.Errorf("unsupported protobuf wire type %d", wire)
+	}
+	return err
+}
+
+// int64s appends a repeated varint field, packed or not.
+func (r *pbReader) int64s(wire int, dst *[]int64) error {
+	if wire == pbVarint {
+		v, err := r.varint()
+		*dst = append(*dst, int64(v))
+		return err
+	}
+	b, err := r.bytes()
+	if err != nil {
+		return err
+	}
+	pr := &pbReader{buf: b}
+	for pr.pos < len(b) {
+		v, err := pr.varint()
+		if err != nil {
+			return err
+		}
+		*dst = append(*dst, int64(v))
+	}
+	return nil
+}
+
+func (r *pbReader) floats(wire int, dst *[]float32) error {
+	if wire == pbFixed32 {
+		v, err := r.fixed32()
+		*dst = append(*dst, math.Float32frombits(v))
+		return err
+	}
+	b, err := r.bytes()
+	if err != nil {
+		return err
+	}
+	if len(b)%4 != 0 {
+		return errPbTruncated
+	}
+	for i := 0; i < len(b); i += 4 {
+		*dst = append(*dst, math.Float32frombits(binary.LittleEndian.Uint32(b[i:])))
+	}
+	return nil
+}
+
+func (r *pbReader) doubles(wire int, dst *[]float64) error {
+	if wire == pbFixed64 {
+		v, err := r.fixed64()
+		*dst = append(*dst, math.Float64frombits(v))
+		return err
+	}
+	b, err := r.bytes()
+	if err != nil {
+		return err
+	}
+	if len(b)%8 != 0 {
+		return errPbTruncated
+	}
+	for i := 0; i < len(b); i += 8 {
+		*dst = append(*dst, math.Float64frombits(binary.LittleEndian.Uint64(b[i:])))
+	}
+	return nil
+}
+
+// pbEach calls fn for every field of a message; fn must consume the value.
+func pbEach(buf []byte, fn func(field, wire int, r *pbReader) error) error {
+	r := &pbReader{buf: buf}
+	for r.pos < len(buf) {
+		key, err := r.varint()
+		if err != nil {
+			return err
+		}
+		if err := fn(int(key>>3), int(key&7), r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pbStringEntry(buf []byte, m map[string]string) error {
+	var key, value string
+	err := pbEach(buf, func(field, wire int, r *pbReader) error {
+		if wire != pbBytes {
+			return r.skip(wire)
+		}
+		b, err := r.bytes()
+		if field == 1 {
+			key = string(b)
+		} else if field == 2 {
+			value = string(b)
+		}
+		return err
+	})
+	m[key] = value
+	return err
+}
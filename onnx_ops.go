@@ -0,0 +1,738 @@
+package goincv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/wailovet/goincv/third/gonum.org/v1/gonum/blas"
+	"github.com/wailovet/goincv/third/gonum.org/v1/gonum/blas/blas32"
+)
+
+// CPU kernels for the operators common in small vision models. Convolution
+// and Gemm run on the vendored gonum BLAS; everything works in float32 with
+// NCHW layout.
+func init() {
+	RegisterOp("Conv", opConv)
+	RegisterOp("Gemm", opGemm)
+	RegisterOp("MatMul", opMatMul)
+	RegisterOp("BatchNormalization", opBatchNorm)
+	RegisterOp("MaxPool", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		return opPool(n, in, true)
+	})
+	RegisterOp("AveragePool", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		return opPool(n, in, false)
+	})
+	RegisterOp("GlobalAveragePool", opGlobalPool)
+	RegisterOp("Resize", opResize)
+	RegisterOp("Upsample", opResize)
+	RegisterOp("Constant", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		if t := n.Attrs.Tensor("value"); t != nil {
+			return []AnyTensor{t}, nil
+		}
+		if v, ok := n.Attrs["value_float"].(float32); ok {
+			return []AnyTensor{MustTensorFrom([]float32{v}, Shape{})}, nil
+		}
+		if v, ok := n.Attrs["value_int"].(int); ok {
+			return []AnyTensor{MustTensorFrom([]int64{int64(v)}, Shape{})}, nil
+		}
+		if v := n.Attrs.Ints("value_ints"); v != nil {
+			return []AnyTensor{fromInts(v)}, nil
+		}
+		if v := n.Attrs.Floats("value_floats"); v != nil {
+			return []AnyTensor{MustTensorFrom(v, nil)}, nil
+		}
+		return nil, errors.New("unsupported constant value")
+	})
+	RegisterOp("Clip", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		x, err := opFloat(in, 0)
+		if err != nil {
+			return nil, err
+		}
+		lo := n.Attrs.Float("min", -math.MaxFloat32)
+		hi := n.Attrs.Float("max", math.MaxFloat32)
+		if len(in) > 1 && in[1] != nil {
+			v, _ := opFloat(in, 1)
+			lo = v.Data()[0]
+		}
+		if len(in) > 2 && in[2] != nil {
+			v, _ := opFloat(in, 2)
+			hi = v.Data()[0]
+		}
+		return []AnyTensor{x.Clip(lo, hi)}, nil
+	})
+	RegisterOp("HardSigmoid", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		alpha, beta := n.Attrs.Float("alpha", 0.2), n.Attrs.Float("beta", 0.5)
+		return unaryOp(func(x float32) float32 { return hardSigmoid(x, alpha, beta) })(n, in)
+	})
+	RegisterOp("HardSwish", unaryOp(func(x float32) float32 { return x * hardSigmoid(x, 1.0/6, 0.5) }))
+	// SiLU is exported as Sigmoid followed by Mul, some converters fuse it
+	RegisterOp("SiLU", unaryOp(func(x float32) float32 { return x * float32(sigmoid(float64(x))) }))
+	RegisterOp("Dropout", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		return in[:1], nil
+	})
+	RegisterOp("Cast", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		x, err := opInput(in, 0)
+		if err != nil {
+			return nil, err
+		}
+		switch onnxDataTypes[n.Attrs.Int("to", 1)] {
+		case DataTypeFloat32:
+			ret, err := AsTensor[float32](x)
+			return []AnyTensor{ret}, err
+		case DataTypeInt64:
+			ret, err := AsTensor[int64](x)
+			return []AnyTensor{ret}, err
+		case DataTypeInt32:
+			ret, err := AsTensor[int32](x)
+			return []AnyTensor{ret}, err
+		case DataTypeUInt8:
+			ret, err := AsTensor[uint8](x)
+			return []AnyTensor{ret}, err
+		case DataTypeFloat16:
+			ret, err := AsHalfTensor[Float16](x)
+			return []AnyTensor{ret}, err
+		case DataTypeBFloat16:
+			ret, err := AsHalfTensor[BFloat16](x)
+			return []AnyTensor{ret}, err
+		}
+		return nil, fmt.Errorf("unsupported cast to %d", n.Attrs.Int("to", 1))
+	})
+	RegisterOp("Gather", func(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+		x, err := opShaped(in, 0)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := opInput(in, 1)
+		if err != nil {
+			return nil, err
+		}
+		ret, err := x.gatherAny(n.Attrs.Int("axis", 0), idx)
+		return []AnyTensor{ret}, err
+	})
+	RegisterOp("Slice", opSlice)
+}
+
+func hardSigmoid(x, alpha, beta float32) float32 {
+	v := alpha*x + beta
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+func fromInts(v []int) *Tensor[int64] {
+	ret := NewTensor[int64](Shape{len(v)})
+	for i := range v {
+		ret.data[i] = int64(v[i])
+	}
+	return ret
+}
+
+// gatherAny takes whole slices along axis, numpy's take. The index shape
+// replaces the axis, so a scalar index drops it.
+func (t *Tensor[T]) gatherAny(axis int, indices AnyTensor) (AnyTensor, error) {
+	axis, err := normalizeAxis(axis, len(t.shape))
+	if err != nil {
+		return nil, err
+	}
+	idx, err := AsTensor[int](indices)
+	if err != nil {
+		return nil, err
+	}
+	pick := append([]int(nil), idx.Data()...)
+	for i, v := range pick {
+		if v < 0 {
+			pick[i] = v + t.shape[axis]
+		}
+	}
+	sel, err := t.IndexSelect(axis, pick)
+	if err != nil {
+		return nil, err
+	}
+	shape := append(Shape{}, t.shape[:axis]...)
+	shape = append(shape, idx.shape...)
+	shape = append(shape, t.shape[axis+1:]...)
+	return sel.Reshape(shape)
+}
+
+func (t *Tensor[T]) sliceAny(idx []SliceIndex) (AnyTensor, error) {
+	ret, err := t.Slice(idx...)
+	if err != nil {
+		return nil, err
+	}
+	return ret.Contiguous(), nil
+}
+
+func opSlice(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+	s, err := opShaped(in, 0)
+	if err != nil {
+		return nil, err
+	}
+	starts, ends, axes := n.Attrs.Ints("starts"), n.Attrs.Ints("ends"), n.Attrs.Ints("axes")
+	var steps []int
+	if len(in) > 1 {
+		if starts, err = opInts(in, 1); err != nil {
+			return nil, err
+		}
+		if ends, err = opInts(in, 2); err != nil {
+			return nil, err
+		}
+		if axes, err = opAxes(&Node{}, in, 3); err != nil {
+			return nil, err
+		}
+		if len(in) > 4 && in[4] != nil {
+			if steps, err = opInts(in, 4); err != nil {
+				return nil, err
+			}
+		}
+	}
+	shape := in[0].Shape()
+	idx := make([]SliceIndex, len(shape))
+	for i := range idx {
+		idx[i] = All()
+	}
+	if len(ends) != len(starts) || (axes != nil && len(axes) != len(starts)) || (steps != nil && len(steps) != len(starts)) {
+		return nil, fmt.Errorf("slice wants as many ends, axes and steps as starts, got %d %d %d %d", len(starts), len(ends), len(axes), len(steps))
+	}
+	for i := range starts {
+		axis := i
+		if axes != nil {
+			if axis, err = normalizeAxis(axes[i], len(shape)); err != nil {
+				return nil, err
+			}
+		} else if axis >= len(shape) {
+			return nil, fmt.Errorf("slice has %d starts for %d dims", len(starts), len(shape))
+		}
+		step := 1
+		if steps != nil {
+			step = steps[i]
+		}
+		// exporters use huge values for "to the end", which slice resolution clamps
+		start, end := clampInt(starts[i]), clampInt(ends[i])
+		idx[axis] = SpanStep(start, end, step)
+	}
+	ret, err := s.sliceAny(idx)
+	return []AnyTensor{ret}, err
+}
+
+func clampInt(v int) int {
+	if v > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	if v <= SliceNone {
+		return SliceNone + 1
+	}
+	return v
+}
+
+// convGeometry resolves the output size of one spatial axis.
+func convGeometry(n *Node, in, kernel, axis, nd int) (out, padBegin int) {
+	stride, dilation := 1, 1
+	if s := n.Attrs.Ints("strides"); s != nil {
+		stride = s[axis]
+	}
+	if d := n.Attrs.Ints("dilations"); d != nil {
+		dilation = d[axis]
+	}
+	k := (kernel-1)*dilation + 1
+	switch n.Attrs.String("auto_pad", "NOTSET") {
+	case "SAME_UPPER", "SAME_LOWER":
+		out = (in + stride - 1) / stride
+		total := (out-1)*stride + k - in
+		if total < 0 {
+			total = 0
+		}
+		padBegin = total / 2
+		if n.Attrs.String("auto_pad", "") == "SAME_LOWER" {
+			padBegin = total - total/2
+		}
+		return
+	case "VALID":
+		return (in-k)/stride + 1, 0
+	}
+	padEnd := 0
+	if p := n.Attrs.Ints("pads"); p != nil {
+		padBegin, padEnd = p[axis], p[axis+nd]
+	}
+	span := in + padBegin + padEnd - k
+	if n.Attrs.Int("ceil_mode", 0) == 1 {
+		out = (span+stride-1)/stride + 1
+		// the last window must start inside the input or left padding
+		if (out-1)*stride >= in+padBegin {
+			out--
+		}
+		return
+	}
+	return span/stride + 1, padBegin
+}
+
+func opConv(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+	x, err := opFloat(in, 0)
+	if err != nil {
+		return nil, err
+	}
+	w, err := opFloat(in, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(x.shape) != 4 || len(w.shape) != 4 {
+		return nil, fmt.Errorf("conv supports 2D only, got %v and %v", x.shape, w.shape)
+	}
+	var bias []float32
+	if len(in) > 2 && in[2] != nil {
+		b, err := opFloat(in, 2)
+		if err != nil {
+			return nil, err
+		}
+		bias = b.Data()
+	}
+	batch, c, h, wd := x.shape[0], x.shape[1], x.shape[2], x.shape[3]
+	m, cg, kh, kw := w.shape[0], w.shape[1], w.shape[2], w.shape[3]
+	group := n.Attrs.Int("group", 1)
+	if cg*group != c || m%group != 0 {
+		return nil, fmt.Errorf("conv weight %v does not match input %v with group %d", w.shape, x.shape, group)
+	}
+	oh, ph := convGeometry(n, h, kh, 0, 2)
+	ow, pw := convGeometry(n, wd, kw, 1, 2)
+	sh, sw, dh, dw := 1, 1, 1, 1
+	if s := n.Attrs.Ints("strides"); s != nil {
+		sh, sw = s[0], s[1]
+	}
+	if d := n.Attrs.Ints("dilations"); d != nil {
+		dh, dw = d[0], d[1]
+	}
+
+	xd, wdata := x.Data(), w.Data()
+	ret := NewTensor[float32](Shape{batch, m, oh, ow})
+	mg, k, spatial := m/group, cg*kh*kw, oh*ow
+	direct := kh == 1 && kw == 1 && sh == 1 && sw == 1 && ph == 0 && pw == 0 && oh == h && ow == wd
+	var cols []float32
+	if !direct {
+		cols = make([]float32, k*spatial)
+	}
+	for b := 0; b < batch; b++ {
+		for g := 0; g < group; g++ {
+			src := xd[(b*c+g*cg)*h*wd : (b*c+(g+1)*cg)*h*wd]
+			if direct {
+				cols = src
+			} else {
+				im2col(src, cg, h, wd, kh, kw, ph, pw, sh, sw, dh, dw, oh, ow, cols)
+			}
+			dst := ret.data[(b*m+g*mg)*spatial : (b*m+(g+1)*mg)*spatial]
+			blas32.Gemm(blas.NoTrans, blas.NoTrans, 1,
+				blas32.General{Rows: mg, Cols: k, Stride: k, Data: wdata[g*mg*k : (g+1)*mg*k]},
+				blas32.General{Rows: k, Cols: spatial, Stride: spatial, Data: cols},
+				0, blas32.General{Rows: mg, Cols: spatial, Stride: spatial, Data: dst})
+		}
+		if bias != nil {
+			for o := 0; o < m; o++ {
+				row := ret.data[(b*m+o)*spatial : (b*m+o+1)*spatial]
+				for i := range row {
+					row[i] += bias[o]
+				}
+			}
+		}
+	}
+	return []AnyTensor{ret}, nil
+}
+
+// im2col lays out every receptive field as a column so convolution becomes
+// one matrix product; padded positions are 0.
+func im2col(src []float32, c, h, w, kh, kw, ph, pw, sh, sw, dh, dw, oh, ow int, cols []float32) {
+	i := 0
+	for ch := 0; ch < c; ch++ {
+		plane := src[ch*h*w : (ch+1)*h*w]
+		for ky := 0; ky < kh; ky++ {
+			for kx := 0; kx < kw; kx++ {
+				for oy := 0; oy < oh; oy++ {
+					y := oy*sh - ph + ky*dh
+					row := cols[i : i+ow]
+					i += ow
+					if y < 0 || y >= h {
+						for ox := range row {
+							row[ox] = 0
+						}
+						continue
+					}
+					line := plane[y*w : (y+1)*w]
+					for ox := range row {
+						x := ox*sw - pw + kx*dw
+						if x < 0 || x >= w {
+							row[ox] = 0
+						} else {
+							row[ox] = line[x]
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func opGemm(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+	a, err := opFloat(in, 0)
+	if err != nil {
+		return nil, err
+	}
+	b, err := opFloat(in, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.shape) != 2 || len(b.shape) != 2 {
+		return nil, fmt.Errorf("gemm wants 2D inputs, got %v and %v", a.shape, b.shape)
+	}
+	ta, tb := blas.NoTrans, blas.NoTrans
+	m, k := a.shape[0], a.shape[1]
+	if n.Attrs.Int("transA", 0) == 1 {
+		ta, m, k = blas.Trans, a.shape[1], a.shape[0]
+	}
+	k2, nn := b.shape[0], b.shape[1]
+	if n.Attrs.Int("transB", 0) == 1 {
+		tb, k2, nn = blas.Trans, b.shape[1], b.shape[0]
+	}
+	if k != k2 {
+		return nil, fmt.Errorf("gemm shapes %v and %v do not match", a.shape, b.shape)
+	}
+	alpha, beta := n.Attrs.Float("alpha", 1), n.Attrs.Float("beta", 1)
+	ret := NewTensor[float32](Shape{m, nn})
+	if len(in) > 2 && in[2] != nil && beta != 0 {
+		c, err := opFloat(in, 2)
+		if err != nil {
+			return nil, err
+		}
+		cb, err := c.BroadcastTo(ret.shape)
+		if err != nil {
+			return nil, err
+		}
+		copy(ret.data, cb.Data())
+	} else {
+		beta = 0
+	}
+	blas32.Gemm(ta, tb, alpha,
+		blas32.General{Rows: a.shape[0], Cols: a.shape[1], Stride: a.shape[1], Data: a.Data()},
+		blas32.General{Rows: b.shape[0], Cols: b.shape[1], Stride: b.shape[1], Data: b.Data()},
+		beta, blas32.General{Rows: m, Cols: nn, Stride: nn, Data: ret.data})
+	return []AnyTensor{ret}, nil
+}
+
+// opMatMul replaces the generic MatMul op with a BLAS path for the usual
+// [..., M, K] x [K, N] case.
+func opMatMul(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+	a, err := opFloat(in, 0)
+	if err != nil {
+		return nil, err
+	}
+	b, err := opFloat(in, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.shape) < 2 || len(b.shape) != 2 || a.shape[len(a.shape)-1] != b.shape[0] {
+		ret, err := MatMul(a, b)
+		return []AnyTensor{ret}, err
+	}
+	k, nn := b.shape[0], b.shape[1]
+	m := a.Size() / k
+	shape := append(a.shape[:len(a.shape)-1:len(a.shape)-1], nn)
+	ret := NewTensor[float32](shape)
+	blas32.Gemm(blas.NoTrans, blas.NoTrans, 1,
+		blas32.General{Rows: m, Cols: k, Stride: k, Data: a.Data()},
+		blas32.General{Rows: k, Cols: nn, Stride: nn, Data: b.Data()},
+		0, blas32.General{Rows: m, Cols: nn, Stride: nn, Data: ret.data})
+	return []AnyTensor{ret}, nil
+}
+
+func opBatchNorm(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+	params := make([][]float32, 5)
+	for i := range params {
+		t, err := opFloat(in, i)
+		if err != nil {
+			return nil, err
+		}
+		params[i] = t.Data()
+	}
+	x := params[0]
+	shape := in[0].Shape()
+	if len(shape) < 2 {
+		return nil, fmt.Errorf("batch norm wants NC..., got %v", shape)
+	}
+	scale, bias, mean, variance := params[1], params[2], params[3], params[4]
+	eps := n.Attrs.Float("epsilon", 1e-5)
+	c := shape[1]
+	spatial := Shape(shape[2:]).Size()
+	ret := NewTensor[float32](shape)
+	for i := 0; i < shape[0]*c; i++ {
+		ch := i % c
+		s := scale[ch] / float32(math.Sqrt(float64(variance[ch]+eps)))
+		o := bias[ch] - mean[ch]*s
+		src, dst := x[i*spatial:(i+1)*spatial], ret.data[i*spatial:(i+1)*spatial]
+		for k := range src {
+			dst[k] = src[k]*s + o
+		}
+	}
+	return []AnyTensor{ret}, nil
+}
+
+func opPool(n *Node, in []AnyTensor, max bool) ([]AnyTensor, error) {
+	x, err := opFloat(in, 0)
+	if err != nil {
+		return nil, err
+	}
+	kernel := n.Attrs.Ints("kernel_shape")
+	if len(x.shape) != 4 || len(kernel) != 2 {
+		return nil, fmt.Errorf("pool supports 2D only, got %v", x.shape)
+	}
+	batch, c, h, w := x.shape[0], x.shape[1], x.shape[2], x.shape[3]
+	oh, ph := convGeometry(n, h, kernel[0], 0, 2)
+	ow, pw := convGeometry(n, w, kernel[1], 1, 2)
+	sh, sw := 1, 1
+	if s := n.Attrs.Ints("strides"); s != nil {
+		sh, sw = s[0], s[1]
+	}
+	includePad := n.Attrs.Int("count_include_pad", 0) == 1
+	xd := x.Data()
+	ret := NewTensor[float32](Shape{batch, c, oh, ow})
+	o := 0
+	for p := 0; p < batch*c; p++ {
+		plane := xd[p*h*w : (p+1)*h*w]
+		for oy := 0; oy < oh; oy++ {
+			for ox := 0; ox < ow; ox++ {
+				y0, x0 := oy*sh-ph, ox*sw-pw
+				acc := float32(0)
+				if max {
+					acc = float32(math.Inf(-1))
+				}
+				cnt := 0
+				for y := y0; y < y0+kernel[0]; y++ {
+					for xx := x0; xx < x0+kernel[1]; xx++ {
+						if y < 0 || y >= h || xx < 0 || xx >= w {
+							continue
+						}
+						v := plane[y*w+xx]
+						if max {
+							if v > acc {
+								acc = v
+							}
+						} else {
+							acc += v
+						}
+						cnt++
+					}
+				}
+				if !max {
+					if includePad {
+						cnt = kernel[0] * kernel[1]
+					}
+					if cnt > 0 {
+						acc /= float32(cnt)
+					}
+				}
+				ret.data[o] = acc
+				o++
+			}
+		}
+	}
+	return []AnyTensor{ret}, nil
+}
+
+func opGlobalPool(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+	x, err := opFloat(in, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(x.shape) < 3 {
+		return nil, fmt.Errorf("global pool wants NC..., got %v", x.shape)
+	}
+	outer := x.shape[0] * x.shape[1]
+	spatial := x.Size() / outer
+	shape := Shape{x.shape[0], x.shape[1]}
+	for range x.shape[2:] {
+		shape = append(shape, 1)
+	}
+	xd := x.Data()
+	ret := NewTensor[float32](shape)
+	for i := 0; i < outer; i++ {
+		sum := float32(0)
+		for _, v := range xd[i*spatial : (i+1)*spatial] {
+			sum += v
+		}
+		ret.data[i] = sum / float32(spatial)
+	}
+	return []AnyTensor{ret}, nil
+}
+
+// opResize handles Resize (opset 10+) and Upsample on the last two axes
+// with nearest or (bi)linear interpolation.
+func opResize(n *Node, in []AnyTensor) ([]AnyTensor, error) {
+	x, err := opFloat(in, 0)
+	if err != nil {
+		return nil, err
+	}
+	nd := len(x.shape)
+	if nd < 2 {
+		return nil, fmt.Errorf("resize wants at least 2 axes, got %v", x.shape)
+	}
+	// Resize-10 and Upsample take scales as input 1, Resize-11+ as input 2
+	// after roi, with sizes as input 3
+	var scales []float32
+	var sizes []int
+	switch {
+	case len(in) > 3 && in[3] != nil && in[3].Size() > 0:
+		if sizes, err = opInts(in, 3); err != nil {
+			return nil, err
+		}
+	case len(in) > 2 && in[2] != nil && in[2].Size() > 0:
+		s, err := opFloat(in, 2)
+		if err != nil {
+			return nil, err
+		}
+		scales = s.Data()
+	case n.Op == "Upsample" || len(in) == 2:
+		if len(in) > 1 && in[1] != nil {
+			s, err := opFloat(in, 1)
+			if err != nil {
+				return nil, err
+			}
+			scales = s.Data()
+		} else {
+			scales = n.Attrs.Floats("scales")
+		}
+	}
+	out := x.shape.Clone()
+	fy, fx := float32(1), float32(1)
+	switch {
+	case sizes != nil:
+		if len(sizes) != nd {
+			return nil, fmt.Errorf("resize sizes %v do not match %v", sizes, x.shape)
+		}
+		copy(out, sizes)
+		fy = float32(out[nd-2]) / float32(x.shape[nd-2])
+		fx = float32(out[nd-1]) / float32(x.shape[nd-1])
+	case scales != nil:
+		if len(scales) != nd {
+			return nil, fmt.Errorf("resize scales %v do not match %v", scales, x.shape)
+		}
+		for i := range out {
+			out[i] = int(float32(x.shape[i]) * scales[i])
+		}
+		fy, fx = scales[nd-2], scales[nd-1]
+	default:
+		return nil, errors.New("resize needs scales or sizes")
+	}
+	if !Shape(out[:nd-2]).Equal(x.shape[:nd-2]) {
+		return nil, fmt.Errorf("resize only scales the last two axes, got %v to %v", x.shape, out)
+	}
+
+	mode := n.Attrs.String("mode", "nearest")
+	if mode != "nearest" && mode != "linear" && mode != "bilinear" {
+		return nil, fmt.Errorf("unsupported resize mode %q", mode)
+	}
+	ctm := n.Attrs.String("coordinate_transformation_mode", "half_pixel")
+	nearest := n.Attrs.String("nearest_mode", "round_prefer_floor")
+	// Upsample and Resize-10 predate both attributes: coordinates are
+	// asymmetric and nearest truncates when enlarging, as in onnxruntime
+	legacy := n.Op == "Upsample" || len(in) == 2
+	if legacy {
+		ctm = "asymmetric"
+	}
+	h, w, oh, ow := x.shape[nd-2], x.shape[nd-1], out[nd-2], out[nd-1]
+	ys := resizeAxis(h, oh, fy, ctm)
+	xs := resizeAxis(w, ow, fx, ctm)
+	nearestY, nearestX := nearest, nearest
+	if legacy {
+		nearestY, nearestX = legacyNearest(fy), legacyNearest(fx)
+	}
+	xd := x.Data()
+	ret := NewTensor[float32](out)
+	planes := x.Size() / (h * w)
+	for p := 0; p < planes; p++ {
+		src := xd[p*h*w : (p+1)*h*w]
+		dst := ret.data[p*oh*ow : (p+1)*oh*ow]
+		for oy := 0; oy < oh; oy++ {
+			for ox := 0; ox < ow; ox++ {
+				var v float32
+				if mode == "nearest" {
+					y := nearestIndex(ys[oy], h, nearestY)
+					xx := nearestIndex(xs[ox], w, nearestX)
+					v = src[y*w+xx]
+				} else {
+					v = bilinear(src, h, w, ys[oy], xs[ox])
+				}
+				dst[oy*ow+ox] = v
+			}
+		}
+	}
+	return []AnyTensor{ret}, nil
+}
+
+// resizeAxis maps every output coordinate to the input coordinate system.
+func resizeAxis(in, out int, scale float32, mode string) []float32 {
+	ret := make([]float32, out)
+	for i := range ret {
+		o := float32(i)
+		switch mode {
+		case "asymmetric":
+			ret[i] = o / scale
+		case "align_corners":
+			if out > 1 {
+				ret[i] = o * float32(in-1) / float32(out-1)
+			}
+		case "pytorch_half_pixel":
+			if out > 1 {
+				ret[i] = (o+0.5)/scale - 0.5
+			}
+		default: // half_pixel
+			ret[i] = (o+0.5)/scale - 0.5
+		}
+	}
+	return ret
+}
+
+// legacyNearest is the nearest mode of Upsample and Resize-10 for a scale.
+func legacyNearest(scale float32) string {
+	if scale < 1 {
+		return "ceil"
+	}
+	return "floor"
+}
+
+func nearestIndex(v float32, n int, mode string) int {
+	var i int
+	switch mode {
+	case "floor":
+		i = int(math.Floor(float64(v)))
+	case "ceil":
+		i = int(math.Ceil(float64(v)))
+	case "round_prefer_ceil":
+		i = int(math.Floor(float64(v) + 0.5))
+	default: // round_prefer_floor
+		i = int(math.Ceil(float64(v) - 0.5))
+	}
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+func bilinear(src []float32, h, w int, y, x float32) float32 {
+	y = float32(math.Max(0, math.Min(float64(y), float64(h-1))))
+	x = float32(math.Max(0, math.Min(float64(x), float64(w-1))))
+	y0, x0 := int(y), int(x)
+	y1, x1 := y0+1, x0+1
+	if y1 >= h {
+		y1 = h - 1
+	}
+	if x1 >= w {
+		x1 = w - 1
+	}
+	dy, dx := y-float32(y0), x-float32(x0)
+	top := src[y0*w+x0]*(1-dx) + src[y0*w+x1]*dx
+	bottom := src[y1*w+x0]*(1-dx) + src[y1*w+x1]*dx
+	return top*(1-dy) + bottom*dy
+}
@@ -0,0 +1,208 @@
+package goincv
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestOpResize(t *testing.T) {
+	x2x2 := MustTensorFrom([]float32{1, 2, 3, 4}, Shape{1, 1, 2, 2})
+	x2x4 := MustTensorFrom([]float32{1, 2, 3, 4, 5, 6, 7, 8}, Shape{1, 1, 2, 4})
+	scales := func(s ...float32) AnyTensor { return MustTensorFrom(s, nil) }
+	roi := MustTensorFrom([]float32{}, nil)
+	cases := []struct {
+		name  string
+		node  Node
+		in    []AnyTensor
+		shape Shape
+		want  []float32
+	}{
+		{
+			// nearest upsampling truncates: 0,0,0,1,1,1 across
+			name:  "upsample nearest",
+			node:  Node{Op: "Upsample", Attrs: Attrs{"mode": "nearest"}},
+			in:    []AnyTensor{x2x2, scales(1, 1, 2, 3)},
+			shape: Shape{1, 1, 4, 6},
+			want: []float32{
+				1, 1, 1, 2, 2, 2,
+				1, 1, 1, 2, 2, 2,
+				3, 3, 3, 4, 4, 4,
+				3, 3, 3, 4, 4, 4,
+			},
+		},
+		{
+			name:  "resize-10 nearest downsample",
+			node:  Node{Op: "Resize", Attrs: Attrs{"mode": "nearest"}},
+			in:    []AnyTensor{x2x4, scales(1, 1, 0.6, 0.6)},
+			shape: Shape{1, 1, 1, 2},
+			want:  []float32{1, 3},
+		},
+		{
+			name:  "resize-10 linear is asymmetric",
+			node:  Node{Op: "Resize", Attrs: Attrs{"mode": "linear"}},
+			in:    []AnyTensor{x2x2, scales(1, 1, 2, 2)},
+			shape: Shape{1, 1, 4, 4},
+			want: []float32{
+				1, 1.5, 2, 2,
+				2, 2.5, 3, 3,
+				3, 3.5, 4, 4,
+				3, 3.5, 4, 4,
+			},
+		},
+		{
+			name:  "linear half_pixel",
+			node:  Node{Op: "Resize", Attrs: Attrs{"mode": "linear"}},
+			in:    []AnyTensor{x2x2, roi, scales(1, 1, 2, 2)},
+			shape: Shape{1, 1, 4, 4},
+			want: []float32{
+				1, 1.25, 1.75, 2,
+				1.5, 1.75, 2.25, 2.5,
+				2.5, 2.75, 3.25, 3.5,
+				3, 3.25, 3.75, 4,
+			},
+		},
+		{
+			name:  "linear align_corners",
+			node:  Node{Op: "Resize", Attrs: Attrs{"mode": "linear", "coordinate_transformation_mode": "align_corners"}},
+			in:    []AnyTensor{x2x2, roi, scales(1, 1, 2, 2)},
+			shape: Shape{1, 1, 4, 4},
+			want: []float32{
+				1, 4.0 / 3, 5.0 / 3, 2,
+				5.0 / 3, 2, 7.0 / 3, 8.0 / 3,
+				7.0 / 3, 8.0 / 3, 3, 10.0 / 3,
+				3, 10.0 / 3, 11.0 / 3, 4,
+			},
+		},
+		{
+			name:  "nearest sizes",
+			node:  Node{Op: "Resize", Attrs: Attrs{"mode": "nearest"}},
+			in:    []AnyTensor{x2x2, roi, nil, MustTensorFrom([]int64{1, 1, 7, 8}, nil)},
+			shape: Shape{1, 1, 7, 8},
+			want: []float32{
+				1, 1, 1, 1, 2, 2, 2, 2,
+				1, 1, 1, 1, 2, 2, 2, 2,
+				1, 1, 1, 1, 2, 2, 2, 2,
+				1, 1, 1, 1, 2, 2, 2, 2,
+				3, 3, 3, 3, 4, 4, 4, 4,
+				3, 3, 3, 3, 4, 4, 4, 4,
+				3, 3, 3, 3, 4, 4, 4, 4,
+			},
+		},
+	}
+	for _, c := range cases {
+		out, err := opResize(&c.node, c.in)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		got := out[0].(*Tensor[float32])
+		if !got.Shape().Equal(c.shape) {
+			t.Errorf("%s: shape %v, want %v", c.name, got.Shape(), c.shape)
+			continue
+		}
+		for i, v := range got.Data() {
+			if math.Abs(float64(v-c.want[i])) > 1e-5 {
+				t.Errorf("%s: element %d = %v, want %v", c.name, i, v, c.want[i])
+				break
+			}
+		}
+	}
+
+	for _, mode := range []string{"cubic", "area"} {
+		n := &Node{Op: "Resize", Attrs: Attrs{"mode": mode}}
+		if _, err := opResize(n, []AnyTensor{x2x2, roi, scales(1, 1, 2, 2)}); err == nil {
+			t.Errorf("mode %s was accepted", mode)
+		}
+	}
+}
+
+// naiveConv is the textbook 2D convolution with explicit padding.
+func naiveConv(x, w []float32, bias []float32, n, c, h, wd, m, kh, kw, group int, pads, strides, dilations [2]int, oh, ow int) []float32 {
+	out := make([]float32, n*m*oh*ow)
+	cg, mg := c/group, m/group
+	for b := 0; b < n; b++ {
+		for o := 0; o < m; o++ {
+			g := o / mg
+			for oy := 0; oy < oh; oy++ {
+				for ox := 0; ox < ow; ox++ {
+					var s float32
+					if bias != nil {
+						s = bias[o]
+					}
+					for ci := 0; ci < cg; ci++ {
+						for ky := 0; ky < kh; ky++ {
+							for kx := 0; kx < kw; kx++ {
+								y := oy*strides[0] - pads[0] + ky*dilations[0]
+								xx := ox*strides[1] - pads[1] + kx*dilations[1]
+								if y < 0 || y >= h || xx < 0 || xx >= wd {
+									continue
+								}
+								s += x[((b*c+g*cg+ci)*h+y)*wd+xx] * w[((o*cg+ci)*kh+ky)*kw+kx]
+							}
+						}
+					}
+					out[((b*m+o)*oh+oy)*ow+ox] = s
+				}
+			}
+		}
+	}
+	return out
+}
+
+func TestOpConvMatchesNaive(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func(n int) []float32 {
+		v := make([]float32, n)
+		for i := range v {
+			v[i] = r.Float32()*2 - 1
+		}
+		return v
+	}
+	cases := []struct {
+		c, h, w, m, kh, kw, group int
+		pads, strides, dilations  [2]int
+		bias                      bool
+	}{
+		{c: 3, h: 8, w: 9, m: 4, kh: 3, kw: 3, group: 1, pads: [2]int{1, 1}, strides: [2]int{1, 1}, dilations: [2]int{1, 1}, bias: true},
+		{c: 2, h: 7, w: 7, m: 2, kh: 1, kw: 1, group: 1, strides: [2]int{1, 1}, dilations: [2]int{1, 1}},
+		{c: 4, h: 10, w: 6, m: 6, kh: 3, kw: 2, group: 2, pads: [2]int{2, 0}, strides: [2]int{2, 1}, dilations: [2]int{1, 2}, bias: true},
+		{c: 4, h: 9, w: 9, m: 4, kh: 3, kw: 3, group: 4, pads: [2]int{1, 1}, strides: [2]int{2, 2}, dilations: [2]int{2, 2}},
+	}
+	for i, c := range cases {
+		const batch = 2
+		x := random(batch * c.c * c.h * c.w)
+		w := random(c.m * c.c / c.group * c.kh * c.kw)
+		in := []AnyTensor{
+			MustTensorFrom(x, Shape{batch, c.c, c.h, c.w}),
+			MustTensorFrom(w, Shape{c.m, c.c / c.group, c.kh, c.kw}),
+		}
+		var bias []float32
+		if c.bias {
+			bias = random(c.m)
+			in = append(in, MustTensorFrom(bias, nil))
+		}
+		node := &Node{Op: "Conv", Attrs: Attrs{
+			"group":     c.group,
+			"pads":      []int{c.pads[0], c.pads[1], c.pads[0], c.pads[1]},
+			"strides":   []int{c.strides[0], c.strides[1]},
+			"dilations": []int{c.dilations[0], c.dilations[1]},
+		}}
+		out, err := opConv(node, in)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		oh := (c.h+2*c.pads[0]-(c.kh-1)*c.dilations[0]-1)/c.strides[0] + 1
+		ow := (c.w+2*c.pads[1]-(c.kw-1)*c.dilations[1]-1)/c.strides[1] + 1
+		got := out[0].(*Tensor[float32])
+		if !got.Shape().Equal(Shape{batch, c.m, oh, ow}) {
+			t.Fatalf("case %d: shape %v, want %v", i, got.Shape(), Shape{batch, c.m, oh, ow})
+		}
+		want := naiveConv(x, w, bias, batch, c.c, c.h, c.w, c.m, c.kh, c.kw, c.group, c.pads, c.strides, c.dilations, oh, ow)
+		for j, v := range got.Data() {
+			if math.Abs(float64(v-want[j])) > 1e-4 {
+				t.Fatalf("case %d: element %d = %v, want %v", i, j, v, want[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package goincv
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOnnxExternalData(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "weights.bin"), []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	p := &onnxParser{dir: filepath.Join(dir, "sub")}
+	if _, err := p.external("../weights.bin", map[string]string{}); err == nil {
+		t.Error("location outside the model directory was read")
+	}
+	if _, err := p.external(filepath.Join(dir, "weights.bin"), map[string]string{}); err == nil {
+		t.Error("absolute location was read")
+	}
+
+	p.dir = dir
+	cases := []struct {
+		entry map[string]string
+		want  string
+		ok    bool
+	}{
+		{map[string]string{}, "0123456789", true},
+		{map[string]string{"offset": "4"}, "456789", true},
+		{map[string]string{"offset": "2", "length": "3"}, "234", true},
+		{map[string]string{"offset": "10", "length": "0"}, "", true},
+		{map[string]string{"offset": "x"}, "", false},
+		{map[string]string{"offset": "-1"}, "", false},
+		{map[string]string{"offset": "11"}, "", false},
+		{map[string]string{"length": "-1"}, "", false},
+		{map[string]string{"offset": "8", "length": "3"}, "", false},
+		{map[string]string{"length": "9223372036854775807"}, "", false},
+	}
+	for _, c := range cases {
+		got, err := p.external("weights.bin", c.entry)
+		if (err == nil) != c.ok || string(got) != c.want {
+			t.Errorf("%v: got %q, %v", c.entry, got, err)
+		}
+	}
+}
+
+// onnxTensorProto encodes a TensorProto with dims, data_type and either
+// raw_data or packed float_data.
+func onnxTensorProto(dims []int64, dtype int, raw []byte, floats []float32) []byte {
+	var b []byte
+	for _, d := range dims {
+		b = append(b, 0x08)
+		b = binary.AppendUvarint(b, uint64(d))
+	}
+	b = append(b, 0x10, byte(dtype))
+	if floats != nil {
+		b = append(b, 0x22)
+		b = binary.AppendUvarint(b, uint64(4*len(floats)))
+		for _, f := range floats {
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(f))
+		}
+	}
+	if raw != nil {
+		b = append(b, 0x4a)
+		b = binary.AppendUvarint(b, uint64(len(raw)))
+		b = append(b, raw...)
+	}
+	return b
+}
+
+func TestOnnxTensorShape(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"raw", onnxTensorProto([]int64{2, 2}, 1, make([]byte, 16), nil), true},
+		{"typed", onnxTensorProto([]int64{3}, 1, nil, []float32{1, 2, 3}), true},
+		{"scalar", onnxTensorProto(nil, 1, nil, []float32{1}), true},
+		{"empty", onnxTensorProto([]int64{0, 5}, 7, []byte{}, nil), true},
+		{"negative dim", onnxTensorProto([]int64{-1}, 1, make([]byte, 4), nil), false},
+		{"raw too short", onnxTensorProto([]int64{2, 2}, 1, make([]byte, 12), nil), false},
+		{"raw too long", onnxTensorProto([]int64{2}, 7, make([]byte, 24), nil), false},
+		{"typed too short", onnxTensorProto([]int64{4}, 1, nil, []float32{1, 2}), false},
+		{"huge dim", onnxTensorProto([]int64{4000000000}, 1, make([]byte, 16), nil), false},
+		{"overflow", onnxTensorProto([]int64{1 << 32, 1 << 32, 1 << 32}, 1, make([]byte, 4), nil), false},
+	}
+	p := &onnxParser{}
+	for _, c := range cases {
+		_, tensor, err := p.tensor(c.data)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: got %v, %v", c.name, tensor, err)
+		}
+	}
+}
@@ -46,25 +46,7 @@ func File2Image(fileName string) image.Image {
 }
 
 func ImRead(img image.Image) (ret [][][]uint8) {
-	img = ToRGBA(img) //jpg精度损失,提前转为rgba格式
-
-	w := img.Bounds().Dx()
-	h := img.Bounds().Dy()
-	ret = make([][][]uint8, h)
-	for dh := 0; dh < h; dh++ {
-		ret[dh] = make([][]uint8, w)
-	}
-	for dh := 0; dh < h; dh++ {
-		for dw := 0; dw < w; dw++ {
-			r, g, b, _ := img.At(dw, dh).RGBA()
-			ret[dh][dw] = []uint8{
-				uint8(b),
-				uint8(g),
-				uint8(r),
-			}
-		}
-	}
-	return
+	return imReadHWC(img, ChannelBGR)
 }
 
 func ImRead4File(fileName string) (ret [][][]uint8) {
@@ -74,45 +56,25 @@ func ImRead4File(fileName string) (ret [][][]uint8) {
 }
 
 func ImReadRGB(img image.Image) (ret [][][]uint8) {
-	img = ToRGBA(img) //jpg精度损失,提前转为rgba格式
-
-	w := img.Bounds().Dx()
-	h := img.Bounds().Dy()
-	ret = make([][][]uint8, h)
-	for dh := 0; dh < h; dh++ {
-		ret[dh] = make([][]uint8, w)
-	}
-	for dh := 0; dh < h; dh++ {
-		for dw := 0; dw < w; dw++ {
-			r, g, b, _ := img.At(dw, dh).RGBA()
-			ret[dh][dw] = []uint8{
-				uint8(r),
-				uint8(g),
-				uint8(b),
-			}
-		}
-	}
-	return
+	return imReadHWC(img, ChannelRGB)
 }
 
 func ImReadBGR(img image.Image) (ret [][][]uint8) {
-	img = ToRGBA(img) //jpg精度损失,提前转为rgba格式
+	return imReadHWC(img, ChannelBGR)
+}
 
+// imReadHWC views one flat buffer as [h][w][3]; every pixel shares it.
+func imReadHWC(img image.Image, order ChannelOrder) (ret [][][]uint8) {
+	buf := ImReadFlat(img, LayoutHWC, order, nil)
 	w := img.Bounds().Dx()
 	h := img.Bounds().Dy()
-	ret = make([][][]uint8, h)
-	for dh := 0; dh < h; dh++ {
-		ret[dh] = make([][]uint8, w)
+	px := make([][]uint8, w*h)
+	for i := range px {
+		px[i] = buf[i*3 : i*3+3 : i*3+3]
 	}
+	ret = make([][][]uint8, h)
 	for dh := 0; dh < h; dh++ {
-		for dw := 0; dw < w; dw++ {
-			r, g, b, _ := img.At(dw, dh).RGBA()
-			ret[dh][dw] = []uint8{
-				uint8(b),
-				uint8(g),
-				uint8(r),
-			}
-		}
+		ret[dh] = px[dh*w : (dh+1)*w : (dh+1)*w]
 	}
 	return
 }
@@ -178,31 +140,15 @@ func ImageMeanNormalizeF32RGBHWC(imgData [][][]uint8, mean, norm []float32) [][]
 }
 
 func ImReadRGBCHW(img image.Image) (ret [][][]uint8) {
-	img = ToRGBA(img) //jpg精度损失,提前转为rgba格式
-
-	ret = [][][]uint8{
-		{},
-		{},
-		{},
-	}
-
+	buf := ImReadFlat(img, LayoutCHW, ChannelRGB, nil)
 	w := img.Bounds().Dx()
 	h := img.Bounds().Dy()
-
-	ret[0] = make([][]uint8, h)
-	ret[1] = make([][]uint8, h)
-	ret[2] = make([][]uint8, h)
-	for dh := 0; dh < h; dh++ {
-		ret[0][dh] = make([]uint8, w)
-		ret[1][dh] = make([]uint8, w)
-		ret[2][dh] = make([]uint8, w)
-	}
-	for dh := 0; dh < h; dh++ {
-		for dw := 0; dw < w; dw++ {
-			r, g, b, _ := img.At(dw, dh).RGBA()
-			ret[0][dh][dw] = uint8(r)
-			ret[1][dh][dw] = uint8(g)
-			ret[2][dh][dw] = uint8(b)
+	ret = make([][][]uint8, 3)
+	for c := range ret {
+		ret[c] = make([][]uint8, h)
+		for dh := 0; dh < h; dh++ {
+			o := (c*h + dh) * w
+			ret[c][dh] = buf[o : o+w : o+w]
 		}
 	}
 	return
@@ -491,36 +437,24 @@ type Box struct {
 	Landmark  []BoxLandmark
 	Extension map[string]interface{}
 	Prob      float32
+	ClassID   int
+	Label     string
+	Rotated   *RotatedBox  // oriented box, Rectangle then holds its bounds
+	Mask      *image.Alpha // instance mask over Rectangle, see DecodeMasks
 }
 
+// DetectNms suppresses overlapping boxes in input order, ignoring classes.
+// See MultiClassNms for class-aware suppression.
 func DetectNms(inputBoxes []Box, thresh float32) []Box {
-
-	vArea := []int{}
-	for i := range inputBoxes {
-		vArea = append(vArea,
-			(inputBoxes[i].Rectangle.Max.X-inputBoxes[i].Rectangle.Min.X+1)*(inputBoxes[i].Rectangle.Max.Y-inputBoxes[i].Rectangle.Min.Y+1),
-		)
-	}
-
-	for i := 0; i < len(inputBoxes); i++ {
-		for j := i + 1; j < len(inputBoxes); {
-			xx1 := math.Max(float64(inputBoxes[i].Rectangle.Min.X), float64(inputBoxes[j].Rectangle.Min.X))
-			yy1 := math.Max(float64(inputBoxes[i].Rectangle.Min.Y), float64(inputBoxes[j].Rectangle.Min.Y))
-			xx2 := math.Min(float64(inputBoxes[i].Rectangle.Max.X), float64(inputBoxes[j].Rectangle.Max.X))
-			yy2 := math.Min(float64(inputBoxes[i].Rectangle.Max.Y), float64(inputBoxes[j].Rectangle.Max.Y))
-			w := math.Max(0, xx2-xx1+1)
-			h := math.Max(0, yy2-yy1+1)
-			inter := float32(w * h)
-			ovr := inter / (float32(vArea[i]) + float32(vArea[j]) - inter)
-			if ovr >= thresh {
-				inputBoxes = append(inputBoxes[:j], inputBoxes[j+1:]...)
-				vArea = append(vArea[:j], vArea[j+1:]...)
-			} else {
-				j++
-			}
-		}
+	rects, canonical := nmsRects(len(inputBoxes), func(i int) image.Rectangle { return inputBoxes[i].Rectangle })
+	keep := suppress(rects, canonical, thresh, 0, nil, func(i, j int) float32 {
+		return rects[i].iou(rects[j])
+	})
+	// compact in place like the slice removal this replaces
+	for k, i := range keep {
+		inputBoxes[k] = inputBoxes[i]
 	}
-	return inputBoxes
+	return inputBoxes[:len(keep)]
 }
 
 func ScrfdGenerateProposals(anchors [][]float32, scale float32, inpWidth, inpHeight, stride int, pdata_score []float32, pdata_bbox []float32, pdata_kps []float32, confThreshold float32) []Box {
@@ -846,9 +780,35 @@ func ExtractionBasisMask(img image.Image, mask image.Image) image.Image {
 }
 
 type ScaleParams struct {
-	Ratio float32
-	Dw    int
-	Dh    int
+	Ratio  float32
+	Dw     int
+	Dh     int
+	RatioY float32 // vertical ratio when the image was stretched, 0 means Ratio
+}
+
+// Unscale maps a point of the resized model input back to the source image.
+func (s ScaleParams) Unscale(x, y float32) (float32, float32) {
+	ry := s.RatioY
+	if ry == 0 {
+		ry = s.Ratio
+	}
+	return (x - float32(s.Dw)) / s.Ratio, (y - float32(s.Dh)) / ry
+}
+
+// Scale maps a point of the source image into the resized model input.
+func (s ScaleParams) Scale(x, y float32) (float32, float32) {
+	ry := s.RatioY
+	if ry == 0 {
+		ry = s.Ratio
+	}
+	return x*s.Ratio + float32(s.Dw), y*ry + float32(s.Dh)
+}
+
+// UnscaleRect maps a rectangle of the model input back to the source image.
+func (s ScaleParams) UnscaleRect(r image.Rectangle) image.Rectangle {
+	x0, y0 := s.Unscale(float32(r.Min.X), float32(r.Min.Y))
+	x1, y1 := s.Unscale(float32(r.Max.X), float32(r.Max.Y))
+	return image.Rect(int(x0), int(y0), int(x1), int(y1))
 }
 
 var ResizeMode imaging.ResampleFilter = imaging.Lanczos
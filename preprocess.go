@@ -0,0 +1,210 @@
+package goincv
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/disintegration/imaging"
+)
+
+// FitMode controls how an image is brought to the model input size.
+type FitMode int
+
+const (
+	FitLetterbox  FitMode = iota // keep aspect ratio and pad, like ResizeImageBorder
+	FitStretch                   // resize to the exact size, ignoring aspect ratio
+	FitCenterCrop                // keep aspect ratio, fill the input and crop the overflow
+)
+
+// Preprocessor describes how a batch of images is turned into one model
+// input tensor. With only Width and Height set it letterboxes to RGB NHWC
+// float32 scaled to [0,1]; most detectors want Layout: LayoutCHW. It can be
+// shared between goroutines.
+type Preprocessor struct {
+	Width, Height int
+	Fit           FitMode
+	Background    color.Color            // letterbox padding, default black
+	Filter        imaging.ResampleFilter // default ResizeMode
+	Order         ChannelOrder
+	Layout        ImageLayout // LayoutCHW gives NCHW, LayoutHWC gives NHWC
+	Mean, Norm    []float32   // output channel order, see ImReadNormalizeF32
+	DType         DataType    // DataTypeFloat32 (default), DataTypeFloat16 or DataTypeUInt8
+	Workers       int         // default runtime.NumCPU()
+}
+
+// Run preprocesses imgs concurrently and returns a tensor of shape
+// [N,3,H,W] or [N,H,W,3] together with the ScaleParams of every image,
+// which map model coordinates back with ScaleParams.Unscale.
+func (p *Preprocessor) Run(imgs []image.Image) (AnyTensor, []ScaleParams, error) {
+	switch p.DType {
+	case "", DataTypeFloat32:
+		return p.RunF32(imgs, nil)
+	case DataTypeUInt8:
+		return p.RunU8(imgs, nil)
+	case DataTypeFloat16:
+		t, params, err := p.RunF32(imgs, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return TensorToF16(t), params, nil
+	}
+	return nil, nil, fmt.Errorf("preprocess does not support %v", p.DType)
+}
+
+// RunF32 is Run for float32 output; dst is reused when large enough.
+func (p *Preprocessor) RunF32(imgs []image.Image, dst []float32) (*Tensor[float32], []ScaleParams, error) {
+	if err := p.check(imgs); err != nil {
+		return nil, nil, err
+	}
+	n := 3 * p.Width * p.Height
+	if cap(dst) < n*len(imgs) {
+		dst = make([]float32, n*len(imgs))
+	}
+	dst = dst[:n*len(imgs)]
+	params := p.each(imgs, func(i int, img image.Image) {
+		ImReadNormalizeF32(img, p.Layout, p.Order, p.Mean, p.Norm, dst[i*n:(i+1)*n:(i+1)*n])
+	})
+	return MustTensorFrom(dst, p.batchShape(len(imgs))), params, nil
+}
+
+// RunU8 is Run for raw uint8 output, Mean and Norm are ignored.
+func (p *Preprocessor) RunU8(imgs []image.Image, dst []uint8) (*Tensor[uint8], []ScaleParams, error) {
+	if err := p.check(imgs); err != nil {
+		return nil, nil, err
+	}
+	n := 3 * p.Width * p.Height
+	if cap(dst) < n*len(imgs) {
+		dst = make([]uint8, n*len(imgs))
+	}
+	dst = dst[:n*len(imgs)]
+	params := p.each(imgs, func(i int, img image.Image) {
+		ImReadFlat(img, p.Layout, p.Order, dst[i*n:(i+1)*n:(i+1)*n])
+	})
+	return MustTensorFrom(dst, p.batchShape(len(imgs))), params, nil
+}
+
+func (p *Preprocessor) check(imgs []image.Image) error {
+	if p.Width <= 0 || p.Height <= 0 {
+		return errors.New("preprocess size must be positive")
+	}
+	if len(imgs) == 0 {
+		return errors.New("preprocess needs at least one image")
+	}
+	for i, img := range imgs {
+		if img == nil || img.Bounds().Empty() {
+			return fmt.Errorf("preprocess image %d is empty", i)
+		}
+	}
+	return nil
+}
+
+func (p *Preprocessor) batchShape(n int) Shape {
+	if p.Layout == LayoutCHW {
+		return Shape{n, 3, p.Height, p.Width}
+	}
+	return Shape{n, p.Height, p.Width, 3}
+}
+
+// each resizes every image on a pool of workers and hands the result to fn.
+func (p *Preprocessor) each(imgs []image.Image, fn func(i int, img image.Image)) []ScaleParams {
+	params := make([]ScaleParams, len(imgs))
+	workers := p.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(imgs) {
+		workers = len(imgs)
+	}
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				var img image.Image
+				img, params[i] = p.Fit.apply(imgs[i], p.Width, p.Height, p.Background, p.filter())
+				fn(i, img)
+			}
+		}()
+	}
+	for i := range imgs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return params
+}
+
+func (p *Preprocessor) filter() imaging.ResampleFilter {
+	if p.Filter.Kernel == nil {
+		return ResizeMode
+	}
+	return p.Filter
+}
+
+// Fit resizes one image to width x height. Images already at that size are
+// returned untouched.
+func (m FitMode) Fit(img image.Image, width, height int, bg color.Color) (image.Image, ScaleParams) {
+	return m.apply(img, width, height, bg, ResizeMode)
+}
+
+func (m FitMode) apply(img image.Image, width, height int, bg color.Color, filter imaging.ResampleFilter) (image.Image, ScaleParams) {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w == width && h == height {
+		return img, ScaleParams{Ratio: 1}
+	}
+	rx, ry := float32(width)/float32(w), float32(height)/float32(h)
+	switch m {
+	case FitStretch:
+		return imaging.Resize(img, width, height, filter), ScaleParams{Ratio: rx, RatioY: ry}
+	case FitCenterCrop:
+		ratio := float32(math.Max(float64(rx), float64(ry)))
+		nw := int(math.Ceil(float64(float32(w) * ratio)))
+		nh := int(math.Ceil(float64(float32(h) * ratio)))
+		if nw < width {
+			nw = width
+		}
+		if nh < height {
+			nh = height
+		}
+		resized := imaging.Resize(img, nw, nh, filter)
+		x0, y0 := (nw-width)/2, (nh-height)/2
+		crop := resized.SubImage(image.Rect(x0, y0, x0+width, y0+height))
+		return crop, ScaleParams{Ratio: ratio, Dw: -x0, Dh: -y0}
+	}
+
+	params, nw, nh := letterbox(w, h, width, height)
+	if nw < 1 {
+		nw = 1
+	}
+	if nh < 1 {
+		nh = 1
+	}
+	if bg == nil {
+		bg = color.Black
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{bg}, image.Point{}, draw.Src)
+	resized := imaging.Resize(img, nw, nh, filter)
+	draw.Draw(canvas, image.Rect(params.Dw, params.Dh, params.Dw+nw, params.Dh+nh), resized, image.Point{}, draw.Src)
+	return canvas, params
+}
+
+// letterbox computes the same ratio and padding as NewNMS, plus the size
+// the image is resized to before padding.
+func letterbox(w, h, width, height int) (params ScaleParams, nw, nh int) {
+	ratio := float32(width) / float32(w)
+	nw, nh = width, int(float32(h)*ratio)
+	if float64(w)/float64(h) <= float64(width)/float64(height) {
+		ratio = float32(height) / float32(h)
+		nw, nh = int(float32(w)*ratio), height
+	}
+	return ScaleParams{Ratio: ratio, Dw: (width - nw) / 2, Dh: (height - nh) / 2}, nw, nh
+}
@@ -0,0 +1,218 @@
+package goincv
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func preprocessTestImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(10*x + y), uint8(100 + y), uint8(200 - x), 255})
+		}
+	}
+	return img
+}
+
+func TestPreprocessorRunLayout(t *testing.T) {
+	// the image is already at the input size, so no resampling is involved
+	img := preprocessTestImage(4, 3)
+	mean := []float32{0.5, 0.25, 0}
+	norm := []float32{0.5, 1, 2}
+	p := Preprocessor{Width: 4, Height: 3, Layout: LayoutCHW, Order: ChannelBGR, Mean: mean, Norm: norm}
+	out, params, err := p.Run([]image.Image{img, img})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params) != 2 || params[0] != (ScaleParams{Ratio: 1}) {
+		t.Fatalf("params = %v", params)
+	}
+	ft, ok := out.(*Tensor[float32])
+	if !ok {
+		t.Fatalf("Run returned %T", out)
+	}
+	if !ft.Shape().Equal(Shape{2, 3, 3, 4}) {
+		t.Fatalf("shape = %v", ft.Shape())
+	}
+	data := ft.Data()
+	for n := 0; n < 2; n++ {
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 4; x++ {
+				px := img.RGBAAt(x, y)
+				bgr := []uint8{px.B, px.G, px.R}
+				for c, v := range bgr {
+					want := (float32(v)/255 - mean[c]) / norm[c]
+					if got := data[((n*3+c)*3+y)*4+x]; got != want {
+						t.Fatalf("[%d,%d,%d,%d] = %v, want %v", n, c, y, x, got, want)
+					}
+				}
+			}
+		}
+	}
+
+	p = Preprocessor{Width: 4, Height: 3, DType: DataTypeUInt8}
+	out, _, err = p.Run([]image.Image{img})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ut := out.(*Tensor[uint8])
+	if !ut.Shape().Equal(Shape{1, 3, 4, 3}) {
+		t.Fatalf("uint8 shape = %v", ut.Shape())
+	}
+	px := img.RGBAAt(2, 1)
+	if got := ut.Data()[(1*4+2)*3:][:3]; got[0] != px.R || got[1] != px.G || got[2] != px.B {
+		t.Errorf("uint8 pixel (2,1) = %v, want %v", got, px)
+	}
+
+	p.DType = DataTypeFloat16
+	out, _, err = p.Run([]image.Image{img})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ht, ok := out.(*HalfTensor[Float16]); !ok || !ht.Shape().Equal(Shape{1, 3, 4, 3}) {
+		t.Errorf("float16 output = %T %v", out, out.Shape())
+	}
+}
+
+func TestPreprocessorLetterbox(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	p := Preprocessor{Width: 4, Height: 4, Background: color.Gray{64}, DType: DataTypeUInt8}
+	out, params, err := p.Run([]image.Image{img})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ScaleParams{Ratio: 0.5, Dh: 1}); params[0] != want {
+		t.Fatalf("params = %+v, want %+v", params[0], want)
+	}
+	data := out.(*Tensor[uint8]).Data()
+	for y := 0; y < 4; y++ {
+		want := uint8(0xff)
+		if y == 0 || y == 3 {
+			want = 64
+		}
+		for i, v := range data[y*12 : (y+1)*12] {
+			if v != want {
+				t.Fatalf("row %d value %d = %d, want %d", y, i, v, want)
+			}
+		}
+	}
+}
+
+func TestPreprocessorWorkers(t *testing.T) {
+	imgs := make([]image.Image, 23)
+	for i := range imgs {
+		imgs[i] = preprocessTestImage(5+i%7, 4+i%5)
+	}
+	for _, fit := range []FitMode{FitLetterbox, FitStretch, FitCenterCrop} {
+		one := Preprocessor{Width: 6, Height: 5, Fit: fit, Workers: 1}
+		many := one
+		many.Workers = 4
+		a, pa, err := one.RunF32(imgs, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, pb, err := many.RunF32(imgs, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range imgs {
+			_, want := fit.Fit(imgs[i], 6, 5, nil)
+			if pa[i] != want || pb[i] != want {
+				t.Errorf("fit %d image %d params %+v and %+v, want %+v", fit, i, pa[i], pb[i], want)
+			}
+		}
+		for i, v := range a.Data() {
+			if b.Data()[i] != v {
+				t.Fatalf("fit %d: value %d differs between 1 and 4 workers", fit, i)
+			}
+		}
+	}
+}
+
+func TestPreprocessorCheck(t *testing.T) {
+	img := preprocessTestImage(2, 2)
+	cases := []struct {
+		name string
+		p    Preprocessor
+		imgs []image.Image
+	}{
+		{"no size", Preprocessor{}, []image.Image{img}},
+		{"no images", Preprocessor{Width: 2, Height: 2}, nil},
+		{"nil image", Preprocessor{Width: 2, Height: 2}, []image.Image{img, nil}},
+		{"empty image", Preprocessor{Width: 2, Height: 2}, []image.Image{image.NewRGBA(image.Rectangle{})}},
+		{"dtype", Preprocessor{Width: 2, Height: 2, DType: DataTypeInt64}, []image.Image{img}},
+	}
+	for _, c := range cases {
+		if _, _, err := c.p.Run(c.imgs); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+}
+
+func TestFitScaleRoundTrip(t *testing.T) {
+	near := func(a, b float32) bool { return math.Abs(float64(a-b)) < 1e-3 }
+	sizes := [][2]int{{40, 30}, {30, 40}, {17, 50}, {64, 64}}
+	for _, fit := range []FitMode{FitLetterbox, FitStretch, FitCenterCrop} {
+		for _, sz := range sizes {
+			w, h := sz[0], sz[1]
+			out, params := fit.Fit(preprocessTestImage(w, h), 32, 24, nil)
+			if b := out.Bounds(); b.Dx() != 32 || b.Dy() != 24 {
+				t.Fatalf("fit %d %dx%d: output %v", fit, w, h, b)
+			}
+			for _, pt := range [][2]float32{{0, 0}, {float32(w), float32(h)}, {3.5, 7.25}, {float32(w) / 2, float32(h) / 2}} {
+				sx, sy := params.Scale(pt[0], pt[1])
+				ux, uy := params.Unscale(sx, sy)
+				if !near(ux, pt[0]) || !near(uy, pt[1]) {
+					t.Errorf("fit %d %dx%d: %v -> (%v,%v) -> (%v,%v)", fit, w, h, pt, sx, sy, ux, uy)
+				}
+			}
+
+			// the source centre lands on the input centre within a pixel
+			cx, cy := params.Scale(float32(w)/2, float32(h)/2)
+			if math.Abs(float64(cx-16)) > 1 || math.Abs(float64(cy-12)) > 1 {
+				t.Errorf("fit %d %dx%d: centre maps to (%v,%v)", fit, w, h, cx, cy)
+			}
+			x1, y1 := params.Scale(float32(w), float32(h))
+			x0, y0 := params.Scale(0, 0)
+			switch fit {
+			case FitStretch:
+				if !near(x0, 0) || !near(y0, 0) || !near(x1, 32) || !near(y1, 24) {
+					t.Errorf("stretch %dx%d: corners (%v,%v) (%v,%v)", w, h, x0, y0, x1, y1)
+				}
+			case FitLetterbox:
+				if x0 < 0 || y0 < 0 || x1 > 32.5 || y1 > 24.5 {
+					t.Errorf("letterbox %dx%d: corners (%v,%v) (%v,%v) outside the input", w, h, x0, y0, x1, y1)
+				}
+			case FitCenterCrop:
+				if x0 > 0 || y0 > 0 || x1 < 32 || y1 < 24 {
+					t.Errorf("centercrop %dx%d: corners (%v,%v) (%v,%v) do not cover the input", w, h, x0, y0, x1, y1)
+				}
+			}
+		}
+	}
+}
+
+func TestScaleParams(t *testing.T) {
+	s := ScaleParams{Ratio: 2, RatioY: 0.5, Dw: 4, Dh: -2}
+	if x, y := s.Scale(10, 10); x != 24 || y != 3 {
+		t.Errorf("Scale = (%v,%v), want (24,3)", x, y)
+	}
+	if x, y := s.Unscale(24, 3); x != 10 || y != 10 {
+		t.Errorf("Unscale = (%v,%v), want (10,10)", x, y)
+	}
+
+	// RatioY 0 falls back to Ratio
+	s = ScaleParams{Ratio: 0.5, Dw: 4, Dh: 2}
+	if x, y := s.Unscale(14, 12); x != 20 || y != 20 {
+		t.Errorf("Unscale = (%v,%v), want (20,20)", x, y)
+	}
+	if got, want := s.UnscaleRect(image.Rect(4, 2, 14, 12)), image.Rect(0, 0, 20, 20); got != want {
+		t.Errorf("UnscaleRect = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,177 @@
+package goincv
+
+import (
+	"fmt"
+	"math"
+)
+
+// Quantized is the set of integer types used by quantised models.
+type Quantized interface {
+	int8 | uint8
+}
+
+// QuantParams describes affine quantization: real = Scale * (q - ZeroPoint).
+type QuantParams struct {
+	Scale     float32
+	ZeroPoint int32
+}
+
+func quantRange[Q Quantized]() (int32, int32) {
+	var zero Q
+	if _, ok := any(zero).(int8); ok {
+		return math.MinInt8, math.MaxInt8
+	}
+	return 0, math.MaxUint8
+}
+
+// CalcQuantParams derives scale and zero point covering [min, max]. The
+// range is widened to include 0 so zero stays exactly representable;
+// symmetric forces a zero point of 0 for int8.
+func CalcQuantParams[Q Quantized](min, max float32, symmetric bool) QuantParams {
+	qmin, qmax := quantRange[Q]()
+	min = float32(math.Min(float64(min), 0))
+	max = float32(math.Max(float64(max), 0))
+	if symmetric {
+		m := float32(math.Max(math.Abs(float64(min)), math.Abs(float64(max))))
+		min, max = -m, m
+	}
+	if max == min {
+		return QuantParams{Scale: 1, ZeroPoint: 0}
+	}
+	if symmetric {
+		zp := int32(0)
+		if qmin == 0 {
+			zp = (qmax + 1) / 2
+		}
+		return QuantParams{Scale: max / float32(qmax-zp), ZeroPoint: zp}
+	}
+	scale := (max - min) / float32(qmax-qmin)
+	zp := int32(math.RoundToEven(float64(float32(qmin) - min/scale)))
+	if zp < qmin {
+		zp = qmin
+	}
+	if zp > qmax {
+		zp = qmax
+	}
+	return QuantParams{Scale: scale, ZeroPoint: zp}
+}
+
+// QuantizeValue maps one real value, rounding half to even and saturating
+// like ONNX QuantizeLinear.
+func QuantizeValue[Q Quantized](v float32, p QuantParams) Q {
+	qmin, qmax := quantRange[Q]()
+	q := math.RoundToEven(float64(v/p.Scale)) + float64(p.ZeroPoint)
+	if q < float64(qmin) {
+		q = float64(qmin)
+	}
+	if q > float64(qmax) {
+		q = float64(qmax)
+	}
+	return Q(q)
+}
+
+func DequantizeValue[Q Quantized](q Q, p QuantParams) float32 {
+	return p.Scale * float32(int32(q)-p.ZeroPoint)
+}
+
+// QuantizeSlice quantizes a flat buffer with per-tensor parameters.
+func QuantizeSlice[Q Quantized](data []float32, p QuantParams) []Q {
+	ret := make([]Q, len(data))
+	for i := range data {
+		ret[i] = QuantizeValue[Q](data[i], p)
+	}
+	return ret
+}
+
+// DequantizeSlice turns raw int8/uint8 model outputs back into float32,
+// e.g. before passing scores to NMS.AddValue.
+func DequantizeSlice[Q Quantized](data []Q, p QuantParams) []float32 {
+	ret := make([]float32, len(data))
+	for i := range data {
+		ret[i] = DequantizeValue(data[i], p)
+	}
+	return ret
+}
+
+// Quantize applies per-tensor quantization.
+func Quantize[Q Quantized](t *Tensor[float32], p QuantParams) *Tensor[Q] {
+	return MustTensorFrom(QuantizeSlice[Q](t.Data(), p), t.shape)
+}
+
+// Dequantize reverses per-tensor quantization.
+func Dequantize[Q Quantized](t *Tensor[Q], p QuantParams) *Tensor[float32] {
+	return MustTensorFrom(DequantizeSlice(t.Data(), p), t.shape)
+}
+
+// QuantizePerChannel quantizes every slice along axis with its own
+// parameters, as used for conv weights and per-channel outputs.
+func QuantizePerChannel[Q Quantized](t *Tensor[float32], axis int, params []QuantParams) (*Tensor[Q], error) {
+	axis, err := normalizeAxis(axis, len(t.shape))
+	if err != nil {
+		return nil, err
+	}
+	if len(params) != t.shape[axis] {
+		return nil, fmt.Errorf("%d quant params for %d channels", len(params), t.shape[axis])
+	}
+	ret := NewTensor[Q](t.shape)
+	for c := range params {
+		src, _ := t.Narrow(axis, c, 1)
+		dst, _ := ret.Narrow(axis, c, 1)
+		eachPair(dst, src, func(_, offDst, offSrc int) {
+			ret.data[offDst] = QuantizeValue[Q](t.data[offSrc], params[c])
+		})
+	}
+	return ret, nil
+}
+
+// DequantizePerChannel reverses QuantizePerChannel.
+func DequantizePerChannel[Q Quantized](t *Tensor[Q], axis int, params []QuantParams) (*Tensor[float32], error) {
+	axis, err := normalizeAxis(axis, len(t.shape))
+	if err != nil {
+		return nil, err
+	}
+	if len(params) != t.shape[axis] {
+		return nil, fmt.Errorf("%d quant params for %d channels", len(params), t.shape[axis])
+	}
+	ret := NewTensor[float32](t.shape)
+	for c := range params {
+		src, _ := t.Narrow(axis, c, 1)
+		dst, _ := ret.Narrow(axis, c, 1)
+		eachPair(dst, src, func(_, offDst, offSrc int) {
+			ret.data[offDst] = DequantizeValue(t.data[offSrc], params[c])
+		})
+	}
+	return ret, nil
+}
+
+// ImageQuantizeI8RGBCHW normalises ImReadRGBCHW output like
+// ImageMeanNormalizeF32RGBCHW and quantizes it to int8 in the same pass.
+func ImageQuantizeI8RGBCHW(imgData [][][]uint8, mean, norm []float32, p QuantParams) [][][]int8 {
+	return imageQuantizeRGBCHW[int8](imgData, mean, norm, p)
+}
+
+// ImageQuantizeU8RGBCHW is the uint8 variant of ImageQuantizeI8RGBCHW.
+func ImageQuantizeU8RGBCHW(imgData [][][]uint8, mean, norm []float32, p QuantParams) [][][]uint8 {
+	return imageQuantizeRGBCHW[uint8](imgData, mean, norm, p)
+}
+
+func imageQuantizeRGBCHW[Q Quantized](imgData [][][]uint8, mean, norm []float32, p QuantParams) [][][]Q {
+	// 256 possible inputs per channel, so a lookup table beats per pixel math
+	var lut [3][256]Q
+	for c := 0; c < 3; c++ {
+		for v := 0; v < 256; v++ {
+			lut[c][v] = QuantizeValue[Q]((float32(v)/255-mean[c])/norm[c], p)
+		}
+	}
+	ret := make([][][]Q, len(imgData))
+	for c := range imgData {
+		ret[c] = make([][]Q, len(imgData[c]))
+		for i := range imgData[c] {
+			ret[c][i] = make([]Q, len(imgData[c][i]))
+			for k, v := range imgData[c][i] {
+				ret[c][i][k] = lut[c%3][v]
+			}
+		}
+	}
+	return ret
+}
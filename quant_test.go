@@ -0,0 +1,199 @@
+package goincv
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalcQuantParams(t *testing.T) {
+	near := func(a, b float32) bool { return math.Abs(float64(a-b)) < 1e-6 }
+	cases := []struct {
+		name      string
+		int8      bool
+		min, max  float32
+		symmetric bool
+		want      QuantParams
+	}{
+		{"uint8 unit", false, 0, 255, false, QuantParams{Scale: 1, ZeroPoint: 0}},
+		{"uint8 shifted", false, -2, 253, false, QuantParams{Scale: 1, ZeroPoint: 2}},
+		{"uint8 widened to zero", false, 2, 10, false, QuantParams{Scale: 10.0 / 255, ZeroPoint: 0}},
+		{"int8 unit", true, -128, 127, false, QuantParams{Scale: 1, ZeroPoint: 0}},
+		{"int8 positive", true, 0, 255, false, QuantParams{Scale: 1, ZeroPoint: -128}},
+		{"int8 symmetric", true, -3, 127, true, QuantParams{Scale: 1, ZeroPoint: 0}},
+		{"uint8 symmetric", false, -1, 2, true, QuantParams{Scale: 2.0 / 127, ZeroPoint: 128}},
+		{"empty range", true, 0, 0, false, QuantParams{Scale: 1, ZeroPoint: 0}},
+	}
+	for _, c := range cases {
+		var got QuantParams
+		if c.int8 {
+			got = CalcQuantParams[int8](c.min, c.max, c.symmetric)
+		} else {
+			got = CalcQuantParams[uint8](c.min, c.max, c.symmetric)
+		}
+		if !near(got.Scale, c.want.Scale) || got.ZeroPoint != c.want.ZeroPoint {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestQuantizeValue(t *testing.T) {
+	p := QuantParams{Scale: 0.5, ZeroPoint: 10}
+	u8 := []struct {
+		v    float32
+		want uint8
+	}{
+		{1.25, 12}, // 2.5 rounds down to even
+		{1.75, 14}, // 3.5 rounds up to even
+		{-0.25, 10},
+		{-0.75, 8},
+		{3, 16},
+		{200, 255},
+		{-100, 0},
+	}
+	for _, c := range u8 {
+		if got := QuantizeValue[uint8](c.v, p); got != c.want {
+			t.Errorf("uint8 %v: got %d, want %d", c.v, got, c.want)
+		}
+	}
+
+	p = QuantParams{Scale: 1, ZeroPoint: 0}
+	i8 := []struct {
+		v    float32
+		want int8
+	}{
+		{2.5, 2},
+		{-2.5, -2},
+		{3.5, 4},
+		{127.5, 127},
+		{-128.5, -128},
+		{1000, 127},
+		{-1000, -128},
+	}
+	for _, c := range i8 {
+		if got := QuantizeValue[int8](c.v, p); got != c.want {
+			t.Errorf("int8 %v: got %d, want %d", c.v, got, c.want)
+		}
+	}
+}
+
+func TestQuantizeRoundTrip(t *testing.T) {
+	pu := QuantParams{Scale: 0.5, ZeroPoint: 10}
+	for q := 0; q <= math.MaxUint8; q++ {
+		v := DequantizeValue(uint8(q), pu)
+		if want := 0.5 * float32(q-10); v != want {
+			t.Fatalf("dequantize %d = %v, want %v", q, v, want)
+		}
+		if got := QuantizeValue[uint8](v, pu); got != uint8(q) {
+			t.Fatalf("uint8 %d -> %v -> %d", q, v, got)
+		}
+	}
+	pi := QuantParams{Scale: 0.1, ZeroPoint: -3}
+	for q := math.MinInt8; q <= math.MaxInt8; q++ {
+		if got := QuantizeValue[int8](DequantizeValue(int8(q), pi), pi); got != int8(q) {
+			t.Fatalf("int8 %d round trips to %d", q, got)
+		}
+	}
+
+	in := MustTensorFrom([]float32{-6, -0.25, 0, 0.75, 1.25, 300}, Shape{2, 3})
+	qt := Quantize[uint8](in, pu)
+	want := []uint8{0, 10, 10, 12, 12, 255}
+	for i, v := range qt.Data() {
+		if v != want[i] {
+			t.Fatalf("Quantize = %v, want %v", qt.Data(), want)
+		}
+	}
+	back := Dequantize(qt, pu)
+	if !back.Shape().Equal(Shape{2, 3}) {
+		t.Fatalf("Dequantize shape = %v", back.Shape())
+	}
+	for i, v := range back.Data() {
+		if v != DequantizeValue(want[i], pu) {
+			t.Fatalf("Dequantize = %v", back.Data())
+		}
+	}
+}
+
+func TestQuantizePerChannel(t *testing.T) {
+	in := MustTensorFrom([]float32{
+		1, 2, 3,
+		-1, -2, 100,
+	}, Shape{2, 3})
+	params := []QuantParams{{Scale: 1, ZeroPoint: 0}, {Scale: 0.5, ZeroPoint: 1}, {Scale: 2, ZeroPoint: -1}}
+	for _, axis := range []int{1, -1} {
+		q, err := QuantizePerChannel[int8](in, axis, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []int8{1, 5, 1, -1, -3, 49}
+		for i, v := range q.Data() {
+			if v != want[i] {
+				t.Fatalf("axis %d: got %v, want %v", axis, q.Data(), want)
+			}
+		}
+		back, err := DequantizePerChannel(q, axis, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range back.Data() {
+			if w := DequantizeValue(want[i], params[i%3]); v != w {
+				t.Fatalf("axis %d: dequantized %v at %d, want %v", axis, v, i, w)
+			}
+		}
+	}
+
+	// axis 0 uses one parameter per row
+	q, err := QuantizePerChannel[uint8](in, 0, params[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint8{3, 5, 7, 0, 0, 49}
+	for i, v := range q.Data() {
+		if v != want[i] {
+			t.Fatalf("axis 0: got %v, want %v", q.Data(), want)
+		}
+	}
+
+	if _, err := QuantizePerChannel[int8](in, 1, params[:2]); err == nil {
+		t.Error("expected an error for too few params")
+	}
+	if _, err := QuantizePerChannel[int8](in, 2, params); err == nil {
+		t.Error("expected an error for an out of range axis")
+	}
+	if _, err := DequantizePerChannel(MustTensorFrom([]int8{1, 2}, Shape{2}), 0, params); err == nil {
+		t.Error("expected an error for too many params")
+	}
+}
+
+func TestImageQuantizeRGBCHW(t *testing.T) {
+	// every channel value appears once per row, so the whole table is checked
+	img := make([][][]uint8, 3)
+	for c := range img {
+		img[c] = make([][]uint8, 2)
+		for y := range img[c] {
+			img[c][y] = make([]uint8, 256)
+			for x := range img[c][y] {
+				img[c][y][x] = uint8(x + 85*c + 7*y)
+			}
+		}
+	}
+	mean := []float32{0.485, 0.456, 0.406}
+	norm := []float32{0.229, 0.224, 0.225}
+	f := ImageMeanNormalizeF32RGBCHW(img, mean, norm)
+
+	pi := CalcQuantParams[int8](-2.2, 2.7, false)
+	i8 := ImageQuantizeI8RGBCHW(img, mean, norm, pi)
+	pu := CalcQuantParams[uint8](-2.2, 2.7, false)
+	u8 := ImageQuantizeU8RGBCHW(img, mean, norm, pu)
+	for c := range img {
+		for y := range img[c] {
+			for x := range img[c][y] {
+				if want := QuantizeValue[int8](f[c][y][x], pi); i8[c][y][x] != want {
+					t.Fatalf("int8 [%d][%d][%d] = %d, want %d", c, y, x, i8[c][y][x], want)
+				}
+				if want := QuantizeValue[uint8](f[c][y][x], pu); u8[c][y][x] != want {
+					t.Fatalf("uint8 [%d][%d][%d] = %d, want %d", c, y, x, u8[c][y][x], want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package goincv
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// RotatedBox is an oriented box given by its centre, size and rotation in
+// radians. Positive angles turn the width axis from +x towards +y, i.e.
+// clockwise on screen, like OpenCV's RotatedRect.
+type RotatedBox struct {
+	CX, CY float32
+	W, H   float32
+	Angle  float32
+}
+
+// RotatedBoxFromRect returns the unrotated box covering the pixels of r,
+// using the inclusive convention of BoxIoU.
+func RotatedBoxFromRect(r image.Rectangle) RotatedBox {
+	return RotatedBox{
+		CX: float32(r.Min.X+r.Max.X+1) / 2,
+		CY: float32(r.Min.Y+r.Max.Y+1) / 2,
+		W:  float32(r.Max.X - r.Min.X + 1),
+		H:  float32(r.Max.Y - r.Min.Y + 1),
+	}
+}
+
+// RotatedBoxFromPolygon fits the minimum area box to a 4-point polygon such
+// as a DOTA or ICDAR quadrilateral.
+func RotatedBoxFromPolygon(pts [4][2]float32) RotatedBox {
+	return MinAreaRect(pts[:])
+}
+
+// Polygon returns the corners, starting at the top left of the unrotated
+// box and going clockwise on screen.
+func (r RotatedBox) Polygon() [4][2]float32 {
+	sin, cos := math.Sincos(float64(r.Angle))
+	ux, uy := float32(cos)*r.W/2, float32(sin)*r.W/2
+	vx, vy := -float32(sin)*r.H/2, float32(cos)*r.H/2
+	return [4][2]float32{
+		{r.CX - ux - vx, r.CY - uy - vy},
+		{r.CX + ux - vx, r.CY + uy - vy},
+		{r.CX + ux + vx, r.CY + uy + vy},
+		{r.CX - ux + vx, r.CY - uy + vy},
+	}
+}
+
+// Bounds is the smallest rectangle, in inclusive pixels, containing the box.
+func (r RotatedBox) Bounds() image.Rectangle {
+	pts := r.Polygon()
+	x0, y0, x1, y1 := pts[0][0], pts[0][1], pts[0][0], pts[0][1]
+	for _, p := range pts[1:] {
+		x0, y0 = min32(x0, p[0]), min32(y0, p[1])
+		x1, y1 = max32(x1, p[0]), max32(y1, p[1])
+	}
+	return image.Rect(int(math.Floor(float64(x0))), int(math.Floor(float64(y0))),
+		int(math.Ceil(float64(x1)))-1, int(math.Ceil(float64(y1)))-1)
+}
+
+func (r RotatedBox) Area() float32 {
+	return r.W * r.H
+}
+
+// Box wraps r in a Box whose Rectangle is its bounds.
+func (r RotatedBox) Box(prob float32) Box {
+	return Box{Rectangle: r.Bounds(), Rotated: &r, Prob: prob}
+}
+
+// RotatedIoU is the intersection over union of two oriented boxes, found by
+// clipping one polygon with the other.
+func RotatedIoU(a, b RotatedBox) float32 {
+	areaA, areaB := float64(a.Area()), float64(b.Area())
+	if areaA <= 0 || areaB <= 0 {
+		return 0
+	}
+	pa, pb := a.Polygon(), b.Polygon()
+	inter := polygonArea(clipConvex(pa[:], pb[:]))
+	return float32(inter / (areaA + areaB - inter))
+}
+
+// clipConvex clips subject against the convex polygon clip with the
+// Sutherland-Hodgman algorithm. Both must wind like RotatedBox.Polygon.
+func clipConvex(subject, clip [][2]float32) [][2]float64 {
+	out := make([][2]float64, len(subject))
+	for i, p := range subject {
+		out[i] = [2]float64{float64(p[0]), float64(p[1])}
+	}
+	for i := range clip {
+		if len(out) == 0 {
+			break
+		}
+		p := [2]float64{float64(clip[i][0]), float64(clip[i][1])}
+		q := [2]float64{float64(clip[(i+1)%len(clip)][0]), float64(clip[(i+1)%len(clip)][1])}
+		side := func(x [2]float64) float64 {
+			return (q[0]-p[0])*(x[1]-p[1]) - (q[1]-p[1])*(x[0]-p[0])
+		}
+		in := out
+		out = make([][2]float64, 0, len(in)+1)
+		for j := range in {
+			cur, next := in[j], in[(j+1)%len(in)]
+			sc, sn := side(cur), side(next)
+			if sc >= 0 {
+				out = append(out, cur)
+			}
+			if (sc >= 0) != (sn >= 0) {
+				t := sc / (sc - sn)
+				out = append(out, [2]float64{cur[0] + t*(next[0]-cur[0]), cur[1] + t*(next[1]-cur[1])})
+			}
+		}
+	}
+	return out
+}
+
+// polygonArea is the absolute shoelace area.
+func polygonArea(pts [][2]float64) float64 {
+	s := 0.0
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		s += pts[i][0]*pts[j][1] - pts[j][0]*pts[i][1]
+	}
+	return math.Abs(s) / 2
+}
+
+// MinAreaRect returns the smallest oriented box containing the points,
+// trying every edge direction of their convex hull. The angle is in
+// [-π/2, π/2).
+func MinAreaRect(pts [][2]float32) RotatedBox {
+	hull := convexHull(pts)
+	switch len(hull) {
+	case 0:
+		return RotatedBox{}
+	case 1:
+		return RotatedBox{CX: hull[0][0], CY: hull[0][1]}
+	}
+	var best RotatedBox
+	bestArea := math.Inf(1)
+	for i := range hull {
+		p, q := hull[i], hull[(i+1)%len(hull)]
+		dx, dy := float64(q[0]-p[0]), float64(q[1]-p[1])
+		l := math.Hypot(dx, dy)
+		if l == 0 {
+			continue
+		}
+		ux, uy := dx/l, dy/l
+		u0, u1, v0, v1 := math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+		for _, h := range hull {
+			u := float64(h[0])*ux + float64(h[1])*uy
+			v := -float64(h[0])*uy + float64(h[1])*ux
+			u0, u1 = math.Min(u0, u), math.Max(u1, u)
+			v0, v1 = math.Min(v0, v), math.Max(v1, v)
+		}
+		if area := (u1 - u0) * (v1 - v0); area < bestArea {
+			cu, cv := (u0+u1)/2, (v0+v1)/2
+			angle := math.Atan2(uy, ux)
+			if angle >= math.Pi/2 {
+				angle -= math.Pi
+			} else if angle < -math.Pi/2 {
+				angle += math.Pi
+			}
+			bestArea = area
+			best = RotatedBox{
+				CX:    float32(cu*ux - cv*uy),
+				CY:    float32(cu*uy + cv*ux),
+				W:     float32(u1 - u0),
+				H:     float32(v1 - v0),
+				Angle: float32(angle),
+			}
+		}
+	}
+	return best
+}
+
+// convexHull is Andrew's monotone chain, counter-clockwise in y-up axes.
+func convexHull(pts [][2]float32) [][2]float32 {
+	p := append([][2]float32(nil), pts...)
+	sort.Slice(p, func(i, j int) bool {
+		return p[i][0] < p[j][0] || (p[i][0] == p[j][0] && p[i][1] < p[j][1])
+	})
+	if len(p) < 3 {
+		return p
+	}
+	cross := func(o, a, b [2]float32) float32 {
+		return (a[0]-o[0])*(b[1]-o[1]) - (a[1]-o[1])*(b[0]-o[0])
+	}
+	hull := make([][2]float32, 0, 2*len(p))
+	for _, pt := range p {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], pt) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, pt)
+	}
+	lower := len(hull) + 1
+	for i := len(p) - 2; i >= 0; i-- {
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p[i]) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p[i])
+	}
+	return hull[:len(hull)-1]
+}
+
+// UnscaleRotated maps an oriented box of the model input back to the source
+// image. A stretched input skews the box, which is then refitted.
+func (s ScaleParams) UnscaleRotated(r RotatedBox) RotatedBox {
+	if s.RatioY == 0 || s.RatioY == s.Ratio {
+		r.CX, r.CY = s.Unscale(r.CX, r.CY)
+		r.W, r.H = r.W/s.Ratio, r.H/s.Ratio
+		return r
+	}
+	pts := r.Polygon()
+	for i := range pts {
+		pts[i][0], pts[i][1] = s.Unscale(pts[i][0], pts[i][1])
+	}
+	return MinAreaRect(pts[:])
+}
+
+// rotatedOf returns the oriented form of a box, its rectangle when it has
+// none.
+func rotatedOf(b Box) RotatedBox {
+	if b.Rotated != nil {
+		return *b.Rotated
+	}
+	return RotatedBoxFromRect(b.Rectangle)
+}
+
+// boxBounds is the axis aligned extent of a box, rotated or not.
+func boxBounds(b Box) image.Rectangle {
+	if b.Rotated != nil {
+		return b.Rotated.Bounds()
+	}
+	return b.Rectangle
+}
+
+// BoxOverlap is the IoU of two boxes, using RotatedIoU when either of them
+// is oriented.
+func BoxOverlap(a, b Box) float32 {
+	if a.Rotated == nil && b.Rotated == nil {
+		return BoxIoU(a.Rectangle, b.Rectangle)
+	}
+	return RotatedIoU(rotatedOf(a), rotatedOf(b))
+}
+
+func hasRotated(boxes []Box) bool {
+	for _, b := range boxes {
+		if b.Rotated != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// RotatedRectangle draws the outline of an oriented box, like Rectangle.
+func RotatedRectangle(img image.Image, r RotatedBox, color color.Color) image.Image {
+	rgba := ToRGBA(img)
+	pts := r.Polygon()
+	drwaPolygon(rgba, pts[:], color)
+	return rgba
+}
+
+func drwaPolygon(img *image.RGBA, pts [][2]float32, col color.Color) {
+	for i := range pts {
+		p, q := pts[i], pts[(i+1)%len(pts)]
+		drwaLine(img, int(math.Round(float64(p[0]))), int(math.Round(float64(p[1]))),
+			int(math.Round(float64(q[0]))), int(math.Round(float64(q[1]))), col)
+	}
+}
+
+// drwaLine draws a line with Bresenham's algorithm.
+func drwaLine(img *image.RGBA, x0, y0, x1, y1 int, col color.Color) {
+	dx, dy := x1-x0, -(y1 - y0)
+	sx, sy := 1, 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	if dy > 0 {
+		dy, sy = -dy, -1
+	}
+	e := dx + dy
+	for {
+		img.Set(x0, y0, col)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package goincv
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestRotatedIoU(t *testing.T) {
+	sq := RotatedBox{CX: 0, CY: 0, W: 2, H: 2}
+	cases := []struct {
+		name string
+		a, b RotatedBox
+		want float64
+	}{
+		{"identical", sq, sq, 1},
+		{"disjoint", sq, RotatedBox{CX: 5, W: 2, H: 2}, 0},
+		{"touching", sq, RotatedBox{CX: 2, W: 2, H: 2}, 0},
+		{"half shift", sq, RotatedBox{CX: 1, W: 2, H: 2}, 1.0 / 3},
+		{"square turned 90", sq, RotatedBox{W: 2, H: 2, Angle: math.Pi / 2}, 1},
+		{"rect turned 90", RotatedBox{W: 4, H: 2}, RotatedBox{W: 4, H: 2, Angle: math.Pi / 2}, 4.0 / 12},
+		// the overlap is a regular octagon of area 8(√2-1)
+		{"square turned 45", sq, RotatedBox{W: 2, H: 2, Angle: math.Pi / 4}, 8 * (math.Sqrt2 - 1) / (8 - 8*(math.Sqrt2-1))},
+		{"contained", sq, RotatedBox{W: 1, H: 1, Angle: 0.3}, 0.25},
+		{"empty", sq, RotatedBox{W: 0, H: 2}, 0},
+	}
+	for _, c := range cases {
+		for _, p := range [][2]RotatedBox{{c.a, c.b}, {c.b, c.a}} {
+			if got := float64(RotatedIoU(p[0], p[1])); math.Abs(got-c.want) > 1e-5 {
+				t.Errorf("%s: IoU %v, want %v", c.name, got, c.want)
+			}
+		}
+	}
+}
+
+func TestRotatedIoUMatchesBoxIoU(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	rect := func() image.Rectangle {
+		x, y := r.Intn(50), r.Intn(50)
+		return image.Rect(x, y, x+r.Intn(30), y+r.Intn(30))
+	}
+	for i := 0; i < 500; i++ {
+		a, b := rect(), rect()
+		got := RotatedIoU(RotatedBoxFromRect(a), RotatedBoxFromRect(b))
+		if want := BoxIoU(a, b); math.Abs(float64(got-want)) > 1e-5 {
+			t.Fatalf("%v %v: rotated IoU %v, BoxIoU %v", a, b, got, want)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package goincv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// SafetensorsInfo describes one tensor entry of a safetensors header.
+type SafetensorsInfo struct {
+	DType       string   `json:"dtype"`
+	Shape       Shape    `json:"shape"`
+	DataOffsets [2]int64 `json:"data_offsets"`
+}
+
+// SafetensorsFile gives lazy access to the tensors of a safetensors file.
+// On unix the file is memory-mapped, so only the tensors that are read
+// are paged in.
+type SafetensorsFile struct {
+	Metadata map[string]string
+
+	infos map[string]SafetensorsInfo
+	data  []byte // whole data section when mapped or held in memory
+	file  *os.File
+	base  int64
+	unmap func() error
+}
+
+var safetensorsDTypes = map[DataType]string{
+	DataTypeFloat64:  "F64",
+	DataTypeFloat32:  "F32",
+	DataTypeFloat16:  "F16",
+	DataTypeBFloat16: "BF16",
+	DataTypeInt64:    "I64",
+	DataTypeInt:      "I64",
+	DataTypeInt32:    "I32",
+	DataTypeInt16:    "I16",
+	DataTypeInt8:     "I8",
+	DataTypeUInt64:   "U64",
+	DataTypeUInt:     "U64",
+	DataTypeUInt32:   "U32",
+	DataTypeUInt16:   "U16",
+	DataTypeUInt8:    "U8",
+}
+
+// OpenSafetensors parses the header of a safetensors file. Tensor data is
+// only read on demand; call Close when done.
+func OpenSafetensors(path string) (*SafetensorsFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var n uint64
+	if err := binary.Read(f, binary.LittleEndian, &n); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if n > uint64(st.Size()-8) {
+		f.Close()
+		return nil, errors.New("invalid safetensors header size")
+	}
+	header := make([]byte, n)
+	if _, err := io.ReadFull(f, header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	sf, err := parseSafetensorsHeader(header)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	sf.file = f
+	sf.base = 8 + int64(n)
+
+	if mapped, unmap, err := mmapFile(f, st.Size()); err == nil && mapped != nil {
+		sf.data = mapped[sf.base:]
+		sf.unmap = unmap
+	}
+	if err := sf.check(st.Size() - sf.base); err != nil {
+		sf.Close()
+		return nil, err
+	}
+	return sf, nil
+}
+
+// ReadSafetensors parses a safetensors buffer already held in memory.
+func ReadSafetensors(buf []byte) (*SafetensorsFile, error) {
+	if len(buf) < 8 {
+		return nil, errors.New("invalid safetensors data")
+	}
+	n := binary.LittleEndian.Uint64(buf)
+	if n > uint64(len(buf)-8) {
+		return nil, errors.New("invalid safetensors header size")
+	}
+	sf, err := parseSafetensorsHeader(buf[8 : 8+n])
+	if err != nil {
+		return nil, err
+	}
+	sf.data = buf[8+n:]
+	if err := sf.check(int64(len(sf.data))); err != nil {
+		return nil, err
+	}
+	return sf, nil
+}
+
+func parseSafetensorsHeader(header []byte) (*SafetensorsFile, error) {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(header, &raw); err != nil {
+		return nil, fmt.Errorf("invalid safetensors header: %v", err)
+	}
+	sf := &SafetensorsFile{
+		Metadata: map[string]string{},
+		infos:    map[string]SafetensorsInfo{},
+	}
+	for name, msg := range raw {
+		if name == "__metadata__" {
+			if err := json.Unmarshal(msg, &sf.Metadata); err != nil {
+				return nil, fmt.Errorf("invalid safetensors metadata: %v", err)
+			}
+			continue
+		}
+		info := SafetensorsInfo{}
+		if err := json.Unmarshal(msg, &info); err != nil {
+			return nil, fmt.Errorf("invalid safetensors entry %s: %v", name, err)
+		}
+		sf.infos[name] = info
+	}
+	return sf, nil
+}
+
+// check validates every entry against the data section size.
+func (f *SafetensorsFile) check(size int64) error {
+	for name, info := range f.infos {
+		b, e := info.DataOffsets[0], info.DataOffsets[1]
+		if b < 0 || e < b || e > size {
+			return fmt.Errorf("safetensors entry %s out of range", name)
+		}
+		if safetensorsItemSize(info.DType) == 0 {
+			return fmt.Errorf("unsupported safetensors dtype %s for %s", info.DType, name)
+		}
+		if n, ok := info.byteSize(e - b); !ok || n != e-b {
+			return fmt.Errorf("safetensors entry %s size does not match shape %v", name, info.Shape)
+		}
+	}
+	return nil
+}
+
+// byteSize returns the data size implied by dtype and shape; ok is false
+// for negative dimensions or a size above limit.
+func (info SafetensorsInfo) byteSize(limit int64) (int64, bool) {
+	n := int64(safetensorsItemSize(info.DType))
+	for _, d := range info.Shape {
+		if d < 0 {
+			return 0, false
+		}
+		if d == 0 {
+			return 0, true
+		}
+	}
+	for _, d := range info.Shape {
+		if n > limit/int64(d) {
+			return 0, false
+		}
+		n *= int64(d)
+	}
+	return n, true
+}
+
+// Keys returns the tensor names in sorted order.
+func (f *SafetensorsFile) Keys() []string {
+	ret := make([]string, 0, len(f.infos))
+	for name := range f.infos {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (f *SafetensorsFile) Info(name string) (SafetensorsInfo, bool) {
+	info, ok := f.infos[name]
+	return info, ok
+}
+
+// Bytes returns the raw little endian bytes of a tensor. When the file is
+// mapped the slice points into the mapping and is only valid until Close.
+func (f *SafetensorsFile) Bytes(name string) ([]byte, error) {
+	info, ok := f.infos[name]
+	if !ok {
+		return nil, fmt.Errorf("safetensors has no tensor %s", name)
+	}
+	b, e := info.DataOffsets[0], info.DataOffsets[1]
+	if f.data != nil {
+		return f.data[b:e], nil
+	}
+	if f.file == nil {
+		return nil, errors.New("safetensors file is closed")
+	}
+	buf := make([]byte, e-b)
+	if _, err := f.file.ReadAt(buf, f.base+b); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Tensor decodes one tensor into a newly allocated Tensor whose element
+// type follows the stored dtype.
+func (f *SafetensorsFile) Tensor(name string) (AnyTensor, error) {
+	raw, err := f.Bytes(name)
+	if err != nil {
+		return nil, err
+	}
+	info := f.infos[name]
+	return decodeSafetensor(info.DType, info.Shape, raw)
+}
+
+// Tensors decodes every tensor of the file.
+func (f *SafetensorsFile) Tensors() (map[string]AnyTensor, error) {
+	ret := map[string]AnyTensor{}
+	for _, name := range f.Keys() {
+		t, err := f.Tensor(name)
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = t
+	}
+	return ret, nil
+}
+
+func (f *SafetensorsFile) Close() error {
+	var err error
+	if f.unmap != nil {
+		err = f.unmap()
+		f.unmap = nil
+	}
+	f.data = nil
+	if f.file != nil {
+		if cerr := f.file.Close(); err == nil {
+			err = cerr
+		}
+		f.file = nil
+	}
+	return err
+}
+
+func safetensorsItemSize(dtype string) int {
+	switch dtype {
+	case "F64", "I64", "U64":
+		return 8
+	case "F32", "I32", "U32":
+		return 4
+	case "F16", "BF16", "I16", "U16":
+		return 2
+	case "I8", "U8", "BOOL":
+		return 1
+	}
+	return 0
+}
+
+func decodeSafetensor(dtype string, shape Shape, raw []byte) (AnyTensor, error) {
+	switch dtype {
+	case "F64":
+		return decodeLE[float64](raw, shape)
+	case "F32":
+		return decodeLE[float32](raw, shape)
+	case "F16":
+		return decodeHalfLE[Float16](raw, shape)
+	case "BF16":
+		return decodeHalfLE[BFloat16](raw, shape)
+	case "I64":
+		return decodeLE[int64](raw, shape)
+	case "I32":
+		return decodeLE[int32](raw, shape)
+	case "I16":
+		return decodeLE[int16](raw, shape)
+	case "I8":
+		return decodeLE[int8](raw, shape)
+	case "U64":
+		return decodeLE[uint64](raw, shape)
+	case "U32":
+		return decodeLE[uint32](raw, shape)
+	case "U16":
+		return decodeLE[uint16](raw, shape)
+	case "U8", "BOOL":
+		return decodeLE[uint8](raw, shape)
+	}
+	return nil, fmt.Errorf("unsupported safetensors dtype %s", dtype)
+}
+
+func decodeLE[T Number](raw []byte, shape Shape) (*Tensor[T], error) {
+	data := make([]T, shape.Size())
+	if err := binary.Read(bytes.NewReader(raw), binary.LittleEndian, data); err != nil {
+		return nil, err
+	}
+	return TensorFrom(data, shape)
+}
+
+func decodeHalfLE[T Half](raw []byte, shape Shape) (*HalfTensor[T], error) {
+	bits, err := decodeLE[uint16](raw, shape)
+	if err != nil {
+		return nil, err
+	}
+	return halfFromBits[T](bits), nil
+}
+
+// WriteSafetensors encodes named tensors and optional metadata in the
+// safetensors format. Entries are laid out in name order.
+func WriteSafetensors(w io.Writer, tensors map[string]AnyTensor, metadata map[string]string) error {
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		if name == "__metadata__" {
+			return errors.New("__metadata__ is reserved")
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	header := map[string]interface{}{}
+	if len(metadata) > 0 {
+		header["__metadata__"] = metadata
+	}
+	var offset int64
+	for _, name := range names {
+		t := tensors[name]
+		dtype, ok := safetensorsDTypes[t.DataType()]
+		if !ok {
+			return fmt.Errorf("safetensors does not support %v", t.DataType())
+		}
+		size := int64(t.Size() * safetensorsItemSize(dtype))
+		shape := t.Shape()
+		if shape == nil {
+			shape = Shape{}
+		}
+		header[name] = SafetensorsInfo{
+			DType:       dtype,
+			Shape:       shape,
+			DataOffsets: [2]int64{offset, offset + size},
+		}
+		offset += size
+	}
+	headerData, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	// the data section is kept 8 byte aligned like the reference writer
+	for len(headerData)%8 != 0 {
+		headerData = append(headerData, ' ')
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint64(len(headerData))); err != nil {
+		return err
+	}
+	if _, err := w.Write(headerData); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := writeTensorData(w, tensors[name]); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// SaveSafetensors writes named tensors to a safetensors file.
+func SaveSafetensors(path string, tensors map[string]AnyTensor, metadata map[string]string) error {
+	return writeFileWith(path, func(w io.Writer) error {
+		return WriteSafetensors(w, tensors, metadata)
+	})
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package goincv
+
+import (
+	"os"
+)
+
+// mmapFile is not available here; tensors are read with ReadAt instead.
+func mmapFile(f *os.File, size int64) ([]byte, func() error, error) {
+	return nil, nil, nil
+}
@@ -0,0 +1,90 @@
+package goincv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafetensorsRoundTrip(t *testing.T) {
+	tensors := map[string]AnyTensor{
+		"w":     MustTensorFrom([]float32{1, 2, 3, 4, 5, 6}, Shape{2, 3}),
+		"b":     MustTensorFrom([]float64{-1}, Shape{}),
+		"ids":   MustTensorFrom([]int64{7, 8, 9}, Shape{3}),
+		"mask":  MustTensorFrom([]uint8{1, 0}, Shape{2}),
+		"f16":   TensorToF16(MustTensorFrom([]float32{0.5, -2}, Shape{2})),
+		"bf16":  TensorToBF16(MustTensorFrom([]float32{3, 0.25}, Shape{1, 2})),
+		"empty": MustTensorFrom([]int32{}, Shape{0, 4}),
+	}
+	meta := map[string]string{"format": "pt"}
+	buf := bytes.NewBuffer(nil)
+	if err := WriteSafetensors(buf, tensors, meta); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "m.safetensors")
+	if err := SaveSafetensors(path, tensors, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	mem, err := ReadSafetensors(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := OpenSafetensors(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	for _, sf := range []*SafetensorsFile{mem, file} {
+		if sf.Metadata["format"] != "pt" {
+			t.Errorf("metadata %v", sf.Metadata)
+		}
+		got, err := sf.Tensors()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tensors) {
+			t.Errorf("got %d tensors, want %d", len(got), len(tensors))
+		}
+		for name, want := range tensors {
+			g := got[name]
+			if g == nil || g.DataType() != want.DataType() || !g.Shape().Equal(want.Shape()) || fmt.Sprint(g) != fmt.Sprint(want) {
+				t.Errorf("%s: got %v, want %v", name, g, want)
+			}
+		}
+	}
+}
+
+func TestSafetensorsMalformed(t *testing.T) {
+	build := func(header string, data int) []byte {
+		b := binary.LittleEndian.AppendUint64(nil, uint64(len(header)))
+		b = append(b, header...)
+		return append(b, make([]byte, data)...)
+	}
+	cases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"short", []byte{1, 2, 3}, "invalid safetensors data"},
+		{"header past end", build("{}", 0)[:9], "header size"},
+		{"header size wraps", append(binary.LittleEndian.AppendUint64(nil, 1<<64-4), "{}"...), "header size"},
+		{"bad json", build("{", 0), "invalid safetensors header"},
+		{"offsets past data", build(`{"a":{"dtype":"F32","shape":[2],"data_offsets":[0,8]}}`, 4), "out of range"},
+		{"negative offset", build(`{"a":{"dtype":"F32","shape":[1],"data_offsets":[-4,0]}}`, 4), "out of range"},
+		{"reversed offsets", build(`{"a":{"dtype":"F32","shape":[0],"data_offsets":[4,0]}}`, 4), "out of range"},
+		{"size mismatch", build(`{"a":{"dtype":"F32","shape":[2],"data_offsets":[0,4]}}`, 8), "does not match"},
+		{"negative dim", build(`{"a":{"dtype":"F32","shape":[-1,-1],"data_offsets":[0,4]}}`, 4), "does not match"},
+		{"dim overflow", build(`{"a":{"dtype":"U8","shape":[4294967296,4294967296],"data_offsets":[0,0]}}`, 0), "does not match"},
+		{"dtype", build(`{"a":{"dtype":"C64","shape":[1],"data_offsets":[0,8]}}`, 8), "unsupported"},
+	}
+	for _, c := range cases {
+		_, err := ReadSafetensors(c.data)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got %v, want error containing %q", c.name, err, c.want)
+		}
+	}
+}
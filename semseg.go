@@ -0,0 +1,209 @@
+package goincv
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// LabelMap holds one class id per pixel of Rect.
+type LabelMap struct {
+	Pix    []uint16
+	Stride int
+	Rect   image.Rectangle
+}
+
+func NewLabelMap(r image.Rectangle) *LabelMap {
+	return &LabelMap{Pix: make([]uint16, r.Dx()*r.Dy()), Stride: r.Dx(), Rect: r}
+}
+
+// At returns the class of pixel (x, y), 0 outside Rect.
+func (m *LabelMap) At(x, y int) int {
+	if !(image.Point{x, y}.In(m.Rect)) {
+		return 0
+	}
+	return int(m.Pix[(y-m.Rect.Min.Y)*m.Stride+x-m.Rect.Min.X])
+}
+
+// DecodeLabelMap turns the output of a semantic segmentation model into a
+// label map over Source, which must be set. logits is [C, h, w] with an
+// optional batch axis, argmaxed over C, or an integer [h, w] map from models
+// that already include the argmax. h and w may be smaller than the model
+// input; each source pixel takes the class of the cell it falls in after
+// undoing the letterbox with ScaleParams.
+func (d *DetectDecoder) DecodeLabelMap(logits AnyTensor) (*LabelMap, error) {
+	if d.Source.Empty() {
+		return nil, errors.New("decoder has no Source rectangle")
+	}
+	classes, h, w, err := argmaxClasses(logits)
+	if err != nil {
+		return nil, err
+	}
+	m := NewLabelMap(d.Source)
+	sx, sy := float32(w)/float32(d.Width), float32(h)/float32(d.Height)
+	cols := make([]int, m.Rect.Dx())
+	for i := range cols {
+		mx, _ := d.ScaleParams.Scale(float32(m.Rect.Min.X+i)+0.5, 0)
+		cols[i] = clampIndex(int(mx*sx), 0, w-1)
+	}
+	for y := m.Rect.Min.Y; y < m.Rect.Max.Y; y++ {
+		_, my := d.ScaleParams.Scale(0, float32(y)+0.5)
+		gy := clampIndex(int(my*sy), 0, h-1)
+		row := m.Pix[(y-m.Rect.Min.Y)*m.Stride:][:len(cols)]
+		src := classes[gy*w:]
+		for i, gx := range cols {
+			row[i] = src[gx]
+		}
+	}
+	return m, nil
+}
+
+// argmaxClasses reduces the model output to one class per cell.
+func argmaxClasses(logits AnyTensor) (classes []uint16, h, w int, err error) {
+	if isLabelTensor(logits) {
+		t, err := AsTensor[int64](logits)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		for len(t.shape) > 2 && t.shape[0] == 1 {
+			t = t.Index(0)
+		}
+		if len(t.shape) != 2 {
+			return nil, 0, 0, fmt.Errorf("label map %v is not 2-D", logits.Shape())
+		}
+		data := t.Contiguous().Data()
+		classes = make([]uint16, len(data))
+		for i, v := range data {
+			// uint64 labels above MaxInt64 wrap negative and fail here too
+			if v < 0 || v > math.MaxUint16 {
+				return nil, 0, 0, fmt.Errorf("label %d out of range", v)
+			}
+			classes[i] = uint16(v)
+		}
+		return classes, t.shape[0], t.shape[1], nil
+	}
+	t, err := squeezeBatch(logits, 3)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	c, h, w := t.shape[0], t.shape[1], t.shape[2]
+	if c > 1<<16 {
+		return nil, 0, 0, fmt.Errorf("too many classes %d", c)
+	}
+	data, plane := t.data[t.offset:], h*w
+	classes = make([]uint16, plane)
+	best := append([]float32(nil), data[:plane]...)
+	for k := 1; k < c; k++ {
+		for i, v := range data[k*plane:][:plane] {
+			if v > best[i] {
+				best[i], classes[i] = v, uint16(k)
+			}
+		}
+	}
+	return classes, h, w, nil
+}
+
+// isLabelTensor reports whether t holds integer class ids rather than
+// logits.
+func isLabelTensor(t AnyTensor) bool {
+	switch t.DataType() {
+	case DataTypeUInt8, DataTypeUInt16, DataTypeUInt32, DataTypeUInt64, DataTypeUInt:
+		return true
+	}
+	return isIntTensor(t)
+}
+
+// Palette maps class ids to colours. Entries with zero alpha are not drawn
+// by Overlay, which is the usual way to leave the background class out.
+type Palette []color.RGBA
+
+// DefaultPalette returns the n colour PASCAL VOC palette, which keeps
+// neighbouring ids visually distinct.
+func DefaultPalette(n int) Palette {
+	p := make(Palette, n)
+	for i := range p {
+		var r, g, b uint8
+		for c, j := i, 0; c > 0; c, j = c>>3, j+1 {
+			r |= uint8(c&1) << (7 - j)
+			g |= uint8(c>>1&1) << (7 - j)
+			b |= uint8(c>>2&1) << (7 - j)
+		}
+		p[i] = color.RGBA{r, g, b, 255}
+	}
+	return p
+}
+
+func (p Palette) color(class int) color.RGBA {
+	if len(p) == 0 {
+		return color.RGBA{}
+	}
+	return p[class%len(p)]
+}
+
+// Colorize paints every pixel with the colour of its class.
+func (m *LabelMap) Colorize(p Palette) *image.RGBA {
+	ret := image.NewRGBA(m.Rect)
+	for y := 0; y < m.Rect.Dy(); y++ {
+		row := ret.Pix[y*ret.Stride:]
+		for x, c := range m.Pix[y*m.Stride:][:m.Rect.Dx()] {
+			col := p.color(int(c))
+			row[x*4], row[x*4+1], row[x*4+2], row[x*4+3] = col.R, col.G, col.B, col.A
+		}
+	}
+	return ret
+}
+
+// Overlay blends the class colours over img with the given opacity in
+// [0, 1]. Pixels whose palette entry has zero alpha keep the image.
+func (m *LabelMap) Overlay(img image.Image, p Palette, alpha float32) *image.RGBA {
+	ret := image.NewRGBA(img.Bounds())
+	draw.Draw(ret, ret.Bounds(), img, img.Bounds().Min, draw.Src)
+	a := uint32(alpha*256 + 0.5)
+	if a > 256 {
+		a = 256
+	}
+	r := m.Rect.Intersect(ret.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			col := p.color(m.At(x, y))
+			if col.A == 0 {
+				continue
+			}
+			o := ret.PixOffset(x, y)
+			px := ret.Pix[o : o+3 : o+3]
+			for i, v := range [3]uint8{col.R, col.G, col.B} {
+				px[i] = uint8((uint32(px[i])*(256-a) + uint32(v)*a) >> 8)
+			}
+		}
+	}
+	return ret
+}
+
+// ClassMask returns the pixels of one class as an opaque alpha mask over
+// Rect, suitable for ExtractionBasisMask.
+func (m *LabelMap) ClassMask(class int) *image.Alpha {
+	ret := image.NewAlpha(m.Rect)
+	for y := 0; y < m.Rect.Dy(); y++ {
+		row := ret.Pix[y*ret.Stride:]
+		for x, c := range m.Pix[y*m.Stride:][:m.Rect.Dx()] {
+			if int(c) == class {
+				row[x] = 255
+			}
+		}
+	}
+	return ret
+}
+
+// Classes lists the class ids present in the map with their pixel counts.
+func (m *LabelMap) Classes() map[int]int {
+	ret := map[int]int{}
+	for y := 0; y < m.Rect.Dy(); y++ {
+		for _, c := range m.Pix[y*m.Stride:][:m.Rect.Dx()] {
+			ret[int(c)]++
+		}
+	}
+	return ret
+}
@@ -0,0 +1,37 @@
+package goincv
+
+import (
+	"image"
+	"testing"
+)
+
+func TestDecodeLabelMapIntegerLabels(t *testing.T) {
+	d := NewDetectDecoder(image.Rect(0, 0, 2, 2), 2, 2)
+	labels := []AnyTensor{
+		MustTensorFrom([]uint8{0, 1, 2, 255}, Shape{2, 2}),
+		MustTensorFrom([]uint16{0, 1, 2, 255}, Shape{1, 2, 2}),
+		MustTensorFrom([]int32{0, 1, 2, 255}, Shape{2, 2}),
+		MustTensorFrom([]int64{0, 1, 2, 255}, Shape{1, 1, 2, 2}),
+	}
+	for _, l := range labels {
+		m, err := d.DecodeLabelMap(l)
+		if err != nil {
+			t.Errorf("%v: %v", l.DataType(), err)
+			continue
+		}
+		if m.At(0, 0) != 0 || m.At(1, 0) != 1 || m.At(0, 1) != 2 || m.At(1, 1) != 255 {
+			t.Errorf("%v: got %v", l.DataType(), m.Pix)
+		}
+	}
+
+	bad := []AnyTensor{
+		MustTensorFrom([]int64{0, -1, 2, 3}, Shape{2, 2}),
+		MustTensorFrom([]int32{0, 1 << 16, 2, 3}, Shape{2, 2}),
+		MustTensorFrom([]uint64{0, 1 << 63, 2, 3}, Shape{2, 2}),
+	}
+	for _, l := range bad {
+		if _, err := d.DecodeLabelMap(l); err == nil {
+			t.Errorf("%v: out of range label was accepted", l.DataType())
+		}
+	}
+}
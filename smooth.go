@@ -0,0 +1,222 @@
+package goincv
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+// SmoothMethod selects the per-coordinate filter of a Smoother.
+type SmoothMethod int
+
+const (
+	SmoothOneEuro SmoothMethod = iota // adaptive low pass, little lag on fast motion
+	SmoothKalman                      // constant velocity Kalman filter
+	SmoothEMA                         // exponential moving average
+)
+
+// Smoother removes frame to frame jitter from boxes and landmarks, keeping
+// independent filter state per track ID. Timestamps may be irregular; a
+// frame with a timestamp not after the previous one returns the last
+// output unchanged. It is safe for concurrent use. Fields left at zero take
+// the NewSmoother defaults, except Beta and ProcessNoise where zero is a
+// valid setting.
+type Smoother struct {
+	Method SmoothMethod
+
+	MinCutoff float64 // One Euro minimum cutoff frequency in Hz
+	Beta      float64 // One Euro speed coefficient
+	DCutoff   float64 // One Euro derivative cutoff in Hz
+
+	Alpha float64 // EMA weight of the new value
+
+	ProcessNoise     float64 // Kalman acceleration variance
+	MeasurementNoise float64 // Kalman measurement variance
+
+	MaxAge float64 // a track unseen for longer starts over
+
+	mu     sync.Mutex
+	tracks map[int]*smoothTrack
+}
+
+// NewSmoother returns a smoother for pixel coordinates and timestamps in
+// seconds.
+func NewSmoother(method SmoothMethod) *Smoother {
+	return &Smoother{
+		Method:           method,
+		MinCutoff:        1,
+		Beta:             0.05,
+		DCutoff:          1,
+		Alpha:            0.5,
+		ProcessNoise:     1000,
+		MeasurementNoise: 4,
+		MaxAge:           1,
+	}
+}
+
+// orDefault replaces unset or negative parameters, so a zero Smoother does
+// not restart every track or freeze its output.
+func orDefault(v, def float64) float64 {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+type smoothTrack struct {
+	last   float64
+	filter vectorFilter
+	out    []float64
+}
+
+// vectorFilter filters one sample of a fixed number of coordinates.
+type vectorFilter interface {
+	filter(t float64, x []float64) []float64
+}
+
+// Smooth filters the rectangle and landmarks of the box of track id at
+// time t and returns the smoothed copy.
+func (s *Smoother) Smooth(id int, t float64, b Box) Box {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	x := make([]float64, 4+2*len(b.Landmark))
+	x[0], x[1] = float64(b.Rectangle.Min.X), float64(b.Rectangle.Min.Y)
+	x[2], x[3] = float64(b.Rectangle.Max.X), float64(b.Rectangle.Max.Y)
+	for i, l := range b.Landmark {
+		x[4+2*i], x[5+2*i] = float64(l.X), float64(l.Y)
+	}
+	if s.tracks == nil {
+		s.tracks = map[int]*smoothTrack{}
+	}
+	tr := s.tracks[id]
+	switch {
+	case tr == nil || len(tr.out) != len(x) || t-tr.last > orDefault(s.MaxAge, 1):
+		tr = &smoothTrack{last: t, filter: s.newFilter(t, x), out: x}
+		s.tracks[id] = tr
+	case t > tr.last:
+		tr.out = tr.filter.filter(t, x)
+		tr.last = t
+	}
+	y := tr.out
+	b.Rectangle = image.Rect(roundInt(y[0]), roundInt(y[1]), roundInt(y[2]), roundInt(y[3]))
+	b.Landmark = make([]BoxLandmark, len(b.Landmark))
+	for i := range b.Landmark {
+		b.Landmark[i] = BoxLandmark{X: roundInt(y[4+2*i]), Y: roundInt(y[5+2*i])}
+	}
+	return b
+}
+
+// SmoothTracks smooths the boxes of the tracks returned by Tracker.Update
+// in place, and forgets tracks unseen for MaxAge.
+func (s *Smoother) SmoothTracks(t float64, tracks []Track) []Track {
+	for i := range tracks {
+		tracks[i].Box = s.Smooth(tracks[i].ID, t, tracks[i].Box)
+	}
+	s.Prune(t)
+	return tracks
+}
+
+// Prune forgets the tracks not updated within MaxAge before t.
+func (s *Smoother) Prune(t float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	maxAge := orDefault(s.MaxAge, 1)
+	for id, tr := range s.tracks {
+		if t-tr.last > maxAge {
+			delete(s.tracks, id)
+		}
+	}
+}
+
+// Remove forgets one track, e.g. when the tracker drops it.
+func (s *Smoother) Remove(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tracks, id)
+}
+
+// Reset forgets every track.
+func (s *Smoother) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracks = nil
+}
+
+func (s *Smoother) newFilter(t float64, x []float64) vectorFilter {
+	switch s.Method {
+	case SmoothKalman:
+		r := orDefault(s.MeasurementNoise, 4)
+		f := &kalmanVec{t: t, q: s.ProcessNoise, r: r, x: append([]float64(nil), x...)}
+		f.v = make([]float64, len(x))
+		f.p = make([][3]float64, len(x))
+		for i := range f.p {
+			f.p[i] = [3]float64{r, 0, 1e4}
+		}
+		return f
+	case SmoothEMA:
+		return &emaVec{alpha: orDefault(s.Alpha, 0.5), x: append([]float64(nil), x...)}
+	}
+	minCutoff, dCutoff := orDefault(s.MinCutoff, 1), orDefault(s.DCutoff, 1)
+	f := make(oneEuroVec, len(x))
+	for i, v := range x {
+		f[i] = NewOneEuroFilter(t, v, 0, minCutoff, s.Beta, dCutoff)
+	}
+	return f
+}
+
+type oneEuroVec []*OneEuroFilter
+
+func (f oneEuroVec) filter(t float64, x []float64) []float64 {
+	ret := make([]float64, len(x))
+	for i, v := range x {
+		ret[i] = f[i].Filter(t, v)
+	}
+	return ret
+}
+
+type emaVec struct {
+	alpha float64
+	x     []float64
+}
+
+func (f *emaVec) filter(t float64, x []float64) []float64 {
+	for i, v := range x {
+		f.x[i] = exponentialSmoothing(f.alpha, v, f.x[i])
+	}
+	return append([]float64(nil), f.x...)
+}
+
+// kalmanVec runs an independent position and velocity filter per
+// coordinate, with process noise scaled to the elapsed time.
+type kalmanVec struct {
+	t, q, r float64
+	x, v    []float64
+	p       [][3]float64 // covariance entries xx, xv, vv
+}
+
+func (f *kalmanVec) filter(t float64, z []float64) []float64 {
+	dt := t - f.t
+	f.t = t
+	ret := make([]float64, len(z))
+	for i := range z {
+		pxx, pxv, pvv := f.p[i][0], f.p[i][1], f.p[i][2]
+		// predict
+		x := f.x[i] + dt*f.v[i]
+		pxx += dt*(2*pxv+dt*pvv) + f.q*dt*dt*dt/3
+		pxv += dt*pvv + f.q*dt*dt/2
+		pvv += f.q * dt
+		// update
+		s := pxx + f.r
+		kx, kv := pxx/s, pxv/s
+		y := z[i] - x
+		f.x[i] = x + kx*y
+		f.v[i] += kv * y
+		f.p[i] = [3]float64{(1 - kx) * pxx, (1 - kx) * pxv, pvv - kv*pxv}
+		ret[i] = f.x[i]
+	}
+	return ret
+}
+
+func roundInt(v float64) int {
+	return int(math.Round(v))
+}
@@ -0,0 +1,160 @@
+package goincv
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func smoothBox(x int) Box {
+	return Box{Rectangle: image.Rect(x, 50, x+40, 90), Landmark: []BoxLandmark{{X: x + 10, Y: 60}}}
+}
+
+// smoothJitter feeds a box moving at speed px/s with up to 4 px of jitter at
+// about 30 fps and returns the mean error of the raw and smoothed left edge.
+func smoothJitter(s *Smoother, speed float64) (raw, smoothed float64) {
+	rnd := rand.New(rand.NewSource(5))
+	n := 0
+	for f := 0; f < 200; f++ {
+		ts := float64(f)/30 + rnd.Float64()*0.005
+		x := 100 + speed*ts
+		b := smoothBox(int(math.Round(x)) + rnd.Intn(9) - 4)
+		o := s.Smooth(7, ts, b)
+		if f > 30 {
+			raw += math.Abs(float64(b.Rectangle.Min.X) - x)
+			smoothed += math.Abs(float64(o.Rectangle.Min.X) - x)
+			n++
+		}
+	}
+	return raw / float64(n), smoothed / float64(n)
+}
+
+func TestSmootherJitter(t *testing.T) {
+	for _, m := range []SmoothMethod{SmoothOneEuro, SmoothKalman, SmoothEMA} {
+		for _, speed := range []float64{0, 20} {
+			raw, smoothed := smoothJitter(NewSmoother(m), speed)
+			if !(smoothed < raw*0.8) {
+				t.Errorf("method %d speed %v: smoothed error %.2f, raw %.2f", m, speed, smoothed, raw)
+			}
+		}
+	}
+}
+
+func TestSmootherEMA(t *testing.T) {
+	s := NewSmoother(SmoothEMA)
+	s.Alpha = 0.25
+	in := []int{0, 100, 100, 100}
+	want := []int{0, 25, 44, 58}
+	for i, w := range want {
+		got := s.Smooth(1, float64(i)*0.1, smoothBox(in[i]))
+		if got.Rectangle.Min.X != w || got.Rectangle.Max.X != w+40 || got.Landmark[0].X != w+10 {
+			t.Errorf("frame %d: got %v %v, want x %d", i, got.Rectangle, got.Landmark, w)
+		}
+	}
+}
+
+func TestSmootherKalman(t *testing.T) {
+	// constant velocity is what the model expects, so it should track it
+	// without the lag an EMA has
+	k, e := NewSmoother(SmoothKalman), NewSmoother(SmoothEMA)
+	var kErr, eErr float64
+	for f := 0; f < 60; f++ {
+		ts := float64(f) / 30
+		b := smoothBox(300 * f / 30)
+		ko, eo := k.Smooth(1, ts, b), e.Smooth(1, ts, b)
+		if f >= 30 {
+			kErr += math.Abs(float64(ko.Rectangle.Min.X - b.Rectangle.Min.X))
+			eErr += math.Abs(float64(eo.Rectangle.Min.X - b.Rectangle.Min.X))
+		}
+	}
+	if kErr/30 > 1 || kErr >= eErr {
+		t.Errorf("kalman lag %.2f px, ema %.2f px", kErr/30, eErr/30)
+	}
+}
+
+func TestSmootherMaxAge(t *testing.T) {
+	s := NewSmoother(SmoothEMA)
+	s.Smooth(1, 0, smoothBox(0))
+	if got := s.Smooth(1, 0.5, smoothBox(100)).Rectangle.Min.X; got != 50 {
+		t.Errorf("within MaxAge: x %d, want 50", got)
+	}
+	// unseen for longer than MaxAge, so the track starts over
+	if got := s.Smooth(1, 2, smoothBox(200)).Rectangle.Min.X; got != 200 {
+		t.Errorf("after MaxAge: x %d, want 200", got)
+	}
+	// a different landmark count starts over as well
+	b := smoothBox(0)
+	b.Landmark = nil
+	if got := s.Smooth(1, 2.1, b).Rectangle.Min.X; got != 0 {
+		t.Errorf("new layout: x %d, want 0", got)
+	}
+
+	s.Smooth(2, 2.1, smoothBox(0))
+	s.Prune(3)
+	if len(s.tracks) != 2 {
+		t.Errorf("Prune within MaxAge kept %d tracks", len(s.tracks))
+	}
+	tracks := s.SmoothTracks(3.5, []Track{{ID: 3, Box: smoothBox(10)}})
+	if len(s.tracks) != 1 || tracks[0].Box.Rectangle.Min.X != 10 {
+		t.Errorf("SmoothTracks kept %d tracks, box %v", len(s.tracks), tracks[0].Box.Rectangle)
+	}
+	s.Remove(3)
+	if len(s.tracks) != 0 {
+		t.Errorf("Remove left %d tracks", len(s.tracks))
+	}
+}
+
+func TestSmootherZeroValue(t *testing.T) {
+	for _, m := range []SmoothMethod{SmoothOneEuro, SmoothKalman, SmoothEMA} {
+		s := &Smoother{Method: m}
+		s.Smooth(1, 0, smoothBox(0))
+		s.Smooth(1, 1.0/30, smoothBox(0))
+		got := s.Smooth(1, 2.0/30, smoothBox(100)).Rectangle.Min.X
+		if got <= 0 || got >= 100 {
+			t.Errorf("method %d: zero Smoother gave x %d, want a value between 0 and 100", m, got)
+		}
+	}
+	raw, smoothed := smoothJitter(&Smoother{}, 0)
+	if !(smoothed < raw*0.8) {
+		t.Errorf("zero Smoother: smoothed error %.2f, raw %.2f", smoothed, raw)
+	}
+}
+
+func TestSmootherTimestamps(t *testing.T) {
+	// a longer gap lets a step through further
+	step := func(m SmoothMethod, dt float64) int {
+		s := NewSmoother(m)
+		s.Smooth(1, 0, smoothBox(0))
+		return s.Smooth(1, dt, smoothBox(100)).Rectangle.Min.X
+	}
+	for _, m := range []SmoothMethod{SmoothOneEuro, SmoothKalman} {
+		if short, long := step(m, 0.01), step(m, 0.5); !(short < long) {
+			t.Errorf("method %d: step after 10ms %d, after 500ms %d", m, short, long)
+		}
+	}
+
+	// repeated or older timestamps return the last output
+	s := NewSmoother(SmoothOneEuro)
+	s.Smooth(1, 0, smoothBox(0))
+	last := s.Smooth(1, 0.1, smoothBox(50))
+	for _, ts := range []float64{0.1, 0.05} {
+		if got := s.Smooth(1, ts, smoothBox(90)); got.Rectangle != last.Rectangle {
+			t.Errorf("timestamp %v: got %v, want %v", ts, got.Rectangle, last.Rectangle)
+		}
+	}
+
+	// irregular frame times on constant velocity keep the Kalman filter on
+	// the track
+	k := NewSmoother(SmoothKalman)
+	rnd := rand.New(rand.NewSource(3))
+	ts := 0.0
+	for f := 0; f < 80; f++ {
+		ts += 0.01 + rnd.Float64()*0.1
+		x := 200 * ts
+		o := k.Smooth(1, ts, smoothBox(int(math.Round(x))))
+		if f > 40 && math.Abs(float64(o.Rectangle.Min.X)-x) > 2 {
+			t.Fatalf("frame %d at %.3fs: x %d, want %.1f", f, ts, o.Rectangle.Min.X, x)
+		}
+	}
+}
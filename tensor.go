@@ -0,0 +1,559 @@
+package goincv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Number is the set of element types a Tensor can hold. The half
+// precision types are not numbers to Go, see HalfTensor.
+type Number interface {
+	float32 | float64 |
+		int | int8 | int16 | int32 | int64 |
+		uint | uint8 | uint16 | uint32 | uint64
+}
+
+// AnyTensor is satisfied by every Tensor[T] and is used where the element
+// type is only known at runtime.
+type AnyTensor interface {
+	Shape() Shape
+	DataType() DataType
+	Size() int
+	Value() *Value
+}
+
+// Tensor is an n-dimensional array stored in a flat slice.
+// Views produced by Index, Reshape and friends share the backing slice.
+type Tensor[T Number] struct {
+	data    []T
+	shape   Shape
+	strides []int
+	offset  int
+}
+
+// Size returns the number of elements described by the shape.
+func (s Shape) Size() int {
+	n := 1
+	for i := range s {
+		n *= s[i]
+	}
+	return n
+}
+
+func (s Shape) Clone() Shape {
+	ret := make(Shape, len(s))
+	copy(ret, s)
+	return ret
+}
+
+func (s Shape) Equal(o Shape) bool {
+	if len(s) != len(o) {
+		return false
+	}
+	for i := range s {
+		if s[i] != o[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// rowMajorStrides returns the strides of a contiguous row-major array.
+func (s Shape) rowMajorStrides() []int {
+	strides := make([]int, len(s))
+	step := 1
+	for i := len(s) - 1; i >= 0; i-- {
+		strides[i] = step
+		step *= s[i]
+	}
+	return strides
+}
+
+// NewTensor returns a zero filled tensor.
+func NewTensor[T Number](shape Shape) *Tensor[T] {
+	return &Tensor[T]{
+		data:    make([]T, shape.Size()),
+		shape:   shape.Clone(),
+		strides: shape.rowMajorStrides(),
+	}
+}
+
+// FullTensor returns a tensor with every element set to v.
+func FullTensor[T Number](v T, shape Shape) *Tensor[T] {
+	t := NewTensor[T](shape)
+	for i := range t.data {
+		t.data[i] = v
+	}
+	return t
+}
+
+// TensorFrom wraps data without copying it. A nil shape means a 1D tensor.
+func TensorFrom[T Number](data []T, shape Shape) (*Tensor[T], error) {
+	if shape == nil {
+		shape = Shape{len(data)}
+	}
+	if shape.Size() != len(data) {
+		return nil, fmt.Errorf("data len == %d , shape %v size == %d", len(data), shape, shape.Size())
+	}
+	return &Tensor[T]{
+		data:    data,
+		shape:   shape.Clone(),
+		strides: shape.rowMajorStrides(),
+	}, nil
+}
+
+func MustTensorFrom[T Number](data []T, shape Shape) *Tensor[T] {
+	t, err := TensorFrom(data, shape)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// ValueToTensor converts a Value holding (nested) slices into a tensor.
+func ValueToTensor[T Number](v *Value) (*Tensor[T], error) {
+	return SliceToTensor[T](v.data)
+}
+
+// SliceToTensor converts a scalar or (nested) slice of any numeric type
+// into a tensor. Slices that already hold T are copied without reflection.
+func SliceToTensor[T Number](d interface{}) (*Tensor[T], error) {
+	switch v := d.(type) {
+	case *Value:
+		return SliceToTensor[T](v.data)
+	case T:
+		return MustTensorFrom([]T{v}, Shape{}), nil
+	case []T:
+		return MustTensorFrom(append([]T(nil), v...), Shape{len(v)}), nil
+	case [][]T:
+		shape := Shape{len(v), 0}
+		if len(v) > 0 {
+			shape[1] = len(v[0])
+		}
+		out := make([]T, 0, shape.Size())
+		for i := range v {
+			if len(v[i]) != shape[1] {
+				return nil, errors.New("ragged slices can not convert to tensor")
+			}
+			out = append(out, v[i]...)
+		}
+		return MustTensorFrom(out, shape), nil
+	case [][][]T:
+		shape := Shape{len(v), 0, 0}
+		if len(v) > 0 {
+			shape[1] = len(v[0])
+			if len(v[0]) > 0 {
+				shape[2] = len(v[0][0])
+			}
+		}
+		out := make([]T, 0, shape.Size())
+		for i := range v {
+			if len(v[i]) != shape[1] {
+				return nil, errors.New("ragged slices can not convert to tensor")
+			}
+			for k := range v[i] {
+				if len(v[i][k]) != shape[2] {
+					return nil, errors.New("ragged slices can not convert to tensor")
+				}
+				out = append(out, v[i][k]...)
+			}
+		}
+		return MustTensorFrom(out, shape), nil
+	}
+
+	rv := reflect.ValueOf(d)
+	shape := inferSliceShape(rv)
+	out := make([]T, 0, shape.Size())
+	var walk func(rv reflect.Value) error
+	walk = func(rv reflect.Value) error {
+		for rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return errors.New("nil element can not convert to tensor")
+			}
+			rv = rv.Elem()
+		}
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				if err := walk(rv.Index(i)); err != nil {
+					return err
+				}
+			}
+		case reflect.Float32, reflect.Float64:
+			out = append(out, T(rv.Float()))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			out = append(out, T(rv.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			out = append(out, T(rv.Uint()))
+		case reflect.Struct:
+			if val, ok := rv.Interface().(Value); ok {
+				return walk(reflect.ValueOf(val.data))
+			}
+			return fmt.Errorf("unsupported element type %v", rv.Type())
+		default:
+			return fmt.Errorf("unsupported element type %v", rv.Type())
+		}
+		return nil
+	}
+	if err := walk(rv); err != nil {
+		return nil, err
+	}
+	if len(out) != shape.Size() {
+		return nil, errors.New("ragged slices can not convert to tensor")
+	}
+	return MustTensorFrom(out, shape), nil
+}
+
+// inferSliceShape follows the first element of every level, unlike
+// GetShapeBySlice it copes with empty slices and interface elements.
+func inferSliceShape(rv reflect.Value) Shape {
+	shape := Shape{}
+	for {
+		for rv.IsValid() && (rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr) && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+		if !rv.IsValid() {
+			return shape
+		}
+		if val, ok := rv.Interface().(Value); ok {
+			rv = reflect.ValueOf(val.data)
+			continue
+		}
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return shape
+		}
+		shape = append(shape, rv.Len())
+		if rv.Len() == 0 {
+			return shape
+		}
+		rv = rv.Index(0)
+	}
+}
+
+// CastTensor converts every element to U and returns a contiguous tensor.
+func CastTensor[U Number, T Number](t *Tensor[T]) *Tensor[U] {
+	ret := NewTensor[U](t.shape)
+	i := 0
+	t.each(func(off int) {
+		ret.data[i] = U(t.data[off])
+		i++
+	})
+	return ret
+}
+
+func (t *Tensor[T]) Shape() Shape {
+	return t.shape.Clone()
+}
+
+func (t *Tensor[T]) Strides() []int {
+	ret := make([]int, len(t.strides))
+	copy(ret, t.strides)
+	return ret
+}
+
+func (t *Tensor[T]) Dims() int {
+	return len(t.shape)
+}
+
+func (t *Tensor[T]) Size() int {
+	return t.shape.Size()
+}
+
+func (t *Tensor[T]) DataType() DataType {
+	var zero T
+	switch any(zero).(type) {
+	case float32:
+		return DataTypeFloat32
+	case float64:
+		return DataTypeFloat64
+	case uint8:
+		return DataTypeUInt8
+	case uint16:
+		return DataTypeUInt16
+	case uint32:
+		return DataTypeUInt32
+	case uint64:
+		return DataTypeUInt64
+	case uint:
+		return DataTypeUInt
+	case int8:
+		return DataTypeInt8
+	case int16:
+		return DataTypeInt16
+	case int32:
+		return DataTypeInt32
+	case int64:
+		return DataTypeInt64
+	case int:
+		return DataTypeInt
+	}
+	return DataType(reflect.TypeOf(zero).String())
+}
+
+// IsContiguous reports whether the elements are laid out row-major
+// without gaps, which allows Data and Reshape to avoid copying.
+func (t *Tensor[T]) IsContiguous() bool {
+	step := 1
+	for i := len(t.shape) - 1; i >= 0; i-- {
+		if t.shape[i] == 1 {
+			continue
+		}
+		if t.strides[i] != step {
+			return false
+		}
+		step *= t.shape[i]
+	}
+	return true
+}
+
+// Data returns the elements in row-major order. The returned slice shares
+// memory with the tensor when it is contiguous.
+func (t *Tensor[T]) Data() []T {
+	if t.IsContiguous() {
+		return t.data[t.offset : t.offset+t.Size()]
+	}
+	return t.Clone().data
+}
+
+// Contiguous returns t itself when it is contiguous and a compact copy otherwise.
+func (t *Tensor[T]) Contiguous() *Tensor[T] {
+	if t.IsContiguous() {
+		return t
+	}
+	return t.Clone()
+}
+
+// Clone returns a contiguous deep copy.
+func (t *Tensor[T]) Clone() *Tensor[T] {
+	ret := NewTensor[T](t.shape)
+	i := 0
+	t.each(func(off int) {
+		ret.data[i] = t.data[off]
+		i++
+	})
+	return ret
+}
+
+// each calls fn with the storage offset of every element in row-major order.
+func (t *Tensor[T]) each(fn func(off int)) {
+	n := t.Size()
+	if n == 0 {
+		return
+	}
+	if t.IsContiguous() {
+		for i := 0; i < n; i++ {
+			fn(t.offset + i)
+		}
+		return
+	}
+	nd := len(t.shape)
+	idx := make([]int, nd)
+	off := t.offset
+	for k := 0; k < n; k++ {
+		fn(off)
+		for d := nd - 1; d >= 0; d-- {
+			idx[d]++
+			off += t.strides[d]
+			if idx[d] < t.shape[d] {
+				break
+			}
+			off -= t.strides[d] * t.shape[d]
+			idx[d] = 0
+		}
+	}
+}
+
+func (t *Tensor[T]) offsetOf(idx []int) int {
+	if len(idx) != len(t.shape) {
+		panic(fmt.Sprintf("index %v does not match shape %v", idx, t.shape))
+	}
+	off := t.offset
+	for i := range idx {
+		if idx[i] < 0 || idx[i] >= t.shape[i] {
+			panic(fmt.Sprintf("index %v out of range for shape %v", idx, t.shape))
+		}
+		off += idx[i] * t.strides[i]
+	}
+	return off
+}
+
+func (t *Tensor[T]) At(idx ...int) T {
+	return t.data[t.offsetOf(idx)]
+}
+
+func (t *Tensor[T]) Set(v T, idx ...int) {
+	t.data[t.offsetOf(idx)] = v
+}
+
+// Index returns a view of the i-th entry along the first axis.
+func (t *Tensor[T]) Index(i int) *Tensor[T] {
+	if len(t.shape) == 0 || i < 0 || i >= t.shape[0] {
+		panic(fmt.Sprintf("index %d out of range for shape %v", i, t.shape))
+	}
+	return &Tensor[T]{
+		data:    t.data,
+		shape:   t.shape[1:].Clone(),
+		strides: append([]int(nil), t.strides[1:]...),
+		offset:  t.offset + i*t.strides[0],
+	}
+}
+
+// Reshape returns a tensor with the same elements and a new shape. One
+// dimension may be -1 and is inferred. Contiguous tensors are not copied.
+func (t *Tensor[T]) Reshape(reshape Shape) (*Tensor[T], error) {
+	shape, err := resolveReshape(t.shape, reshape)
+	if err != nil {
+		return nil, err
+	}
+	c := t.Contiguous()
+	return &Tensor[T]{
+		data:    c.data,
+		shape:   shape,
+		strides: shape.rowMajorStrides(),
+		offset:  c.offset,
+	}, nil
+}
+
+// resolveReshape checks reshape against the size of from and infers its
+// -1 dimension.
+func resolveReshape(from, reshape Shape) (Shape, error) {
+	shape := reshape.Clone()
+	infer := -1
+	known := 1
+	for i := range shape {
+		if shape[i] == -1 {
+			if infer >= 0 {
+				return nil, errors.New("only one dimension can be -1")
+			}
+			infer = i
+			continue
+		}
+		if shape[i] < 0 {
+			return nil, fmt.Errorf("invalid dimension %d", shape[i])
+		}
+		known *= shape[i]
+	}
+	size := from.Size()
+	if infer >= 0 {
+		if known == 0 || size%known != 0 {
+			return nil, fmt.Errorf("can not reshape %v to %v", from, reshape)
+		}
+		shape[infer] = size / known
+	}
+	if shape.Size() != size {
+		return nil, fmt.Errorf("can not reshape %v to %v", from, reshape)
+	}
+	return shape, nil
+}
+
+func (t *Tensor[T]) MustReshape(reshape Shape) *Tensor[T] {
+	ret, err := t.Reshape(reshape)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// Flatten returns a 1D view of the tensor.
+func (t *Tensor[T]) Flatten() *Tensor[T] {
+	return t.MustReshape(Shape{-1})
+}
+
+// Nested exports the tensor as nested typed slices ([]T, [][]T, ...), the
+// same layout Value holds. The innermost rows share memory with a
+// contiguous tensor.
+func (t *Tensor[T]) Nested() interface{} {
+	data := t.Data()
+	if len(t.shape) == 0 {
+		return data[0]
+	}
+	return buildNested(reflect.ValueOf(data), t.shape).Interface()
+}
+
+func buildNested(flat reflect.Value, shape Shape) reflect.Value {
+	if len(shape) == 1 {
+		return flat.Slice3(0, shape[0], shape[0])
+	}
+	elemType := flat.Type()
+	for i := 2; i < len(shape); i++ {
+		elemType = reflect.SliceOf(elemType)
+	}
+	ret := reflect.MakeSlice(reflect.SliceOf(elemType), shape[0], shape[0])
+	step := shape[1:].Size()
+	for i := 0; i < shape[0]; i++ {
+		ret.Index(i).Set(buildNested(flat.Slice3(i*step, (i+1)*step, (i+1)*step), shape[1:]))
+	}
+	return ret
+}
+
+// Value wraps Nested so the tensor can be passed to code built on Value.
+func (t *Tensor[T]) Value() *Value {
+	return InterfaceConvertValue(t.Nested())
+}
+
+func (t *Tensor[T]) To1D() []T {
+	return t.MustReshape(Shape{-1}).Data()
+}
+
+func (t *Tensor[T]) To2D() [][]T {
+	ret, _ := t.Nested().([][]T)
+	return ret
+}
+
+func (t *Tensor[T]) To3D() [][][]T {
+	ret, _ := t.Nested().([][][]T)
+	return ret
+}
+
+func (t *Tensor[T]) To4D() [][][][]T {
+	ret, _ := t.Nested().([][][][]T)
+	return ret
+}
+
+func (t *Tensor[T]) To5D() [][][][][]T {
+	ret, _ := t.Nested().([][][][][]T)
+	return ret
+}
+
+func (t *Tensor[T]) String() string {
+	return fmt.Sprintf("Tensor%v%v", t.shape, t.Nested())
+}
+
+// AsTensor returns a as *Tensor[T], converting the element type when
+// needed. Half precision tensors are widened through float32.
+func AsTensor[T Number](a AnyTensor) (*Tensor[T], error) {
+	switch t := a.(type) {
+	case *Tensor[T]:
+		return t, nil
+	case *Tensor[float32]:
+		return CastTensor[T](t), nil
+	case *Tensor[float64]:
+		return CastTensor[T](t), nil
+	case *Tensor[uint8]:
+		return CastTensor[T](t), nil
+	case *Tensor[uint16]:
+		return CastTensor[T](t), nil
+	case *Tensor[uint32]:
+		return CastTensor[T](t), nil
+	case *Tensor[uint64]:
+		return CastTensor[T](t), nil
+	case *Tensor[uint]:
+		return CastTensor[T](t), nil
+	case *Tensor[int8]:
+		return CastTensor[T](t), nil
+	case *Tensor[int16]:
+		return CastTensor[T](t), nil
+	case *Tensor[int32]:
+		return CastTensor[T](t), nil
+	case *Tensor[int64]:
+		return CastTensor[T](t), nil
+	case *Tensor[int]:
+		return CastTensor[T](t), nil
+	case *HalfTensor[Float16]:
+		return CastTensor[T](t.Float32()), nil
+	case *HalfTensor[BFloat16]:
+		return CastTensor[T](t.Float32()), nil
+	}
+	return nil, fmt.Errorf("can not convert %v to tensor", a.DataType())
+}
@@ -0,0 +1,309 @@
+package goincv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// SliceNone stands for an omitted start or stop, like None in Python.
+const SliceNone = math.MinInt32
+
+// SliceIndex selects along one axis in Tensor.Slice, either a
+// start:stop:step range or a single index that drops the axis.
+type SliceIndex struct {
+	Start int
+	Stop  int
+	Step  int
+	index bool
+}
+
+// All selects the whole axis (":").
+func All() SliceIndex {
+	return SliceIndex{Start: SliceNone, Stop: SliceNone, Step: 1}
+}
+
+// Span selects start:stop. Negative values count from the end and
+// SliceNone leaves a side open.
+func Span(start, stop int) SliceIndex {
+	return SliceIndex{Start: start, Stop: stop, Step: 1}
+}
+
+// SpanStep selects start:stop:step; step may be negative.
+func SpanStep(start, stop, step int) SliceIndex {
+	return SliceIndex{Start: start, Stop: stop, Step: step}
+}
+
+// Idx selects a single position and removes the axis.
+func Idx(i int) SliceIndex {
+	return SliceIndex{Start: i, index: true}
+}
+
+// resolve returns the first position, the step and the element count on
+// an axis of length n, following Python's slice.indices.
+func (s SliceIndex) resolve(n int) (start, step, count int, err error) {
+	if s.index {
+		i := s.Start
+		if i < 0 {
+			i += n
+		}
+		if i < 0 || i >= n {
+			return 0, 0, 0, fmt.Errorf("index %d out of range for axis of length %d", s.Start, n)
+		}
+		return i, 1, 1, nil
+	}
+	step = s.Step
+	if step == 0 {
+		return 0, 0, 0, errors.New("slice step can not be zero")
+	}
+	lower, upper := 0, n
+	if step < 0 {
+		lower, upper = -1, n-1
+	}
+	clamp := func(v, def int) int {
+		if v == SliceNone {
+			return def
+		}
+		if v < 0 {
+			v += n
+			if v < lower {
+				v = lower
+			}
+		} else if v > upper {
+			v = upper
+		}
+		return v
+	}
+	if step > 0 {
+		start, stop := clamp(s.Start, lower), clamp(s.Stop, upper)
+		if stop > start {
+			count = (stop - start + step - 1) / step
+		}
+		return start, step, count, nil
+	}
+	start, stop := clamp(s.Start, upper), clamp(s.Stop, lower)
+	if start > stop {
+		count = (start - stop - step - 1) / -step
+	}
+	return start, step, count, nil
+}
+
+// Slice returns a view selected per axis, e.g. out[0, :, 4:] is
+// out.Slice(Idx(0), All(), Span(4, SliceNone)). Missing trailing axes are
+// taken whole.
+func (t *Tensor[T]) Slice(idx ...SliceIndex) (*Tensor[T], error) {
+	if len(idx) > len(t.shape) {
+		return nil, fmt.Errorf("too many indices %d for shape %v", len(idx), t.shape)
+	}
+	ret := &Tensor[T]{data: t.data, offset: t.offset, shape: Shape{}, strides: []int{}}
+	for d := range t.shape {
+		s := All()
+		if d < len(idx) {
+			s = idx[d]
+		}
+		start, step, count, err := s.resolve(t.shape[d])
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			ret.offset += start * t.strides[d]
+		}
+		if s.index {
+			continue
+		}
+		ret.shape = append(ret.shape, count)
+		ret.strides = append(ret.strides, step*t.strides[d])
+	}
+	return ret, nil
+}
+
+func (t *Tensor[T]) MustSlice(idx ...SliceIndex) *Tensor[T] {
+	ret, err := t.Slice(idx...)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// CopyFrom writes src into t, broadcasting src to t's shape. Combined
+// with Slice it assigns into a region of a larger tensor.
+func (t *Tensor[T]) CopyFrom(src *Tensor[T]) error {
+	sb, err := src.BroadcastTo(t.shape)
+	if err != nil {
+		return err
+	}
+	// src may overlap t, so read it out first
+	src = sb.Clone()
+	eachPair(t, src, func(i, offDst, offSrc int) {
+		t.data[offDst] = src.data[offSrc]
+	})
+	return nil
+}
+
+// IndexSelect picks the given positions along axis, in order. Negative
+// indices count from the end.
+func (t *Tensor[T]) IndexSelect(axis int, indices []int) (*Tensor[T], error) {
+	axis, err := normalizeAxis(axis, len(t.shape))
+	if err != nil {
+		return nil, err
+	}
+	shape := t.shape.Clone()
+	shape[axis] = len(indices)
+	ret := NewTensor[T](shape)
+	for k, i := range indices {
+		if i < 0 {
+			i += t.shape[axis]
+		}
+		if i < 0 || i >= t.shape[axis] {
+			return nil, fmt.Errorf("index %d out of range for axis %d of shape %v", indices[k], axis, t.shape)
+		}
+		src, _ := t.Narrow(axis, i, 1)
+		dst, _ := ret.Narrow(axis, k, 1)
+		eachPair(dst, src, func(_, offDst, offSrc int) {
+			ret.data[offDst] = t.data[offSrc]
+		})
+	}
+	return ret, nil
+}
+
+// MaskSelect keeps the positions along axis where mask is true.
+func (t *Tensor[T]) MaskSelect(axis int, mask []bool) (*Tensor[T], error) {
+	axis, err := normalizeAxis(axis, len(t.shape))
+	if err != nil {
+		return nil, err
+	}
+	if len(mask) != t.shape[axis] {
+		return nil, fmt.Errorf("mask len %d does not match axis %d of shape %v", len(mask), axis, t.shape)
+	}
+	return t.IndexSelect(axis, MaskIndices(mask))
+}
+
+// BoolMask keeps the elements (or sub-arrays) where mask is non-zero, like
+// numpy's t[mask]. The mask shape must match the leading dimensions of t
+// and the result has shape [count, remaining dims...].
+func (t *Tensor[T]) BoolMask(mask *Tensor[uint8]) (*Tensor[T], error) {
+	nd := len(mask.shape)
+	if nd > len(t.shape) || !mask.shape.Equal(t.shape[:nd]) {
+		return nil, fmt.Errorf("mask shape %v does not match %v", mask.shape, t.shape)
+	}
+	rows, err := t.Reshape(append(Shape{mask.Size()}, t.shape[nd:]...))
+	if err != nil {
+		return nil, err
+	}
+	keep := []int{}
+	i := 0
+	mask.each(func(off int) {
+		if mask.data[off] != 0 {
+			keep = append(keep, i)
+		}
+		i++
+	})
+	return rows.IndexSelect(0, keep)
+}
+
+// MaskIndices returns the positions where mask is true.
+func MaskIndices(mask []bool) []int {
+	ret := []int{}
+	for i := range mask {
+		if mask[i] {
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+// Gather reads t along axis at the positions stored in index, as
+// torch.gather: out[i][j][k] = t[index[i][j][k]][j][k] for axis 0. The
+// result has the shape of index.
+func (t *Tensor[T]) Gather(axis int, index *Tensor[int]) (*Tensor[T], error) {
+	axis, err := normalizeAxis(axis, len(t.shape))
+	if err != nil {
+		return nil, err
+	}
+	if len(index.shape) != len(t.shape) {
+		return nil, fmt.Errorf("index shape %v does not match %v", index.shape, t.shape)
+	}
+	ret := NewTensor[T](index.shape)
+	pos := make([]int, len(index.shape))
+	k := 0
+	err = eachIndex(index.shape, func(idx []int) error {
+		copy(pos, idx)
+		pos[axis] = index.At(idx...)
+		if pos[axis] < 0 {
+			pos[axis] += t.shape[axis]
+		}
+		if !t.inRange(pos) {
+			return fmt.Errorf("gather index %v out of range for shape %v", pos, t.shape)
+		}
+		ret.data[k] = t.data[t.offsetOf(pos)]
+		k++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Scatter returns a copy of t where src is written along axis at the
+// positions in index, the inverse of Gather.
+func (t *Tensor[T]) Scatter(axis int, index *Tensor[int], src *Tensor[T]) (*Tensor[T], error) {
+	axis, err := normalizeAxis(axis, len(t.shape))
+	if err != nil {
+		return nil, err
+	}
+	if len(index.shape) != len(t.shape) || !index.shape.Equal(src.shape) {
+		return nil, fmt.Errorf("index shape %v, src shape %v do not match %v", index.shape, src.shape, t.shape)
+	}
+	ret := t.Clone()
+	pos := make([]int, len(index.shape))
+	err = eachIndex(index.shape, func(idx []int) error {
+		copy(pos, idx)
+		pos[axis] = index.At(idx...)
+		if pos[axis] < 0 {
+			pos[axis] += t.shape[axis]
+		}
+		if !ret.inRange(pos) {
+			return fmt.Errorf("scatter index %v out of range for shape %v", pos, t.shape)
+		}
+		ret.data[ret.offsetOf(pos)] = src.At(idx...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (t *Tensor[T]) inRange(idx []int) bool {
+	for i := range idx {
+		if idx[i] < 0 || idx[i] >= t.shape[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// eachIndex visits every multi-index of shape in row-major order.
+func eachIndex(shape Shape, fn func(idx []int) error) error {
+	if shape.Size() == 0 {
+		return nil
+	}
+	idx := make([]int, len(shape))
+	for {
+		if err := fn(idx); err != nil {
+			return err
+		}
+		d := len(shape) - 1
+		for ; d >= 0; d-- {
+			idx[d]++
+			if idx[d] < shape[d] {
+				break
+			}
+			idx[d] = 0
+		}
+		if d < 0 {
+			return nil
+		}
+	}
+}
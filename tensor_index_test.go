@@ -0,0 +1,13 @@
+package goincv
+
+import "testing"
+
+func TestIndexAxisOutOfRange(t *testing.T) {
+	x := MustTensorFrom([]float32{1, 2, 3, 4}, Shape{2, 2})
+	if _, err := x.IndexSelect(-3, []int{0}); err == nil {
+		t.Error("IndexSelect(-3) was accepted")
+	}
+	if s, err := x.IndexSelect(-1, []int{1}); err != nil || s.At(1, 0) != 4 {
+		t.Errorf("IndexSelect(-1) = %v, %v", s, err)
+	}
+}
@@ -0,0 +1,343 @@
+package goincv
+
+import (
+	"fmt"
+	"math"
+)
+
+// BroadcastShapes returns the shape obtained by broadcasting the inputs
+// with NumPy rules: shapes are aligned from the right and a dimension of 1
+// stretches to match the other operand.
+func BroadcastShapes(shapes ...Shape) (Shape, error) {
+	nd := 0
+	for i := range shapes {
+		if len(shapes[i]) > nd {
+			nd = len(shapes[i])
+		}
+	}
+	ret := make(Shape, nd)
+	for i := range ret {
+		ret[i] = 1
+	}
+	for _, s := range shapes {
+		for i := range s {
+			d := s[len(s)-1-i]
+			k := nd - 1 - i
+			switch {
+			case d == ret[k] || d == 1:
+			case ret[k] == 1:
+				ret[k] = d
+			default:
+				return nil, fmt.Errorf("shapes %v can not broadcast together", shapes)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// BroadcastTo returns a view of t expanded to shape. Broadcast axes have a
+// stride of 0, so writing through the view touches shared elements.
+func (t *Tensor[T]) BroadcastTo(shape Shape) (*Tensor[T], error) {
+	if len(shape) < len(t.shape) {
+		return nil, fmt.Errorf("can not broadcast %v to %v", t.shape, shape)
+	}
+	strides := make([]int, len(shape))
+	lead := len(shape) - len(t.shape)
+	for i := range t.shape {
+		switch {
+		case t.shape[i] == shape[lead+i]:
+			strides[lead+i] = t.strides[i]
+		case t.shape[i] == 1:
+			strides[lead+i] = 0
+		default:
+			return nil, fmt.Errorf("can not broadcast %v to %v", t.shape, shape)
+		}
+	}
+	return &Tensor[T]{
+		data:    t.data,
+		shape:   shape.Clone(),
+		strides: strides,
+		offset:  t.offset,
+	}, nil
+}
+
+// eachPair walks two tensors of identical shape in row-major order.
+func eachPair[A Number, B Number](a *Tensor[A], b *Tensor[B], fn func(i, offA, offB int)) {
+	n := a.Size()
+	if n == 0 {
+		return
+	}
+	if a.IsContiguous() && b.IsContiguous() {
+		for i := 0; i < n; i++ {
+			fn(i, a.offset+i, b.offset+i)
+		}
+		return
+	}
+	nd := len(a.shape)
+	idx := make([]int, nd)
+	offA, offB := a.offset, b.offset
+	for k := 0; k < n; k++ {
+		fn(k, offA, offB)
+		for d := nd - 1; d >= 0; d-- {
+			idx[d]++
+			offA += a.strides[d]
+			offB += b.strides[d]
+			if idx[d] < a.shape[d] {
+				break
+			}
+			offA -= a.strides[d] * a.shape[d]
+			offB -= b.strides[d] * b.shape[d]
+			idx[d] = 0
+		}
+	}
+}
+
+// broadcastPair expands a and b to their common shape.
+func broadcastPair[A Number, B Number](a *Tensor[A], b *Tensor[B]) (*Tensor[A], *Tensor[B], error) {
+	shape, err := BroadcastShapes(a.shape, b.shape)
+	if err != nil {
+		return nil, nil, err
+	}
+	ab, err := a.BroadcastTo(shape)
+	if err != nil {
+		return nil, nil, err
+	}
+	bb, err := b.BroadcastTo(shape)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ab, bb, nil
+}
+
+// Apply2 combines a and b element by element after broadcasting.
+func Apply2[T Number](a, b *Tensor[T], fn func(x, y T) T) (*Tensor[T], error) {
+	ab, bb, err := broadcastPair(a, b)
+	if err != nil {
+		return nil, err
+	}
+	ret := NewTensor[T](ab.shape)
+	eachPair(ab, bb, func(i, offA, offB int) {
+		ret.data[i] = fn(ab.data[offA], bb.data[offB])
+	})
+	return ret, nil
+}
+
+// compare returns a 0/1 mask after broadcasting a and b.
+func compare[T Number](a, b *Tensor[T], fn func(x, y T) bool) (*Tensor[uint8], error) {
+	ab, bb, err := broadcastPair(a, b)
+	if err != nil {
+		return nil, err
+	}
+	ret := NewTensor[uint8](ab.shape)
+	eachPair(ab, bb, func(i, offA, offB int) {
+		if fn(ab.data[offA], bb.data[offB]) {
+			ret.data[i] = 1
+		}
+	})
+	return ret, nil
+}
+
+func (t *Tensor[T]) Add(o *Tensor[T]) (*Tensor[T], error) {
+	return Apply2(t, o, func(x, y T) T { return x + y })
+}
+
+func (t *Tensor[T]) Sub(o *Tensor[T]) (*Tensor[T], error) {
+	return Apply2(t, o, func(x, y T) T { return x - y })
+}
+
+func (t *Tensor[T]) Mul(o *Tensor[T]) (*Tensor[T], error) {
+	return Apply2(t, o, func(x, y T) T { return x * y })
+}
+
+func (t *Tensor[T]) Div(o *Tensor[T]) (*Tensor[T], error) {
+	return Apply2(t, o, func(x, y T) T { return x / y })
+}
+
+func (t *Tensor[T]) Pow(o *Tensor[T]) (*Tensor[T], error) {
+	return Apply2(t, o, func(x, y T) T { return T(math.Pow(float64(x), float64(y))) })
+}
+
+func (t *Tensor[T]) Maximum(o *Tensor[T]) (*Tensor[T], error) {
+	return Apply2(t, o, func(x, y T) T {
+		if x > y {
+			return x
+		}
+		return y
+	})
+}
+
+func (t *Tensor[T]) Minimum(o *Tensor[T]) (*Tensor[T], error) {
+	return Apply2(t, o, func(x, y T) T {
+		if x < y {
+			return x
+		}
+		return y
+	})
+}
+
+// Greater returns a 0/1 mask of t > o.
+func (t *Tensor[T]) Greater(o *Tensor[T]) (*Tensor[uint8], error) {
+	return compare(t, o, func(x, y T) bool { return x > y })
+}
+
+// GreaterEqual returns a 0/1 mask of t >= o.
+func (t *Tensor[T]) GreaterEqual(o *Tensor[T]) (*Tensor[uint8], error) {
+	return compare(t, o, func(x, y T) bool { return x >= y })
+}
+
+// Less returns a 0/1 mask of t < o.
+func (t *Tensor[T]) Less(o *Tensor[T]) (*Tensor[uint8], error) {
+	return compare(t, o, func(x, y T) bool { return x < y })
+}
+
+// Equal returns a 0/1 mask of t == o.
+func (t *Tensor[T]) Equal(o *Tensor[T]) (*Tensor[uint8], error) {
+	return compare(t, o, func(x, y T) bool { return x == y })
+}
+
+// Map applies fn to every element and returns a new contiguous tensor.
+func (t *Tensor[T]) Map(fn func(x T) T) *Tensor[T] {
+	ret := NewTensor[T](t.shape)
+	i := 0
+	t.each(func(off int) {
+		ret.data[i] = fn(t.data[off])
+		i++
+	})
+	return ret
+}
+
+func (t *Tensor[T]) AddScalar(v T) *Tensor[T] {
+	return t.Map(func(x T) T { return x + v })
+}
+
+func (t *Tensor[T]) SubScalar(v T) *Tensor[T] {
+	return t.Map(func(x T) T { return x - v })
+}
+
+func (t *Tensor[T]) MulScalar(v T) *Tensor[T] {
+	return t.Map(func(x T) T { return x * v })
+}
+
+func (t *Tensor[T]) DivScalar(v T) *Tensor[T] {
+	return t.Map(func(x T) T { return x / v })
+}
+
+func (t *Tensor[T]) PowScalar(p float64) *Tensor[T] {
+	return t.Map(func(x T) T { return T(math.Pow(float64(x), p)) })
+}
+
+// Clip limits every element to [min, max].
+func (t *Tensor[T]) Clip(min, max T) *Tensor[T] {
+	return t.Map(func(x T) T {
+		if x < min {
+			return min
+		}
+		if x > max {
+			return max
+		}
+		return x
+	})
+}
+
+func (t *Tensor[T]) Abs() *Tensor[T] {
+	return t.Map(func(x T) T {
+		if x < 0 {
+			return -x
+		}
+		return x
+	})
+}
+
+func (t *Tensor[T]) Exp() *Tensor[T] {
+	return t.Map(func(x T) T { return T(math.Exp(float64(x))) })
+}
+
+func (t *Tensor[T]) Log() *Tensor[T] {
+	return t.Map(func(x T) T { return T(math.Log(float64(x))) })
+}
+
+func (t *Tensor[T]) Sqrt() *Tensor[T] {
+	return t.Map(func(x T) T { return T(math.Sqrt(float64(x))) })
+}
+
+// Sigmoid computes 1/(1+e^-x), mostly useful for float tensors.
+func (t *Tensor[T]) Sigmoid() *Tensor[T] {
+	return t.Map(func(x T) T { return T(sigmoid(float64(x))) })
+}
+
+func sigmoid(x float64) float64 {
+	if x >= 0 {
+		return 1 / (1 + math.Exp(-x))
+	}
+	e := math.Exp(x)
+	return e / (1 + e)
+}
+
+// GreaterScalar returns a 0/1 mask of t > v, handy for score thresholding.
+func (t *Tensor[T]) GreaterScalar(v T) *Tensor[uint8] {
+	ret := NewTensor[uint8](t.shape)
+	i := 0
+	t.each(func(off int) {
+		if t.data[off] > v {
+			ret.data[i] = 1
+		}
+		i++
+	})
+	return ret
+}
+
+// MatMul multiplies the last two axes of a and b with NumPy semantics:
+// leading axes broadcast, and a 1-D operand is treated as a row (a) or
+// column (b) vector whose axis is dropped from the result.
+func MatMul[T Number](a, b *Tensor[T]) (*Tensor[T], error) {
+	if len(a.shape) == 0 || len(b.shape) == 0 {
+		return nil, fmt.Errorf("matmul of scalar shapes %v %v", a.shape, b.shape)
+	}
+	va, vb := len(a.shape) == 1, len(b.shape) == 1
+	if va {
+		a = a.Unsqueeze(0)
+	}
+	if vb {
+		b = b.Unsqueeze(1)
+	}
+	m, k := a.shape[len(a.shape)-2], a.shape[len(a.shape)-1]
+	k2, n := b.shape[len(b.shape)-2], b.shape[len(b.shape)-1]
+	if k != k2 {
+		return nil, fmt.Errorf("matmul shapes %v and %v do not match", a.shape, b.shape)
+	}
+	batch, err := BroadcastShapes(a.shape[:len(a.shape)-2], b.shape[:len(b.shape)-2])
+	if err != nil {
+		return nil, err
+	}
+	ab, err := a.BroadcastTo(append(batch.Clone(), m, k))
+	if err != nil {
+		return nil, err
+	}
+	bb, err := b.BroadcastTo(append(batch.Clone(), k, n))
+	if err != nil {
+		return nil, err
+	}
+	ad, bd := ab.Data(), bb.Data()
+	ret := NewTensor[T](append(batch.Clone(), m, n))
+	for p := 0; p < batch.Size(); p++ {
+		x, y, z := ad[p*m*k:], bd[p*k*n:], ret.data[p*m*n:]
+		for i := 0; i < m; i++ {
+			row := z[i*n : (i+1)*n]
+			for l := 0; l < k; l++ {
+				v := x[i*k+l]
+				col := y[l*n : (l+1)*n]
+				for j := range row {
+					row[j] += v * col[j]
+				}
+			}
+		}
+	}
+	shape := Shape{}
+	for i, d := range ret.shape {
+		if (va && i == len(ret.shape)-2) || (vb && i == len(ret.shape)-1) {
+			continue
+		}
+		shape = append(shape, d)
+	}
+	return ret.Reshape(shape)
+}
@@ -0,0 +1,21 @@
+package goincv
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMatMulPropagatesNaN(t *testing.T) {
+	a := MustTensorFrom([]float32{0, 1}, Shape{1, 2})
+	b := MustTensorFrom([]float32{float32(math.NaN()), float32(math.Inf(1)), 1, 2}, Shape{2, 2})
+	c, err := MatMul(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0·NaN and 0·Inf are NaN
+	for i, v := range c.Data() {
+		if !math.IsNaN(float64(v)) {
+			t.Errorf("element %d = %v, want NaN", i, v)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package goincv
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// normalizeAxis resolves negative axes counted from the end.
+func normalizeAxis(axis, nd int) (int, error) {
+	a := axis
+	if a < 0 {
+		a += nd
+	}
+	if a < 0 || a >= nd {
+		return 0, fmt.Errorf("axis %d out of range for %d dims", axis, nd)
+	}
+	return a, nil
+}
+
+// mustAxis is normalizeAxis for methods without an error result. It panics
+// on out of range values, the same way slice indexing does.
+func mustAxis(axis, nd int) int {
+	a, err := normalizeAxis(axis, nd)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// eachLane calls fn with the base offset of every 1D lane along axis. Lanes
+// are visited in row-major order of the remaining axes.
+func (t *Tensor[T]) eachLane(axis int, fn func(i, base int)) {
+	outer := &Tensor[T]{
+		data:    t.data,
+		shape:   append(t.shape[:axis:axis], t.shape[axis+1:]...),
+		strides: append(t.strides[:axis:axis], t.strides[axis+1:]...),
+		offset:  t.offset,
+	}
+	i := 0
+	outer.each(func(off int) {
+		fn(i, off)
+		i++
+	})
+}
+
+// reducedShape drops axis from the shape, or keeps it as 1.
+func (t *Tensor[T]) reducedShape(axis int, keepDims bool) Shape {
+	ret := t.shape.Clone()
+	if keepDims {
+		ret[axis] = 1
+		return ret
+	}
+	return append(ret[:axis], ret[axis+1:]...)
+}
+
+func reduceLanes[T Number, R Number](t *Tensor[T], axis int, keepDims bool, fn func(base, stride, n int) R) *Tensor[R] {
+	axis = mustAxis(axis, len(t.shape))
+	ret := NewTensor[R](t.reducedShape(axis, keepDims))
+	stride, n := t.strides[axis], t.shape[axis]
+	t.eachLane(axis, func(i, base int) {
+		ret.data[i] = fn(base, stride, n)
+	})
+	return ret
+}
+
+// Sum adds the elements along axis.
+func (t *Tensor[T]) Sum(axis int, keepDims bool) *Tensor[T] {
+	return reduceLanes(t, axis, keepDims, func(base, stride, n int) T {
+		var s T
+		for k := 0; k < n; k++ {
+			s += t.data[base+k*stride]
+		}
+		return s
+	})
+}
+
+// Mean averages the elements along axis. Integer tensors truncate.
+func (t *Tensor[T]) Mean(axis int, keepDims bool) *Tensor[T] {
+	return reduceLanes(t, axis, keepDims, func(base, stride, n int) T {
+		var s float64
+		for k := 0; k < n; k++ {
+			s += float64(t.data[base+k*stride])
+		}
+		return T(s / float64(n))
+	})
+}
+
+func (t *Tensor[T]) Max(axis int, keepDims bool) *Tensor[T] {
+	return reduceLanes(t, axis, keepDims, func(base, stride, n int) T {
+		return t.data[base+laneArg(t.data, base, stride, n, true)*stride]
+	})
+}
+
+func (t *Tensor[T]) Min(axis int, keepDims bool) *Tensor[T] {
+	return reduceLanes(t, axis, keepDims, func(base, stride, n int) T {
+		return t.data[base+laneArg(t.data, base, stride, n, false)*stride]
+	})
+}
+
+// ArgMax returns the index of the largest element along axis; ties pick the first.
+func (t *Tensor[T]) ArgMax(axis int, keepDims bool) *Tensor[int] {
+	return reduceLanes(t, axis, keepDims, func(base, stride, n int) int {
+		return laneArg(t.data, base, stride, n, true)
+	})
+}
+
+// ArgMin returns the index of the smallest element along axis; ties pick the first.
+func (t *Tensor[T]) ArgMin(axis int, keepDims bool) *Tensor[int] {
+	return reduceLanes(t, axis, keepDims, func(base, stride, n int) int {
+		return laneArg(t.data, base, stride, n, false)
+	})
+}
+
+func laneArg[T Number](data []T, base, stride, n int, largest bool) int {
+	if n == 0 {
+		panic("reduction over an empty axis")
+	}
+	best := 0
+	for k := 1; k < n; k++ {
+		v := data[base+k*stride]
+		if (largest && v > data[base+best*stride]) || (!largest && v < data[base+best*stride]) {
+			best = k
+		}
+	}
+	return best
+}
+
+// SumAll adds every element.
+func (t *Tensor[T]) SumAll() T {
+	var s T
+	t.each(func(off int) {
+		s += t.data[off]
+	})
+	return s
+}
+
+// ArgMaxAll returns the row-major index of the largest element.
+func (t *Tensor[T]) ArgMaxAll() int {
+	return t.Flatten().ArgMax(0, false).data[0]
+}
+
+// ArgMinAll returns the row-major index of the smallest element.
+func (t *Tensor[T]) ArgMinAll() int {
+	return t.Flatten().ArgMin(0, false).data[0]
+}
+
+// ArgSort returns the indices that sort every lane along axis. The sort
+// is stable so equal elements keep their order.
+func (t *Tensor[T]) ArgSort(axis int, descending bool) *Tensor[int] {
+	axis = mustAxis(axis, len(t.shape))
+	ret := NewTensor[int](t.shape)
+	stride, n := t.strides[axis], t.shape[axis]
+	retStride := ret.strides[axis]
+	idx := make([]int, n)
+	t.eachLane(axis, func(i, base int) {
+		for k := range idx {
+			idx[k] = k
+		}
+		sortLane(t.data, base, stride, idx, descending)
+		retBase := laneBase(ret, axis, i)
+		for k := range idx {
+			ret.data[retBase+k*retStride] = idx[k]
+		}
+	})
+	return ret
+}
+
+// TopK returns the k largest (or smallest) elements along axis together
+// with their indices, ordered from best to worst.
+func (t *Tensor[T]) TopK(k, axis int, largest bool) (*Tensor[T], *Tensor[int]) {
+	axis = mustAxis(axis, len(t.shape))
+	stride, n := t.strides[axis], t.shape[axis]
+	if k > n {
+		k = n
+	}
+	shape := t.shape.Clone()
+	shape[axis] = k
+	values := NewTensor[T](shape)
+	indices := NewTensor[int](shape)
+	retStride := values.strides[axis]
+	idx := make([]int, n)
+	t.eachLane(axis, func(i, base int) {
+		for j := range idx {
+			idx[j] = j
+		}
+		sortLane(t.data, base, stride, idx, largest)
+		retBase := laneBase(values, axis, i)
+		for j := 0; j < k; j++ {
+			values.data[retBase+j*retStride] = t.data[base+idx[j]*stride]
+			indices.data[retBase+j*retStride] = idx[j]
+		}
+	})
+	return values, indices
+}
+
+func sortLane[T Number](data []T, base, stride int, idx []int, descending bool) {
+	sort.SliceStable(idx, func(a, b int) bool {
+		va, vb := data[base+idx[a]*stride], data[base+idx[b]*stride]
+		if descending {
+			return va > vb
+		}
+		return va < vb
+	})
+}
+
+// laneBase returns the base offset of the i-th lane of a contiguous tensor.
+func laneBase[T Number](t *Tensor[T], axis, i int) int {
+	inner := 1
+	for d := axis + 1; d < len(t.shape); d++ {
+		inner *= t.shape[d]
+	}
+	return (i/inner)*inner*t.shape[axis] + i%inner
+}
+
+// Softmax returns the probabilities along axis. The lane maximum is
+// subtracted first so large logits do not overflow.
+func (t *Tensor[T]) Softmax(axis int) *Tensor[T] {
+	return t.softmax(axis, false)
+}
+
+// LogSoftmax returns log(Softmax(axis)) computed without underflow.
+func (t *Tensor[T]) LogSoftmax(axis int) *Tensor[T] {
+	return t.softmax(axis, true)
+}
+
+func (t *Tensor[T]) softmax(axis int, logMode bool) *Tensor[T] {
+	axis = mustAxis(axis, len(t.shape))
+	ret := NewTensor[T](t.shape)
+	stride, n := t.strides[axis], t.shape[axis]
+	retStride := ret.strides[axis]
+	exps := make([]float64, n)
+	t.eachLane(axis, func(i, base int) {
+		if n == 0 {
+			return
+		}
+		maxV := float64(t.data[base+laneArg(t.data, base, stride, n, true)*stride])
+		sum := 0.0
+		for k := 0; k < n; k++ {
+			exps[k] = math.Exp(float64(t.data[base+k*stride]) - maxV)
+			sum += exps[k]
+		}
+		retBase := laneBase(ret, axis, i)
+		logSum := math.Log(sum)
+		for k := 0; k < n; k++ {
+			if logMode {
+				ret.data[retBase+k*retStride] = T(float64(t.data[base+k*stride]) - maxV - logSum)
+			} else {
+				ret.data[retBase+k*retStride] = T(exps[k] / sum)
+			}
+		}
+	})
+	return ret
+}
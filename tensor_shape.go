@@ -0,0 +1,238 @@
+package goincv
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Permute reorders the axes without copying, e.g. Permute(0, 3, 1, 2)
+// turns NHWC into NCHW. Call Contiguous to get a compact buffer.
+func (t *Tensor[T]) Permute(axes ...int) (*Tensor[T], error) {
+	nd := len(t.shape)
+	if len(axes) != nd {
+		return nil, fmt.Errorf("permute axes %v do not match %d dims", axes, nd)
+	}
+	seen := make([]bool, nd)
+	shape := make(Shape, nd)
+	strides := make([]int, nd)
+	for i, a := range axes {
+		if a < 0 {
+			a += nd
+		}
+		if a < 0 || a >= nd || seen[a] {
+			return nil, fmt.Errorf("invalid permute axes %v", axes)
+		}
+		seen[a] = true
+		shape[i] = t.shape[a]
+		strides[i] = t.strides[a]
+	}
+	return &Tensor[T]{
+		data:    t.data,
+		shape:   shape,
+		strides: strides,
+		offset:  t.offset,
+	}, nil
+}
+
+func (t *Tensor[T]) MustPermute(axes ...int) *Tensor[T] {
+	ret, err := t.Permute(axes...)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// Transpose reverses the order of the axes.
+func (t *Tensor[T]) Transpose() *Tensor[T] {
+	axes := make([]int, len(t.shape))
+	for i := range axes {
+		axes[i] = len(axes) - 1 - i
+	}
+	return t.MustPermute(axes...)
+}
+
+// SwapAxes exchanges two axes.
+func (t *Tensor[T]) SwapAxes(a, b int) *Tensor[T] {
+	nd := len(t.shape)
+	a, b = mustAxis(a, nd), mustAxis(b, nd)
+	axes := make([]int, nd)
+	for i := range axes {
+		axes[i] = i
+	}
+	axes[a], axes[b] = axes[b], axes[a]
+	return t.MustPermute(axes...)
+}
+
+// Squeeze removes the given axes, which must have length 1. Without
+// arguments every axis of length 1 is removed.
+func (t *Tensor[T]) Squeeze(axes ...int) (*Tensor[T], error) {
+	nd := len(t.shape)
+	drop := make([]bool, nd)
+	if len(axes) == 0 {
+		for i := range t.shape {
+			drop[i] = t.shape[i] == 1
+		}
+	}
+	for _, a := range axes {
+		a, err := normalizeAxis(a, nd)
+		if err != nil {
+			return nil, err
+		}
+		if t.shape[a] != 1 {
+			return nil, fmt.Errorf("can not squeeze axis %d of shape %v", a, t.shape)
+		}
+		drop[a] = true
+	}
+	ret := &Tensor[T]{data: t.data, offset: t.offset, shape: Shape{}, strides: []int{}}
+	for i := range t.shape {
+		if !drop[i] {
+			ret.shape = append(ret.shape, t.shape[i])
+			ret.strides = append(ret.strides, t.strides[i])
+		}
+	}
+	return ret, nil
+}
+
+// Unsqueeze inserts an axis of length 1 at axis (numpy expand_dims).
+func (t *Tensor[T]) Unsqueeze(axis int) *Tensor[T] {
+	nd := len(t.shape) + 1
+	axis = mustAxis(axis, nd)
+	stride := 1
+	if axis < len(t.shape) {
+		stride = t.strides[axis] * t.shape[axis]
+	}
+	shape := make(Shape, 0, nd)
+	strides := make([]int, 0, nd)
+	shape = append(append(append(shape, t.shape[:axis]...), 1), t.shape[axis:]...)
+	strides = append(append(append(strides, t.strides[:axis]...), stride), t.strides[axis:]...)
+	return &Tensor[T]{
+		data:    t.data,
+		shape:   shape,
+		strides: strides,
+		offset:  t.offset,
+	}
+}
+
+// ExpandDims is an alias of Unsqueeze.
+func (t *Tensor[T]) ExpandDims(axis int) *Tensor[T] {
+	return t.Unsqueeze(axis)
+}
+
+// Narrow returns a view of length elements along axis starting at start.
+func (t *Tensor[T]) Narrow(axis, start, length int) (*Tensor[T], error) {
+	axis, err := normalizeAxis(axis, len(t.shape))
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 || length < 0 || start+length > t.shape[axis] {
+		return nil, fmt.Errorf("narrow [%d:%d] out of range for axis %d of shape %v", start, start+length, axis, t.shape)
+	}
+	shape := t.shape.Clone()
+	shape[axis] = length
+	return &Tensor[T]{
+		data:    t.data,
+		shape:   shape,
+		strides: append([]int(nil), t.strides...),
+		offset:  t.offset + start*t.strides[axis],
+	}, nil
+}
+
+// ConcatTensors joins tensors along an existing axis. All other
+// dimensions must match.
+func ConcatTensors[T Number](axis int, ts ...*Tensor[T]) (*Tensor[T], error) {
+	if len(ts) == 0 {
+		return nil, errors.New("nothing to concat")
+	}
+	nd := len(ts[0].shape)
+	axis, err := normalizeAxis(axis, nd)
+	if err != nil {
+		return nil, err
+	}
+	shape := ts[0].shape.Clone()
+	shape[axis] = 0
+	for _, t := range ts {
+		if len(t.shape) != nd {
+			return nil, fmt.Errorf("can not concat shape %v with %v", ts[0].shape, t.shape)
+		}
+		for d := range t.shape {
+			if d != axis && t.shape[d] != ts[0].shape[d] {
+				return nil, fmt.Errorf("can not concat shape %v with %v on axis %d", ts[0].shape, t.shape, axis)
+			}
+		}
+		shape[axis] += t.shape[axis]
+	}
+	ret := NewTensor[T](shape)
+	start := 0
+	for _, t := range ts {
+		dst, _ := ret.Narrow(axis, start, t.shape[axis])
+		eachPair(dst, t, func(i, offDst, offSrc int) {
+			ret.data[offDst] = t.data[offSrc]
+		})
+		start += t.shape[axis]
+	}
+	return ret, nil
+}
+
+// StackTensors joins tensors of identical shape along a new axis, e.g.
+// stacking CHW images into an NCHW batch.
+func StackTensors[T Number](axis int, ts ...*Tensor[T]) (*Tensor[T], error) {
+	if len(ts) == 0 {
+		return nil, errors.New("nothing to stack")
+	}
+	if _, err := normalizeAxis(axis, len(ts[0].shape)+1); err != nil {
+		return nil, err
+	}
+	items := make([]*Tensor[T], len(ts))
+	for i, t := range ts {
+		if !t.shape.Equal(ts[0].shape) {
+			return nil, fmt.Errorf("can not stack shape %v with %v", ts[0].shape, t.shape)
+		}
+		items[i] = t.Unsqueeze(axis)
+	}
+	return ConcatTensors(axis, items...)
+}
+
+// Split cuts t along axis into views of the given sizes.
+func (t *Tensor[T]) Split(axis int, sizes ...int) ([]*Tensor[T], error) {
+	axis, err := normalizeAxis(axis, len(t.shape))
+	if err != nil {
+		return nil, err
+	}
+	total := 0
+	for _, s := range sizes {
+		total += s
+	}
+	if total != t.shape[axis] {
+		return nil, fmt.Errorf("split sizes %v do not add up to %d", sizes, t.shape[axis])
+	}
+	ret := make([]*Tensor[T], 0, len(sizes))
+	start := 0
+	for _, s := range sizes {
+		v, err := t.Narrow(axis, start, s)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, v)
+		start += s
+	}
+	return ret, nil
+}
+
+// Chunk splits t into n views along axis; the last chunk may be smaller.
+func (t *Tensor[T]) Chunk(axis, n int) []*Tensor[T] {
+	axis = mustAxis(axis, len(t.shape))
+	if n <= 0 {
+		panic("chunk count must be positive")
+	}
+	size := (t.shape[axis] + n - 1) / n
+	sizes := []int{}
+	for left := t.shape[axis]; left > 0; left -= size {
+		if left < size {
+			sizes = append(sizes, left)
+		} else {
+			sizes = append(sizes, size)
+		}
+	}
+	ret, _ := t.Split(axis, sizes...)
+	return ret
+}
@@ -0,0 +1,16 @@
+package goincv
+
+import "testing"
+
+func TestShapeAxisOutOfRange(t *testing.T) {
+	x := MustTensorFrom([]float32{1, 2, 3, 4}, Shape{2, 2})
+	if _, err := x.Squeeze(2); err == nil {
+		t.Error("Squeeze(2) was accepted")
+	}
+	if _, err := StackTensors(3, x, x); err == nil {
+		t.Error("StackTensors(3) was accepted")
+	}
+	if s, err := StackTensors(-1, x, x); err != nil || !s.Shape().Equal(Shape{2, 2, 2}) {
+		t.Errorf("StackTensors(-1) = %v, %v", s, err)
+	}
+}
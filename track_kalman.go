@@ -0,0 +1,257 @@
+package goincv
+
+import "math"
+
+// kalmanBox is the constant velocity filter of SORT, DeepSORT and ByteTrack
+// over the state (cx, cy, aspect, h) and its velocity. Noise scales with
+// the box height.
+type kalmanBox struct {
+	mean [8]float64
+	cov  [8][8]float64
+}
+
+const (
+	kalmanStdPosition = 1.0 / 20
+	kalmanStdVelocity = 1.0 / 160
+)
+
+// xyah converts a corner box to the measurement space.
+func xyah(b [4]float64) [4]float64 {
+	w, h := b[2]-b[0], b[3]-b[1]
+	if h <= 0 {
+		h = 1
+	}
+	return [4]float64{(b[0] + b[2]) / 2, (b[1] + b[3]) / 2, w / h, h}
+}
+
+func newKalmanBox(b [4]float64) *kalmanBox {
+	z := xyah(b)
+	k := &kalmanBox{}
+	copy(k.mean[:4], z[:])
+	h := z[3]
+	std := [8]float64{
+		2 * kalmanStdPosition * h, 2 * kalmanStdPosition * h, 1e-2, 2 * kalmanStdPosition * h,
+		10 * kalmanStdVelocity * h, 10 * kalmanStdVelocity * h, 1e-5, 10 * kalmanStdVelocity * h,
+	}
+	for i, s := range std {
+		k.cov[i][i] = s * s
+	}
+	return k
+}
+
+// rect returns the estimated corner box.
+func (k *kalmanBox) rect() [4]float64 {
+	cx, cy, a, h := k.mean[0], k.mean[1], k.mean[2], k.mean[3]
+	w := a * h
+	return [4]float64{cx - w/2, cy - h/2, cx + w/2, cy + h/2}
+}
+
+func (k *kalmanBox) predict() {
+	// the box stops shrinking once it would vanish
+	if k.mean[3]+k.mean[7] <= 0 {
+		k.mean[7] = 0
+	}
+	for i := 0; i < 4; i++ {
+		k.mean[i] += k.mean[i+4]
+	}
+	// P = F P Fᵀ with F = [I I; 0 I]
+	var p [8][8]float64
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			v := k.cov[i][j]
+			if i < 4 {
+				v += k.cov[i+4][j]
+			}
+			p[i][j] = v
+		}
+	}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 4; j++ {
+			p[i][j] += p[i][j+4]
+		}
+	}
+	h := k.mean[3]
+	std := [8]float64{
+		kalmanStdPosition * h, kalmanStdPosition * h, 1e-2, kalmanStdPosition * h,
+		kalmanStdVelocity * h, kalmanStdVelocity * h, 1e-5, kalmanStdVelocity * h,
+	}
+	for i, s := range std {
+		p[i][i] += s * s
+	}
+	k.cov = p
+}
+
+func (k *kalmanBox) update(b [4]float64) {
+	z := xyah(b)
+	h := k.mean[3]
+	r := [4]float64{kalmanStdPosition * h, kalmanStdPosition * h, 1e-1, kalmanStdPosition * h}
+	// S = H P Hᵀ + R is the top left block
+	var s [4][4]float64
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			s[i][j] = k.cov[i][j]
+		}
+		s[i][i] += r[i] * r[i]
+	}
+	si, ok := invert4(s)
+	if !ok {
+		return
+	}
+	// K = P Hᵀ S⁻¹, 8x4
+	var gain [8][4]float64
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 4; j++ {
+			for l := 0; l < 4; l++ {
+				gain[i][j] += k.cov[i][l] * si[l][j]
+			}
+		}
+	}
+	var innov [4]float64
+	for i := range innov {
+		innov[i] = z[i] - k.mean[i]
+	}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 4; j++ {
+			k.mean[i] += gain[i][j] * innov[j]
+		}
+	}
+	// P -= K H P, where H P is the top four rows of P
+	var p [8][8]float64
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			v := k.cov[i][j]
+			for l := 0; l < 4; l++ {
+				v -= gain[i][l] * k.cov[l][j]
+			}
+			p[i][j] = v
+		}
+	}
+	k.cov = p
+}
+
+// invert4 inverts by Gauss-Jordan elimination with partial pivoting.
+func invert4(m [4][4]float64) (inv [4][4]float64, ok bool) {
+	for i := range inv {
+		inv[i][i] = 1
+	}
+	for c := 0; c < 4; c++ {
+		p := c
+		for r := c + 1; r < 4; r++ {
+			if math.Abs(m[r][c]) > math.Abs(m[p][c]) {
+				p = r
+			}
+		}
+		if m[p][c] == 0 {
+			return inv, false
+		}
+		m[c], m[p] = m[p], m[c]
+		inv[c], inv[p] = inv[p], inv[c]
+		d := m[c][c]
+		for j := 0; j < 4; j++ {
+			m[c][j] /= d
+			inv[c][j] /= d
+		}
+		for r := 0; r < 4; r++ {
+			if r == c || m[r][c] == 0 {
+				continue
+			}
+			f := m[r][c]
+			for j := 0; j < 4; j++ {
+				m[r][j] -= f * m[c][j]
+				inv[r][j] -= f * inv[c][j]
+			}
+		}
+	}
+	return inv, true
+}
+
+// linearAssignment solves the rectangular assignment problem with the
+// Hungarian method and returns the pairs (row, col) whose cost does not
+// exceed limit. Costs above limit or not finite are clamped first, so they
+// never force a worse valid match.
+func linearAssignment(cost [][]float64, limit float64) [][2]int {
+	rows := len(cost)
+	if rows == 0 || len(cost[0]) == 0 {
+		return nil
+	}
+	cols := len(cost[0])
+	transposed := rows > cols
+	n, m := rows, cols
+	if transposed {
+		n, m = cols, rows
+	}
+	at := func(i, j int) float64 {
+		var v float64
+		if transposed {
+			v = cost[j][i]
+		} else {
+			v = cost[i][j]
+		}
+		// NaN never compares below a potential and would stall the search
+		if !(v <= limit) || math.IsInf(v, -1) {
+			return limit + 1
+		}
+		return v
+	}
+	// potentials u, v and matching p over 1-based indices, e-maxx style
+	u := make([]float64, n+1)
+	v := make([]float64, m+1)
+	p := make([]int, m+1)
+	way := make([]int, m+1)
+	minv := make([]float64, m+1)
+	used := make([]bool, m+1)
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		for j := range minv {
+			minv[j] = math.Inf(1)
+			used[j] = false
+		}
+		for {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+			for j := 1; j <= m; j++ {
+				if used[j] {
+					continue
+				}
+				if cur := at(i0-1, j-1) - u[i0] - v[j]; cur < minv[j] {
+					minv[j], way[j] = cur, j0
+				}
+				if minv[j] < delta {
+					delta, j1 = minv[j], j
+				}
+			}
+			for j := 0; j <= m; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+	var ret [][2]int
+	for j := 1; j <= m; j++ {
+		if p[j] == 0 {
+			continue
+		}
+		r, c := p[j]-1, j-1
+		if transposed {
+			r, c = c, r
+		}
+		if v := cost[r][c]; v <= limit && !math.IsInf(v, -1) {
+			ret = append(ret, [2]int{r, c})
+		}
+	}
+	return ret
+}
@@ -0,0 +1,129 @@
+package goincv
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// bruteAssignment returns the minimum total cost over every maximal
+// matching, with costs above limit clamped like linearAssignment does.
+func bruteAssignment(cost [][]float64, limit float64) float64 {
+	rows, cols := len(cost), len(cost[0])
+	used := make([]bool, cols)
+	best := math.Inf(1)
+	var rec func(r, left int, sum float64)
+	rec = func(r, left int, sum float64) {
+		if left == 0 || r == rows {
+			if left == 0 && sum < best {
+				best = sum
+			}
+			return
+		}
+		// rows may stay unmatched only when there are more rows than columns
+		if rows-r > left {
+			rec(r+1, left, sum)
+		}
+		for c := 0; c < cols; c++ {
+			if !used[c] {
+				used[c] = true
+				v := cost[r][c]
+				if v > limit {
+					v = limit + 1
+				}
+				rec(r+1, left-1, sum+v)
+				used[c] = false
+			}
+		}
+	}
+	rec(0, int(math.Min(float64(rows), float64(cols))), 0)
+	return best
+}
+
+func TestLinearAssignment(t *testing.T) {
+	cases := []struct {
+		name  string
+		cost  [][]float64
+		limit float64
+		want  [][2]int
+	}{
+		{"empty", nil, 1, nil},
+		{"diagonal", [][]float64{{0.1, 0.9}, {0.9, 0.1}}, 1, [][2]int{{0, 0}, {1, 1}}},
+		{"crossed", [][]float64{{0.5, 0.1}, {0.1, 0.5}}, 1, [][2]int{{1, 0}, {0, 1}}},
+		{"greedy is wrong", [][]float64{{0.1, 0.2}, {0.2, 0.9}}, 1, [][2]int{{1, 0}, {0, 1}}},
+		{"over limit", [][]float64{{0.9, 0.2}, {0.3, 0.9}}, 0.25, [][2]int{{0, 1}}},
+		{"more rows", [][]float64{{0.8}, {0.1}, {0.5}}, 1, [][2]int{{1, 0}}},
+		{"more cols", [][]float64{{0.8, 0.1, 0.5}}, 1, [][2]int{{0, 1}}},
+		{"NaN row", [][]float64{{math.NaN(), math.NaN()}, {0.1, 0.2}}, 0.8, [][2]int{{1, 0}}},
+		{"infinite", [][]float64{{math.Inf(1), 0.3}, {math.Inf(-1), 0.9}}, 0.8, [][2]int{{0, 1}}},
+	}
+	for _, c := range cases {
+		got := linearAssignment(c.cost, c.limit)
+		if len(got) != len(c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestLinearAssignmentMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for iter := 0; iter < 300; iter++ {
+		rows, cols := 1+r.Intn(6), 1+r.Intn(6)
+		limit := 0.3 + r.Float64()*0.7
+		cost := make([][]float64, rows)
+		for i := range cost {
+			cost[i] = make([]float64, cols)
+			for j := range cost[i] {
+				cost[i][j] = r.Float64()
+			}
+		}
+		got := linearAssignment(cost, limit)
+		rowSeen, colSeen := map[int]bool{}, map[int]bool{}
+		sum := 0.0
+		for _, p := range got {
+			if rowSeen[p[0]] || colSeen[p[1]] {
+				t.Fatalf("%v: %v matches a row or column twice", cost, got)
+			}
+			rowSeen[p[0]], colSeen[p[1]] = true, true
+			if cost[p[0]][p[1]] > limit {
+				t.Fatalf("%v: pair %v exceeds limit %v", cost, p, limit)
+			}
+			sum += cost[p[0]][p[1]]
+		}
+		// pairs left out were clamped to limit+1
+		sum += (math.Min(float64(rows), float64(cols)) - float64(len(got))) * (limit + 1)
+		if want := bruteAssignment(cost, limit); math.Abs(sum-want) > 1e-9 {
+			t.Fatalf("%v limit %v: cost %v, optimum %v", cost, limit, sum, want)
+		}
+	}
+}
+
+func TestTrackerNaNEmbedding(t *testing.T) {
+	tr := NewTracker()
+	tr.EmbeddingWeight = 0.5
+	nan := float32(math.NaN())
+	box := func(x int, e []float32) Box {
+		return Box{Rectangle: image.Rect(x, 10, x+40, 50), Prob: 0.9, Extension: map[string]interface{}{EmbeddingKey: e}}
+	}
+	for frame := 0; frame < 4; frame++ {
+		got := tr.Update([]Box{box(10+frame, []float32{1, 0}), box(200, []float32{nan, 1})})
+		if frame > 0 && len(got) != 2 {
+			t.Fatalf("frame %d: %d confirmed tracks, want 2", frame, len(got))
+		}
+		for _, track := range got {
+			for _, v := range track.Embedding {
+				if math.IsNaN(float64(v)) {
+					t.Fatalf("frame %d: track %d keeps a NaN embedding", frame, track.ID)
+				}
+			}
+		}
+	}
+}
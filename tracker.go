@@ -0,0 +1,349 @@
+package goincv
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+// EmbeddingKey is the Box.Extension key of an optional []float32
+// appearance embedding used by Tracker.
+const EmbeddingKey = "embedding"
+
+// TrackState is the lifecycle stage of a Track.
+type TrackState int
+
+const (
+	TrackTentative TrackState = iota // seen too few times to be reported
+	TrackConfirmed                   // matched in the current frame
+	TrackLost                        // not matched lately, kept for MaxLost frames
+	TrackRemoved                     // dropped, its ID is not reused
+)
+
+func (s TrackState) String() string {
+	switch s {
+	case TrackTentative:
+		return "tentative"
+	case TrackConfirmed:
+		return "confirmed"
+	case TrackLost:
+		return "lost"
+	}
+	return "removed"
+}
+
+// Track is one object followed across frames.
+type Track struct {
+	ID        int
+	State     TrackState
+	Box       Box       // last detection, Rectangle replaced by the filtered estimate
+	Hits      int       // frames with a matching detection
+	Age       int       // frames since the track started
+	Lost      int       // frames since the last match
+	Embedding []float32 // running mean of the detection embeddings
+	Velocity  [2]float32
+
+	kf        *kalmanBox
+	activated bool
+}
+
+// Tracker assigns persistent IDs to the detections of consecutive frames
+// following ByteTrack: tracks are predicted with a Kalman filter, matched
+// to confident detections by IoU with the Hungarian method, and the tracks
+// left over get a second chance against low score detections before they
+// are marked lost. It is safe for concurrent use, so one Tracker can be
+// fed from an FFmpegWorking callback.
+type Tracker struct {
+	HighThreshold     float32 // detections above it are matched first and may start tracks
+	LowThreshold      float32 // detections below it are ignored
+	NewTrackScore     float32 // minimum score to start a track
+	MatchIoU          float32 // minimum IoU of the first association
+	LowMatchIoU       float32 // minimum IoU against low score detections
+	TentativeIoU      float32 // minimum IoU for tentative tracks
+	MinHits           int     // matches before a track is confirmed
+	MaxLost           int     // frames a lost track is kept
+	Agnostic          bool    // match detections of any class
+	EmbeddingWeight   float32 // share of the appearance distance in the cost, 0 ignores embeddings
+	EmbeddingMomentum float32 // weight of the old embedding in its running mean
+
+	mu     sync.Mutex
+	tracks []*Track
+	nextID int
+	frame  int
+}
+
+// NewTracker returns a tracker with the ByteTrack defaults.
+func NewTracker() *Tracker {
+	return &Tracker{
+		HighThreshold:     0.5,
+		LowThreshold:      0.1,
+		NewTrackScore:     0.6,
+		MatchIoU:          0.2,
+		LowMatchIoU:       0.5,
+		TentativeIoU:      0.3,
+		MinHits:           2,
+		MaxLost:           30,
+		EmbeddingMomentum: 0.9,
+	}
+}
+
+// Update consumes the detections of the next frame and returns the
+// confirmed tracks matched in it. Detections are usually the output of
+// NMS.End or MultiClassNms.
+func (t *Tracker) Update(boxes []Box) []Track {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.frame++
+
+	var high, low []Box
+	for _, b := range boxes {
+		if b.Prob >= t.HighThreshold {
+			high = append(high, b)
+		} else if b.Prob >= t.LowThreshold {
+			low = append(low, b)
+		}
+	}
+	var active, tentative []*Track
+	for _, tr := range t.tracks {
+		// only followed tracks keep growing or shrinking
+		if tr.State != TrackConfirmed {
+			tr.kf.mean[7] = 0
+		}
+		tr.kf.predict()
+		tr.Age++
+		tr.Lost++
+		if tr.activated {
+			active = append(active, tr)
+		} else {
+			tentative = append(tentative, tr)
+		}
+	}
+
+	// first pass: confirmed and lost tracks against confident detections
+	matches, restTracks, restHigh := t.associate(active, high, t.MatchIoU, true)
+	for _, m := range matches {
+		t.apply(m.track, m.box)
+	}
+	// second pass: tracks still followed against the low score boxes
+	var following []*Track
+	for _, tr := range restTracks {
+		if tr.State == TrackConfirmed {
+			following = append(following, tr)
+		}
+	}
+	matches, unmatched, _ := t.associate(following, low, t.LowMatchIoU, false)
+	for _, m := range matches {
+		t.apply(m.track, m.box)
+	}
+	for _, tr := range unmatched {
+		tr.State = TrackLost
+	}
+	// tentative tracks only get confident detections
+	matches, unmatched, restHigh = t.associate(tentative, restHigh, t.TentativeIoU, true)
+	for _, m := range matches {
+		t.apply(m.track, m.box)
+	}
+	for _, tr := range unmatched {
+		tr.State = TrackRemoved
+	}
+	for _, b := range restHigh {
+		if b.Prob < t.NewTrackScore {
+			continue
+		}
+		t.nextID++
+		tr := &Track{ID: t.nextID, State: TrackTentative, Box: b, Hits: 1, kf: newKalmanBox(rectF(b.Rectangle))}
+		tr.Embedding = t.mixEmbedding(nil, b)
+		// everything seen in the first frame is trusted, as in ByteTrack
+		if t.frame == 1 || t.MinHits <= 1 {
+			tr.State, tr.activated = TrackConfirmed, true
+		}
+		t.tracks = append(t.tracks, tr)
+	}
+
+	alive := t.tracks[:0]
+	var ret []Track
+	for _, tr := range t.tracks {
+		if tr.State == TrackLost && tr.Lost > t.MaxLost {
+			tr.State = TrackRemoved
+		}
+		if tr.State == TrackRemoved {
+			continue
+		}
+		alive = append(alive, tr)
+		if tr.State == TrackConfirmed && tr.Lost == 0 {
+			ret = append(ret, tr.snapshot())
+		}
+	}
+	for i := len(alive); i < len(t.tracks); i++ {
+		t.tracks[i] = nil
+	}
+	t.tracks = alive
+	return ret
+}
+
+// Tracks returns every live track, including tentative and lost ones.
+func (t *Tracker) Tracks() []Track {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ret := make([]Track, len(t.tracks))
+	for i, tr := range t.tracks {
+		ret[i] = tr.snapshot()
+	}
+	return ret
+}
+
+// Reset forgets all tracks, e.g. at a scene cut. IDs keep increasing.
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tracks = nil
+	t.frame = 0
+}
+
+func (tr *Track) snapshot() Track {
+	ret := *tr
+	ret.kf = nil
+	r := tr.kf.rect()
+	ret.Box.Rectangle = image.Rect(int(math.Round(r[0])), int(math.Round(r[1])), int(math.Round(r[2])), int(math.Round(r[3])))
+	ret.Velocity = [2]float32{float32(tr.kf.mean[4]), float32(tr.kf.mean[5])}
+	return ret
+}
+
+func (t *Tracker) apply(tr *Track, b Box) {
+	tr.kf.update(rectF(b.Rectangle))
+	tr.Box = b
+	tr.Hits++
+	tr.Lost = 0
+	tr.Embedding = t.mixEmbedding(tr.Embedding, b)
+	if tr.activated || tr.Hits >= t.MinHits {
+		tr.State, tr.activated = TrackConfirmed, true
+	}
+}
+
+// boxEmbedding returns the normalised EmbeddingKey extension of b; ok is
+// false when it is missing, zero or not finite.
+func boxEmbedding(b Box) (e []float32, ok bool) {
+	e, ok = b.Extension[EmbeddingKey].([]float32)
+	if !ok || len(e) == 0 {
+		return nil, false
+	}
+	s := 0.0
+	for _, x := range e {
+		s += float64(x) * float64(x)
+	}
+	if s == 0 || math.IsNaN(s) || math.IsInf(s, 0) {
+		return nil, false
+	}
+	return normalizeF32(e), true
+}
+
+// mixEmbedding folds the embedding of b into the running mean, normalised.
+// Unusable embeddings leave old unchanged.
+func (t *Tracker) mixEmbedding(old []float32, b Box) []float32 {
+	e, ok := boxEmbedding(b)
+	if !ok {
+		return old
+	}
+	ret := make([]float32, len(e))
+	m := t.EmbeddingMomentum
+	if len(old) != len(e) {
+		m = 0
+	}
+	for i := range ret {
+		ret[i] = e[i] * (1 - m)
+		if m > 0 {
+			ret[i] += old[i] * m
+		}
+	}
+	return normalizeF32(ret)
+}
+
+func normalizeF32(v []float32) []float32 {
+	s := 0.0
+	for _, x := range v {
+		s += float64(x) * float64(x)
+	}
+	if s == 0 {
+		return v
+	}
+	n := float32(1 / math.Sqrt(s))
+	ret := make([]float32, len(v))
+	for i, x := range v {
+		ret[i] = x * n
+	}
+	return ret
+}
+
+type trackMatch struct {
+	track *Track
+	box   Box
+}
+
+// associate matches tracks to boxes, returning the pairs and what is left
+// of both sides.
+func (t *Tracker) associate(tracks []*Track, boxes []Box, minIoU float32, useEmbedding bool) ([]trackMatch, []*Track, []Box) {
+	if len(tracks) == 0 || len(boxes) == 0 {
+		return nil, tracks, boxes
+	}
+	cost := make([][]float64, len(tracks))
+	for i, tr := range tracks {
+		cost[i] = make([]float64, len(boxes))
+		pred := tr.kf.rect()
+		for j, b := range boxes {
+			if !t.Agnostic && tr.Box.ClassID != b.ClassID {
+				cost[i][j] = math.Inf(1)
+				continue
+			}
+			c := 1 - iouF(pred, rectF(b.Rectangle))
+			if w := float64(t.EmbeddingWeight); useEmbedding && w > 0 {
+				if e, ok := boxEmbedding(b); ok && len(e) == len(tr.Embedding) {
+					c = (1-w)*c + w*(1-cosineF32(tr.Embedding, e))
+				}
+			}
+			cost[i][j] = c
+		}
+	}
+	var matches []trackMatch
+	usedT := make([]bool, len(tracks))
+	usedB := make([]bool, len(boxes))
+	for _, p := range linearAssignment(cost, 1-float64(minIoU)) {
+		matches = append(matches, trackMatch{tracks[p[0]], boxes[p[1]]})
+		usedT[p[0]], usedB[p[1]] = true, true
+	}
+	var restT []*Track
+	for i, tr := range tracks {
+		if !usedT[i] {
+			restT = append(restT, tr)
+		}
+	}
+	var restB []Box
+	for j, b := range boxes {
+		if !usedB[j] {
+			restB = append(restB, b)
+		}
+	}
+	return matches, restT, restB
+}
+
+func rectF(r image.Rectangle) [4]float64 {
+	return [4]float64{float64(r.Min.X), float64(r.Min.Y), float64(r.Max.X), float64(r.Max.Y)}
+}
+
+func iouF(a, b [4]float64) float64 {
+	w := math.Min(a[2], b[2]) - math.Max(a[0], b[0])
+	h := math.Min(a[3], b[3]) - math.Max(a[1], b[1])
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	inter := w * h
+	return inter / ((a[2]-a[0])*(a[3]-a[1]) + (b[2]-b[0])*(b[3]-b[1]) - inter)
+}
+
+// cosineF32 is the cosine similarity of two normalised vectors.
+func cosineF32(a, b []float32) float64 {
+	s := 0.0
+	for i := range a {
+		s += float64(a[i]) * float64(b[i])
+	}
+	return s
+}